@@ -7,8 +7,19 @@ else
   name=$1
 fi
 
-echo "Creating the 4096 bit RSA keypair in ${name}hostkey.pem"
-openssl genpkey -algorithm RSA -out "${name}hostkey.pem" -pkeyopt rsa_keygen_bits:4096
+# The second argument selects the type of the keypair, either "rsa" (default) or "ed25519"
+algorithm=${2:-rsa}
 
-echo "Extracting public key from keypair, into ${name}pubkey.pem"
-openssl rsa -pubout -in "${name}hostkey.pem" -out "${name}pubkey.pem"
+if [ "$algorithm" = "ed25519" ] ; then
+  echo "Creating the Ed25519 keypair in ${name}hostkey.pem"
+  openssl genpkey -algorithm ED25519 -out "${name}hostkey.pem"
+
+  echo "Extracting public key from keypair, into ${name}pubkey.pem"
+  openssl pkey -pubout -in "${name}hostkey.pem" -out "${name}pubkey.pem"
+else
+  echo "Creating the 4096 bit RSA keypair in ${name}hostkey.pem"
+  openssl genpkey -algorithm RSA -out "${name}hostkey.pem" -pkeyopt rsa_keygen_bits:4096
+
+  echo "Extracting public key from keypair, into ${name}pubkey.pem"
+  openssl rsa -pubout -in "${name}hostkey.pem" -out "${name}pubkey.pem"
+fi
@@ -4,10 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"gossip/src/core"
+	"gossip/src/crypto/securecomm"
 	"gossip/src/parser/ini"
+	"gossip/src/utils"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var gossipWorkspacePath string
@@ -17,6 +22,108 @@ func init() {
 	gossipWorkspacePath, _ = os.Getwd()
 }
 
+// gossipPolicySectionPrefix is the prefix of the config sections that hold the
+// gossiping rules of a data type, e.g. [gossip.policy.530] for data type 530.
+const gossipPolicySectionPrefix = "gossip.policy."
+
+// readGossipPolicies reads the per-data-type gossip policies from the config.
+// Every key of a policy section is optional.
+func readGossipPolicies(config map[string]ini.KeyValueDict) (map[core.GossipItemDataType]core.GossipDataTypePolicy, error) {
+	policies := map[core.GossipItemDataType]core.GossipDataTypePolicy{}
+	for sectionName, section := range config {
+		if !strings.HasPrefix(sectionName, gossipPolicySectionPrefix) {
+			continue
+		}
+		dataType, err := strconv.ParseUint(strings.TrimPrefix(sectionName, gossipPolicySectionPrefix), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data type in the section name %q: %s", sectionName, err)
+		}
+		policy := core.GossipDataTypePolicy{}
+		if _, ok := section["max_payload_size"]; ok {
+			if policy.MaxPayloadSize, err = section.GetUint16Value("max_payload_size"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := section["default_ttl"]; ok {
+			if policy.DefaultTTL, err = section.GetUint8Value("default_ttl"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := section["max_ttl"]; ok {
+			if policy.MaxTTL, err = section.GetUint8Value("max_ttl"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := section["cache_share"]; ok {
+			if policy.CacheShare, err = section.GetUint8Value("cache_share"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := section["validate_before_forward"]; ok {
+			validate, err := section.GetUint8Value("validate_before_forward")
+			if err != nil {
+				return nil, err
+			}
+			policy.ValidateBeforeForward = validate != 0
+		}
+		if _, ok := section["rate_limit"]; ok {
+			if policy.RateLimit, err = section.GetUint16Value("rate_limit"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := section["priority"]; ok {
+			if policy.Priority, err = section.GetUint8Value("priority"); err != nil {
+				return nil, err
+			}
+		}
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid policy in the section %q: %s", sectionName, err)
+		}
+		policies[core.GossipItemDataType(dataType)] = policy
+	}
+	return policies, nil
+}
+
+// readDiversityPolicy reads the policy for the diversity of the peers from
+// the optional [diversity] section of the config. Every key is optional.
+func readDiversityPolicy(config map[string]ini.KeyValueDict) (core.DiversityPolicy, error) {
+	policy := core.DiversityPolicy{}
+	section, ok := config["diversity"]
+	if !ok {
+		return policy, nil
+	}
+	var err error
+	if _, ok := section["max_peers_per_subnet"]; ok {
+		if policy.MaxPeersPerSubnet, err = section.GetUint16Value("max_peers_per_subnet"); err != nil {
+			return policy, err
+		}
+	}
+	if _, ok := section["max_peers_per_wide_subnet"]; ok {
+		if policy.MaxPeersPerWideSubnet, err = section.GetUint16Value("max_peers_per_wide_subnet"); err != nil {
+			return policy, err
+		}
+	}
+	if _, ok := section["max_source_share"]; ok {
+		if policy.MaxSourceShare, err = section.GetUint8Value("max_source_share"); err != nil {
+			return policy, err
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("invalid policy in the section \"diversity\": %s", err)
+	}
+	return policy, nil
+}
+
+// containsAddr returns true iff the address is in the list of addresses.
+func containsAddr(addrs []string, addr string) bool {
+	for _, other := range addrs {
+		if other == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func newCentralControllerFromConfigFile(configPath string) (*core.CentralController, error) {
 	config, err := ini.ReadConfigFile(configPath)
 	if err != nil {
@@ -46,6 +153,8 @@ func newCentralControllerFromConfigFile(configPath string) (*core.CentralControl
 	if err != nil {
 		return nil, err
 	}
+	// Every address setting is a comma separated list of
+	// addresses, e.g. "127.0.0.1:6001, [::1]:6001".
 	// Check if the bootstrapper address exists
 	bootstrapper, err := gossipConfig.GetStringValue("bootstrapper")
 	if err != nil {
@@ -61,6 +170,70 @@ func newCentralControllerFromConfigFile(configPath string) (*core.CentralControl
 	if err != nil {
 		return nil, err
 	}
+	// Read the optional advertised P2P address, detected automatically if missing
+	advertiseAddr := ""
+	if _, ok := gossipConfig["advertise_address"]; ok {
+		if advertiseAddr, err = gossipConfig.GetStringValue("advertise_address"); err != nil {
+			return nil, err
+		}
+	}
+	// Read the optional static peers, e.g. "<hex identity>@127.0.0.1:6002"
+	staticPeers := ""
+	if _, ok := gossipConfig["static_peers"]; ok {
+		if staticPeers, err = gossipConfig.GetStringValue("static_peers"); err != nil {
+			return nil, err
+		}
+	}
+	// Read the optional ban list file and the permanently banned
+	// identities, IP addresses and CIDR networks, e.g. "10.0.0.0/8"
+	banListPath := ""
+	if _, ok := gossipConfig["ban_list_path"]; ok {
+		if banListPath, err = gossipConfig.GetStringValue("ban_list_path"); err != nil {
+			return nil, err
+		}
+	}
+	bannedPeers := ""
+	if _, ok := gossipConfig["banned_peers"]; ok {
+		if bannedPeers, err = gossipConfig.GetStringValue("banned_peers"); err != nil {
+			return nil, err
+		}
+	}
+	// Read the optional peer history file, which keeps the peers seen
+	// before across restarts for healing a partition of the network
+	peerHistoryPath := ""
+	if _, ok := gossipConfig["peer_history_path"]; ok {
+		if peerHistoryPath, err = gossipConfig.GetStringValue("peer_history_path"); err != nil {
+			return nil, err
+		}
+	}
+	// Read the optional cipher suites of the handshakes in the order of preference,
+	// e.g. "x25519-ed25519, dh-rsa" for connecting to the peers of the original protocol as well
+	var cipherSuites []securecomm.CipherSuite
+	if _, ok := gossipConfig["cipher_suites"]; ok {
+		names, err := gossipConfig.GetStringValue("cipher_suites")
+		if err != nil {
+			return nil, err
+		}
+		if cipherSuites, err = securecomm.ParseCipherSuites(names); err != nil {
+			return nil, err
+		}
+	}
+	// Read the optional limits of the bytes sent and the time duration (in seconds),
+	// after which the key of a direction of a P2P connection is ratcheted forward
+	rekeyBytes := uint64(0)
+	if _, ok := gossipConfig["rekey_bytes"]; ok {
+		if rekeyBytes, err = gossipConfig.GetUint64Value("rekey_bytes"); err != nil {
+			return nil, err
+		}
+	}
+	rekeyInterval := time.Duration(0)
+	if _, ok := gossipConfig["rekey_interval"]; ok {
+		seconds, err := gossipConfig.GetUint32Value("rekey_interval")
+		if err != nil {
+			return nil, err
+		}
+		rekeyInterval = time.Duration(seconds) * time.Second
+	}
 	// Check if the "cache size" exists
 	cacheSize, err := gossipConfig.GetUint16Value("cache_size")
 	if err != nil {
@@ -77,9 +250,67 @@ func newCentralControllerFromConfigFile(configPath string) (*core.CentralControl
 		return nil, err
 	}
 
-	centralController, err := core.NewCentralController(
-		trustedIdentitiesPath, hostKeyPath, pubKeyPath, bootstrapper, apiAddr, p2pAddr, cacheSize, degree, maxTTL,
-	)
+	apiAddrs := utils.SplitAddrList(apiAddr)
+
+	// Read the optional sampler validation interval (in seconds) of the random peer sampling
+	samplerValidationInterval := time.Duration(0)
+	if rpsConfig, ok := config["rps"]; ok {
+		// The RPS api messages are served on every api address, so the
+		// optional RPS api addresses are listened on in addition.
+		if _, ok := rpsConfig["api_address"]; ok {
+			rpsAPIAddr, err := rpsConfig.GetStringValue("api_address")
+			if err != nil {
+				return nil, err
+			}
+			for _, addr := range utils.SplitAddrList(rpsAPIAddr) {
+				if !containsAddr(apiAddrs, addr) {
+					apiAddrs = append(apiAddrs, addr)
+				}
+			}
+		}
+		if _, ok := rpsConfig["sampler_validation_interval"]; ok {
+			seconds, err := rpsConfig.GetUint16Value("sampler_validation_interval")
+			if err != nil {
+				return nil, err
+			}
+			samplerValidationInterval = time.Duration(seconds) * time.Second
+		}
+	}
+
+	// Read the optional diversity policy
+	diversity, err := readDiversityPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the optional per-data-type gossip policies
+	policies, err := readGossipPolicies(config)
+	if err != nil {
+		return nil, err
+	}
+
+	centralController, err := core.NewCentralController(core.CentralControllerConfig{
+		TrustedIdentitiesPath:     trustedIdentitiesPath,
+		HostKeyPath:               hostKeyPath,
+		PubKeyPath:                pubKeyPath,
+		Bootstrapper:              utils.SplitAddrList(bootstrapper),
+		APIAddrs:                  apiAddrs,
+		P2PAddrs:                  utils.SplitAddrList(p2pAddr),
+		AdvertiseAddrs:            utils.SplitAddrList(advertiseAddr),
+		StaticPeers:               utils.SplitAddrList(staticPeers),
+		BanListPath:               banListPath,
+		BannedPeers:               utils.SplitAddrList(bannedPeers),
+		PeerHistoryPath:           peerHistoryPath,
+		CipherSuites:              cipherSuites,
+		RekeyBytes:                rekeyBytes,
+		RekeyInterval:             rekeyInterval,
+		CacheSize:                 cacheSize,
+		Degree:                    degree,
+		MaxTTL:                    maxTTL,
+		SamplerValidationInterval: samplerValidationInterval,
+		Diversity:                 diversity,
+		Policies:                  policies,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"time"
+)
+
+// AggregateID is the 16-bit unsigned integer that identifies
+// an aggregate to which API clients contribute values.
+type AggregateID uint16
+
+// AggregateState is the gossiped state of a single aggregate in an epoch.
+// S and W are the sum and the weight of the "push-sum" protocol, so S/W
+// converges to the average of the contributed values. Min and Max are
+// the extrema of the contributed values. CountMins are the minimums of
+// exponentially distributed random variables drawn for each contribution,
+// from which the number of contributions is estimated.
+type AggregateState struct {
+	S, W      float64
+	Min, Max  float64
+	CountMins []float64
+}
+
+// AggregationState is the gossiped state of all aggregates in an epoch.
+// SizeMins are the minimums of exponentially distributed random variables
+// drawn by each node, from which the network size is estimated.
+type AggregationState struct {
+	Epoch      int64
+	SizeMins   []float64
+	Aggregates map[AggregateID]*AggregateState
+}
+
+// AggregateEstimate is the estimate of an aggregate at the end of an epoch.
+type AggregateEstimate struct {
+	Count    float64
+	Average  float64
+	Sum      float64
+	Min, Max float64
+}
+
+// AggregationConfig holds the parameters of the aggregation.
+type AggregationConfig struct {
+	// numMins is the number of random variables drawn for estimating sizes.
+	// The relative error of the estimates is about 1/sqrt(numMins - 2).
+	numMins int
+	// epochDuration is the duration of an epoch after which every node
+	// starts the aggregation from scratch, so that the estimates follow
+	// the changes of the network and the contributed values.
+	epochDuration time.Duration
+}
+
+// Aggregator estimates the network size and the aggregates of the values
+// contributed by the API clients of all nodes. It is not a goroutine on
+// its own, it is driven by the gossip rounds of the Gossiper.
+type Aggregator struct {
+	config AggregationConfig
+	// contributions is the map of aggregates to the values contributed by
+	// the API clients of this node.
+	contributions map[AggregateID]map[APIClient]float64
+	// state is the aggregation state of the current epoch.
+	state *AggregationState
+	// sizeEstimate and estimates are the results of the last completed epoch.
+	sizeEstimate float64
+	estimates    map[AggregateID]*AggregateEstimate
+}
+
+// NewAggregator is the constructor function for struct type Aggregator.
+func NewAggregator(config AggregationConfig) *Aggregator {
+	aggregator := &Aggregator{
+		config:        config,
+		contributions: map[AggregateID]map[APIClient]float64{},
+		estimates:     map[AggregateID]*AggregateEstimate{},
+	}
+	aggregator.startEpoch(aggregator.currentEpoch())
+	return aggregator
+}
+
+// currentEpoch returns the epoch of the current time. Epochs are derived from
+// the clock, so that all nodes agree on them as long as their clocks do.
+func (aggregator *Aggregator) currentEpoch() int64 {
+	return time.Now().UnixNano() / int64(aggregator.config.epochDuration)
+}
+
+// drawMins returns numMins exponentially distributed random variables with the given rate.
+func (aggregator *Aggregator) drawMins(rate float64) []float64 {
+	mins := make([]float64, aggregator.config.numMins)
+	for i := range mins {
+		mins[i] = mrand.ExpFloat64() / rate
+	}
+	return mins
+}
+
+// estimateCount estimates the number of random variables whose minimums are given.
+func estimateCount(mins []float64) float64 {
+	sum := 0.0
+	for _, min := range mins {
+		sum += min
+	}
+	if sum == 0 {
+		return 0
+	}
+	return float64(len(mins)-1) / sum
+}
+
+// mergeMins sets every element of 'mins' to the minimum of itself and the
+// corresponding element of 'otherMins'. Returns false if the lengths differ.
+func mergeMins(mins, otherMins []float64) bool {
+	if len(mins) != len(otherMins) {
+		return false
+	}
+	for i := range mins {
+		mins[i] = math.Min(mins[i], otherMins[i])
+	}
+	return true
+}
+
+// startEpoch is the method for completing the current epoch, if there is
+// one, and starting the aggregation of the given epoch from scratch.
+func (aggregator *Aggregator) startEpoch(epoch int64) {
+	if aggregator.state != nil {
+		aggregator.sizeEstimate = estimateCount(aggregator.state.SizeMins)
+		aggregator.estimates = map[AggregateID]*AggregateEstimate{}
+		for id, aggState := range aggregator.state.Aggregates {
+			if aggState.W <= 0 {
+				continue
+			}
+			estimate := &AggregateEstimate{
+				Count:   estimateCount(aggState.CountMins),
+				Average: aggState.S / aggState.W,
+				Min:     aggState.Min,
+				Max:     aggState.Max,
+			}
+			estimate.Sum = estimate.Average * estimate.Count
+			aggregator.estimates[id] = estimate
+		}
+	}
+	state := &AggregationState{
+		Epoch:      epoch,
+		SizeMins:   aggregator.drawMins(1),
+		Aggregates: map[AggregateID]*AggregateState{},
+	}
+	for id, values := range aggregator.contributions {
+		if len(values) == 0 {
+			continue
+		}
+		aggState := &AggregateState{Min: math.Inf(1), Max: math.Inf(-1)}
+		for _, value := range values {
+			aggState.S += value
+			aggState.W++
+			aggState.Min = math.Min(aggState.Min, value)
+			aggState.Max = math.Max(aggState.Max, value)
+		}
+		// The minimum of W exponential random variables with rate 1
+		// is an exponential random variable with rate W.
+		aggState.CountMins = aggregator.drawMins(aggState.W)
+		state.Aggregates[id] = aggState
+	}
+	aggregator.state = state
+}
+
+// Contribute sets the value the API client contributes to the aggregate.
+// The value is taken into account starting from the next epoch.
+func (aggregator *Aggregator) Contribute(client APIClient, id AggregateID, value float64) {
+	if _, isMember := aggregator.contributions[id]; !isMember {
+		aggregator.contributions[id] = map[APIClient]float64{}
+	}
+	aggregator.contributions[id][client] = value
+}
+
+// RemoveClient removes all the contributions of the API client.
+func (aggregator *Aggregator) RemoveClient(client APIClient) {
+	for id, values := range aggregator.contributions {
+		delete(values, client)
+		if len(values) == 0 {
+			delete(aggregator.contributions, id)
+		}
+	}
+}
+
+// Round is the method to be called once per gossip round. It completes
+// the current epoch once its time is over.
+func (aggregator *Aggregator) Round() {
+	if epoch := aggregator.currentEpoch(); epoch > aggregator.state.Epoch {
+		aggregator.startEpoch(epoch)
+	}
+}
+
+// Push returns the state to be pushed to a random peer, while keeping
+// the other half of the "push-sum" mass for itself.
+func (aggregator *Aggregator) Push() *AggregationState {
+	pushed := &AggregationState{
+		Epoch:      aggregator.state.Epoch,
+		SizeMins:   append([]float64{}, aggregator.state.SizeMins...),
+		Aggregates: make(map[AggregateID]*AggregateState, len(aggregator.state.Aggregates)),
+	}
+	for id, aggState := range aggregator.state.Aggregates {
+		aggState.S /= 2
+		aggState.W /= 2
+		pushedAggState := *aggState
+		pushedAggState.CountMins = append([]float64{}, aggState.CountMins...)
+		pushed.Aggregates[id] = &pushedAggState
+	}
+	return pushed
+}
+
+// Restore takes back the mass of a state returned by Push which could not be
+// sent to the peer, so that the "push-sum" mass is not lost. The mass of an
+// epoch which is already over is not needed anymore.
+func (aggregator *Aggregator) Restore(pushed *AggregationState) {
+	if pushed.Epoch != aggregator.state.Epoch {
+		return
+	}
+	for id, pushedAggState := range pushed.Aggregates {
+		if aggState, isMember := aggregator.state.Aggregates[id]; isMember {
+			aggState.S += pushedAggState.S
+			aggState.W += pushedAggState.W
+		}
+	}
+}
+
+// Merge is the method for merging the state pushed by a remote peer. States
+// of older epochs are ignored, while a state of a newer epoch makes this node
+// catch up. Returns an error if the state is malformed.
+func (aggregator *Aggregator) Merge(state *AggregationState) error {
+	if state.Epoch < aggregator.state.Epoch {
+		return nil
+	}
+	if len(state.SizeMins) != aggregator.config.numMins {
+		return fmt.Errorf("aggregation state has %d minimums instead of %d", len(state.SizeMins), aggregator.config.numMins)
+	}
+	for _, aggState := range state.Aggregates {
+		if aggState == nil || len(aggState.CountMins) != aggregator.config.numMins ||
+			math.IsNaN(aggState.S) || math.IsInf(aggState.S, 0) ||
+			math.IsNaN(aggState.W) || math.IsInf(aggState.W, 0) || aggState.W < 0 ||
+			!validExtrema(aggState.Min, aggState.Max) {
+			return fmt.Errorf("aggregation state has a malformed aggregate")
+		}
+	}
+	if state.Epoch > aggregator.state.Epoch {
+		// Don't let a remote peer push the epoch too far into the future.
+		if state.Epoch > aggregator.currentEpoch()+1 {
+			return fmt.Errorf("aggregation state is of a future epoch %d", state.Epoch)
+		}
+		aggregator.startEpoch(state.Epoch)
+	}
+	mergeMins(aggregator.state.SizeMins, state.SizeMins)
+	for id, aggState := range state.Aggregates {
+		myAggState, isMember := aggregator.state.Aggregates[id]
+		if !isMember {
+			myAggState = &AggregateState{
+				Min: math.Inf(1), Max: math.Inf(-1),
+				CountMins: append([]float64{}, aggState.CountMins...),
+			}
+			aggregator.state.Aggregates[id] = myAggState
+		}
+		myAggState.S += aggState.S
+		myAggState.W += aggState.W
+		myAggState.Min = math.Min(myAggState.Min, aggState.Min)
+		myAggState.Max = math.Max(myAggState.Max, aggState.Max)
+		mergeMins(myAggState.CountMins, aggState.CountMins)
+	}
+	return nil
+}
+
+// validExtrema returns true iff min and max are the extrema of finite values,
+// or the extrema of no values at all, which are +Inf and -Inf respectively.
+func validExtrema(min, max float64) bool {
+	if math.IsInf(min, 1) && math.IsInf(max, -1) {
+		return true
+	}
+	return !math.IsNaN(min) && !math.IsInf(min, 0) && !math.IsNaN(max) && !math.IsInf(max, 0) && min <= max
+}
+
+// SizeEstimate returns the estimated number of nodes in the network
+// according to the last completed epoch.
+func (aggregator *Aggregator) SizeEstimate() float64 {
+	return aggregator.sizeEstimate
+}
+
+// Estimate returns the estimate of the aggregate according to the
+// last completed epoch, if there is one.
+func (aggregator *Aggregator) Estimate(id AggregateID) (*AggregateEstimate, bool) {
+	estimate, isMember := aggregator.estimates[id]
+	return estimate, isMember
+}
+
+func (aggregator *Aggregator) String() string {
+	return fmt.Sprintf("{config: %+v, contributions: %v, epoch: %d, sizeEstimate: %f, estimates: %v}",
+		aggregator.config, aggregator.contributions, aggregator.state.Epoch, aggregator.sizeEstimate, aggregator.estimates)
+}
+
+func (estimate *AggregateEstimate) String() string {
+	return fmt.Sprintf("%+v", *estimate)
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"gossip/src/datastruct/set"
+	"gossip/src/utils"
 	"io"
 	"log"
+	"math"
 	"net"
 	"sync"
 	"time"
@@ -29,8 +31,9 @@ type APIClientInfoGossiperNotifyDataTypesType GossipItemDataType
 // map[APIClient]*APIClientInfoGossiper by the Gossiper controller.
 type APIClientInfoGossiper struct {
 	notifyDataTypes set.Set
-	// validationMap is a map from message ID's of client notifications to gossip item.
-	validationMap   map[uint16]*GossipItem
+	// validationMap is a map from message ID's of client notifications to gossip item
+	// and the peer who delivered it.
+	validationMap   map[uint16]*GossipItemExtended
 	nextAvailableID uint16
 }
 
@@ -70,6 +73,9 @@ type APIClientInfoCentral struct {
 	endpoint   *APIEndpoint
 	state      APIClientState
 	hasCrashed bool
+	// subscribesPeerEvents is true iff the API client
+	// wants to be notified about the peer lifecycle events.
+	subscribesPeerEvents bool
 }
 
 // APIEndpoint holds a secure connection for communicating with the
@@ -94,7 +100,8 @@ type APIEndpoint struct {
 // requests and it will open an APIEndpoint for each connection. Then by
 // using 'MsgOutQueue', it will inform the Central controller.
 type APIListener struct {
-	ln *net.TCPListener
+	// lns are the listening sockets, one for each listen address.
+	lns []*net.TCPListener
 	// MsgOutQueue is the outgoing message queue from
 	// this APIListener goroutine to the Central controller.
 	MsgOutQueue chan InternalMessage
@@ -104,27 +111,52 @@ type APIListener struct {
 }
 
 // NewAPIListener is the constructor function of APIListener struct.
-func NewAPIListener(apiAddr string, outQ chan InternalMessage) (*APIListener, error) {
-	lAddr, err := net.ResolveTCPAddr("tcp", apiAddr)
-	if err != nil {
-		return nil, err
-	}
-	ln, err := net.ListenTCP("tcp", lAddr)
-	if err != nil {
+// It listens on every address in apiAddrs.
+func NewAPIListener(apiAddrs []string, outQ chan InternalMessage) (*APIListener, error) {
+	lns := make([]*net.TCPListener, 0, len(apiAddrs))
+	for _, apiAddr := range apiAddrs {
+		lAddr, err := net.ResolveTCPAddr("tcp", apiAddr)
+		if err == nil {
+			var ln *net.TCPListener
+			if ln, err = net.ListenTCP("tcp", lAddr); err == nil {
+				lns = append(lns, ln)
+				continue
+			}
+		}
+		// Do not leak the sockets that are already listening.
+		for _, ln := range lns {
+			ln.Close()
+		}
 		return nil, err
 	}
 
 	return &APIListener{
-		ln:          ln,
+		lns:         lns,
 		MsgOutQueue: outQ,
 		sigCh:       make(chan interface{}),
 	}, nil
 }
 
+// listenerRoutine accepts the connections on every listening socket
+// and informs the Central controller once all of them are closed.
 func (apiListener *APIListener) listenerRoutine() {
+	var wg sync.WaitGroup
+	for _, ln := range apiListener.lns {
+		wg.Add(1)
+		go func(ln *net.TCPListener) {
+			defer wg.Done()
+			apiListener.acceptRoutine(ln)
+		}(ln)
+	}
+	wg.Wait()
+	log.Println("API Listener -> Central controller, APIListenerClosedMSG")
+	apiListener.MsgOutQueue <- InternalMessage{Type: APIListenerClosedMSG, Payload: void{}}
+}
+
+func (apiListener *APIListener) acceptRoutine(ln *net.TCPListener) {
 	defer apiListener.recover()
 	for done := false; !done; {
-		conn, err := apiListener.ln.AcceptTCP()
+		conn, err := ln.AcceptTCP()
 		if err != nil {
 			select {
 			case <-apiListener.sigCh:
@@ -151,8 +183,6 @@ func (apiListener *APIListener) listenerRoutine() {
 		log.Println("API Listener -> Central controller, APIEndpointCreatedMSG,", endp)
 		apiListener.MsgOutQueue <- InternalMessage{Type: APIEndpointCreatedMSG, Payload: endp}
 	}
-	log.Println("API Listener -> Central controller, APIListenerClosedMSG")
-	apiListener.MsgOutQueue <- InternalMessage{Type: APIListenerClosedMSG, Payload: void{}}
 }
 
 // RunListenerGoroutine runs the goroutine that will listen
@@ -166,7 +196,9 @@ func (apiListener *APIListener) RunListenerGoroutine() {
 func (apiListener *APIListener) Close() error {
 	// Closing the 'sigCh' channel signals the listener to close itself.
 	close(apiListener.sigCh)
-	apiListener.ln.Close()
+	for _, ln := range apiListener.lns {
+		ln.Close()
+	}
 	return nil
 }
 
@@ -249,6 +281,56 @@ func (apiEndpoint *APIEndpoint) readerRoutine() {
 				log.Println("Error in readerRoutine():", err)
 				continue
 			}
+		case GossipRetract:
+			err := apiEndpoint.handleGossipRetract(binReader, n-4)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case KVPut:
+			err := apiEndpoint.handleKVPut(binReader, n-4)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case DirectSend:
+			err := apiEndpoint.handleDirectSend(binReader, n-4)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case KVGet, KVSubscribe:
+			err := apiEndpoint.handleKVGetOrSubscribe(binReader, n-4, header.MessageType)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case AggregateContribute:
+			err := apiEndpoint.handleAggregateContribute(binReader, n-4)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case AggregateQuery:
+			err := apiEndpoint.handleAggregateQuery(binReader, n-4)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case AdminBan, AdminUnban:
+			err := apiEndpoint.handleAdminBanOrUnban(binReader, n-4, header.MessageType)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case PeerSubscribe:
+			err := apiEndpoint.handlePeerSubscribe(binReader)
+			if err != nil {
+				log.Println("Error in readerRoutine():", err)
+				continue
+			}
+		case RPSQuery:
+			apiEndpoint.handleRPSQuery()
 		default:
 			log.Println("Error in readerRoutine(): invalid MessageType used")
 			break
@@ -342,6 +424,257 @@ func (apiEndpoint *APIEndpoint) handleGossipValidation(binReader io.Reader) erro
 	}
 	return nil
 }
+
+// handleGossipRetract reads a GOSSIP RETRACT api message which has the same
+// layout as a GOSSIP ANNOUNCE, except that the first 2 bytes are reserved.
+func (apiEndpoint *APIEndpoint) handleGossipRetract(binReader io.Reader, size int) error {
+	gossipItem := &GossipItem{}
+	var reserved uint16
+	err := binary.Read(binReader, binary.BigEndian, &reserved)
+	if err != nil {
+		return err
+	}
+	err = binary.Read(binReader, binary.BigEndian, &gossipItem.DataType)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, size-4)
+	err = binary.Read(binReader, binary.BigEndian, &data)
+	gossipItem.Data = string(data)
+	if err != nil {
+		return err
+	}
+	payload := APIRetractMSGPayload{Item: gossipItem}
+	payload2 := InternalMessage{Type: APIRetractMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// readKVKey reads the key length, the 2 reserved bytes and the key, which start
+// every KV api message. Returns the key and the number of remaining bytes.
+func readKVKey(binReader io.Reader, size int) (string, int, error) {
+	var keyLen uint16
+	err := binary.Read(binReader, binary.BigEndian, &keyLen)
+	if err != nil {
+		return "", 0, err
+	}
+	var reserved uint16
+	err = binary.Read(binReader, binary.BigEndian, &reserved)
+	if err != nil {
+		return "", 0, err
+	}
+	if int(keyLen) > size-4 {
+		return "", 0, fmt.Errorf("key length %d exceeds the message size", keyLen)
+	}
+	key := make([]byte, keyLen)
+	err = binary.Read(binReader, binary.BigEndian, &key)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(key), size - 4 - int(keyLen), nil
+}
+
+// handleKVPut reads a KV PUT api message consisting of the key length,
+// 2 reserved bytes, the key and the value.
+func (apiEndpoint *APIEndpoint) handleKVPut(binReader io.Reader, size int) error {
+	key, remaining, err := readKVKey(binReader, size)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, remaining)
+	err = binary.Read(binReader, binary.BigEndian, &value)
+	if err != nil {
+		return err
+	}
+	payload := GossipKVPutMSGPayload{Key: key, Value: string(value)}
+	payload2 := InternalMessage{Type: GossipKVPutMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handleKVGetOrSubscribe reads either a KV GET or a KV SUBSCRIBE api message
+// consisting of the key length, 2 reserved bytes and the key. For KV SUBSCRIBE,
+// the key is the prefix of the keys to be notified about.
+func (apiEndpoint *APIEndpoint) handleKVGetOrSubscribe(binReader io.Reader, size int, messageType APIMessageType) error {
+	key, _, err := readKVKey(binReader, size)
+	if err != nil {
+		return err
+	}
+	who := APIClient{addr: apiEndpoint.conn.RemoteAddr().String()}
+	var payload2 InternalMessage
+	if messageType == KVGet {
+		payload2 = InternalMessage{Type: GossipKVGetMSG, Payload: GossipKVGetMSGPayload{Who: who, Key: key}}
+	} else {
+		payload2 = InternalMessage{Type: GossipKVSubscribeMSG, Payload: GossipKVSubscribeMSGPayload{Who: who, Prefix: key}}
+	}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handleAggregateContribute reads an AGGREGATE CONTRIBUTE api message consisting
+// of the aggregate ID, 2 reserved bytes and the value as an IEEE 754 double.
+func (apiEndpoint *APIEndpoint) handleAggregateContribute(binReader io.Reader, size int) error {
+	if size != 2+2+8 {
+		return fmt.Errorf("AGGREGATE CONTRIBUTE has invalid size %d", size+4)
+	}
+	payload := GossipAggregateContributeMSGPayload{Who: APIClient{addr: apiEndpoint.conn.RemoteAddr().String()}}
+	var reserved uint16
+	for _, field := range []interface{}{&payload.ID, &reserved, &payload.Value} {
+		if err := binary.Read(binReader, binary.BigEndian, field); err != nil {
+			return err
+		}
+	}
+	if math.IsNaN(payload.Value) || math.IsInf(payload.Value, 0) {
+		return fmt.Errorf("AGGREGATE CONTRIBUTE value is not finite")
+	}
+	payload2 := InternalMessage{Type: GossipAggregateContributeMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handleAggregateQuery reads an AGGREGATE QUERY api message consisting
+// of the aggregate ID and 2 reserved bytes.
+func (apiEndpoint *APIEndpoint) handleAggregateQuery(binReader io.Reader, size int) error {
+	if size != 2+2 {
+		return fmt.Errorf("AGGREGATE QUERY has invalid size %d", size+4)
+	}
+	payload := GossipAggregateQueryMSGPayload{Who: APIClient{addr: apiEndpoint.conn.RemoteAddr().String()}}
+	err := binary.Read(binReader, binary.BigEndian, &payload.ID)
+	if err != nil {
+		return err
+	}
+	payload2 := InternalMessage{Type: GossipAggregateQueryMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handleAdminBanOrUnban reads an ADMIN BAN api message consisting of the
+// duration of the ban in seconds, which is 0 if the ban never expires, and
+// the entry, or an ADMIN UNBAN api message consisting of the entry only.
+// The entry is either a hex encoded identity, an IP address or a CIDR
+// network. Admin messages are only accepted from loopback addresses.
+func (apiEndpoint *APIEndpoint) handleAdminBanOrUnban(binReader io.Reader, size int, messageType APIMessageType) error {
+	if addr, ok := apiEndpoint.conn.RemoteAddr().(*net.TCPAddr); !ok || !addr.IP.IsLoopback() {
+		return fmt.Errorf("admin api message from non-loopback address %s", apiEndpoint.conn.RemoteAddr())
+	}
+	var seconds uint32
+	if messageType == AdminBan {
+		if size < 4 {
+			return fmt.Errorf("ADMIN BAN has invalid size %d", size+4)
+		}
+		if err := binary.Read(binReader, binary.BigEndian, &seconds); err != nil {
+			return err
+		}
+		size -= 4
+	}
+	entry := make([]byte, size)
+	if _, err := io.ReadFull(binReader, entry); err != nil {
+		return err
+	}
+	var payload2 InternalMessage
+	if messageType == AdminBan {
+		payload := APIAdminBanMSGPayload{Entry: string(entry)}
+		if seconds != 0 {
+			payload.Expires = time.Now().UTC().Add(time.Duration(seconds) * time.Second)
+		}
+		payload2 = InternalMessage{Type: APIAdminBanMSG, Payload: payload}
+	} else {
+		payload2 = InternalMessage{Type: APIAdminUnbanMSG, Payload: APIAdminUnbanMSGPayload{Entry: string(entry)}}
+	}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handlePeerSubscribe reads a PEER SUBSCRIBE api message consisting of the
+// flags and 2 reserved bytes. The lowest bit of the flags is set iff the
+// client subscribes to the peer lifecycle events, otherwise it unsubscribes.
+func (apiEndpoint *APIEndpoint) handlePeerSubscribe(binReader io.Reader) error {
+	var flags, reserved uint16
+	for _, field := range []interface{}{&flags, &reserved} {
+		if err := binary.Read(binReader, binary.BigEndian, field); err != nil {
+			return err
+		}
+	}
+	payload := APIPeerSubscribeMSGPayload{
+		Who:       APIClient{addr: apiEndpoint.conn.RemoteAddr().String()},
+		Subscribe: flags&1 != 0,
+	}
+	payload2 := InternalMessage{Type: APIPeerSubscribeMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
+// handleRPSQuery reads an RPS QUERY api message, which consists of the header only.
+func (apiEndpoint *APIEndpoint) handleRPSQuery() {
+	payload := MembershipRPSQueryMSGPayload{Who: APIClient{addr: apiEndpoint.conn.RemoteAddr().String()}}
+	payload2 := InternalMessage{Type: MembershipRPSQueryMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+}
+
+// handleDirectSend reads a DIRECT SEND api message consisting of the TTL, the
+// flags, the data type, the request ID, 2 reserved bytes, the identity of the
+// destination and the data. The lowest bit of the flags is set iff the
+// delivery has to be acknowledged.
+func (apiEndpoint *APIEndpoint) handleDirectSend(binReader io.Reader, size int) error {
+	payload := APIDirectSendMSGPayload{Who: APIClient{addr: apiEndpoint.conn.RemoteAddr().String()}}
+	var flags uint8
+	var reserved uint16
+	for _, field := range []interface{}{&payload.TTL, &flags, &payload.DataType, &payload.RequestID, &reserved, &payload.Destination} {
+		if err := binary.Read(binReader, binary.BigEndian, field); err != nil {
+			return err
+		}
+	}
+	payload.WantAck = flags&1 != 0
+	dataSize := size - 8 - len(payload.Destination)
+	if dataSize < 0 {
+		return fmt.Errorf("DIRECT SEND message is too short")
+	}
+	payload.Data = make([]byte, dataSize)
+	err := binary.Read(binReader, binary.BigEndian, &payload.Data)
+	if err != nil {
+		return err
+	}
+	payload2 := InternalMessage{Type: APIDirectSendMSG, Payload: payload}
+	log.Println("API Endpoint -> Central controller, IncomingAPIMSG,", payload2)
+	apiEndpoint.MsgOutQueue <- InternalMessage{
+		Type:    IncomingAPIMSG,
+		Payload: payload2,
+	}
+	return nil
+}
+
 func (apiEndpoint *APIEndpoint) handleGossipNotification(_payload AnyMessage) error {
 	payload := _payload.(APINotificationMSGPayload)
 	// Combine messageID, dataType and data to message
@@ -374,6 +707,179 @@ func (apiEndpoint *APIEndpoint) handleGossipNotification(_payload AnyMessage) er
 	return err
 }
 
+// handleGossipRetraction writes a GOSSIP RETRACTION api message which
+// consists of the data type, 2 reserved bytes and the retracted item ID.
+func (apiEndpoint *APIEndpoint) handleGossipRetraction(_payload AnyMessage) error {
+	payload := _payload.(APIRetractionMSGPayload)
+	size := 2 + 2 + 2 + 2 + len(payload.Tombstone.ItemID)
+	msg := make([]byte, 8, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(GossipRetraction))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(payload.Tombstone.DataType))
+	msg = append(msg, payload.Tombstone.ItemID[:]...)
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handleKVValue writes either a KV VALUE or a KV CHANGE api message consisting
+// of the key length, the flags, the version counter, the identity of the
+// writer, the key and the value. The lowest bit of the flags is set iff
+// the key exists.
+func (apiEndpoint *APIEndpoint) handleKVValue(_payload AnyMessage) error {
+	payload := _payload.(APIKVValueMSGPayload)
+	messageType := KVValue
+	if payload.IsChange {
+		messageType = KVChange
+	}
+	var flags uint16
+	var version KVVersion
+	var value string
+	if payload.Entry != nil {
+		flags |= 1
+		version = payload.Entry.Version
+		value = payload.Entry.Value
+	}
+	size := 2 + 2 + 2 + 2 + 8 + len(version.Node) + len(payload.Key) + len(value)
+	if size > 65535 {
+		return fmt.Errorf("APIEndpoint: Key and value are too large")
+	}
+	msg := make([]byte, 16, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(messageType))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(len(payload.Key)))
+	binary.BigEndian.PutUint16(msg[6:8], flags)
+	binary.BigEndian.PutUint64(msg[8:16], version.Counter)
+	msg = append(msg, version.Node[:]...)
+	msg = append(msg, []byte(payload.Key)...)
+	msg = append(msg, []byte(value)...)
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handleDirectReceive writes a DIRECT RECEIVE api message consisting of the
+// data type, 2 reserved bytes, the identity of the source and the data.
+func (apiEndpoint *APIEndpoint) handleDirectReceive(_payload AnyMessage) error {
+	payload := _payload.(APIDirectReceiveMSGPayload)
+	size := 2 + 2 + 2 + 2 + len(payload.Message.Source) + len(payload.Message.Data)
+	if size > 65535 {
+		return fmt.Errorf("APIEndpoint: Data field is too large")
+	}
+	msg := make([]byte, 8, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(DirectReceive))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(payload.Message.DataType))
+	msg = append(msg, payload.Message.Source[:]...)
+	msg = append(msg, payload.Message.Data...)
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handleDirectAck writes a DIRECT ACK api message consisting of the request
+// ID, 2 reserved bytes and the identity of the node who acknowledged.
+func (apiEndpoint *APIEndpoint) handleDirectAck(_payload AnyMessage) error {
+	payload := _payload.(APIDirectAckMSGPayload)
+	size := 2 + 2 + 2 + 2 + len(payload.From)
+	msg := make([]byte, 8, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(DirectAck))
+	binary.BigEndian.PutUint16(msg[4:6], payload.RequestID)
+	msg = append(msg, payload.From[:]...)
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handleAggregateEstimate writes an AGGREGATE RESULT api message consisting
+// of the aggregate ID, the flags, the network size estimate and the count,
+// average, sum, minimum and maximum of the contributed values, each as an
+// IEEE 754 double. The lowest bit of the flags is set iff the aggregate has
+// an estimate, otherwise its fields are 0.
+func (apiEndpoint *APIEndpoint) handleAggregateEstimate(_payload AnyMessage) error {
+	payload := _payload.(APIAggregateEstimateMSGPayload)
+	var flags uint16
+	estimate := AggregateEstimate{}
+	if payload.Estimate != nil {
+		flags |= 1
+		estimate = *payload.Estimate
+	}
+	values := []float64{payload.SizeEstimate, estimate.Count, estimate.Average, estimate.Sum, estimate.Min, estimate.Max}
+	size := 2 + 2 + 2 + 2 + 8*len(values)
+	msg := make([]byte, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(AggregateResult))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(payload.ID))
+	binary.BigEndian.PutUint16(msg[6:8], flags)
+	for i, value := range values {
+		binary.BigEndian.PutUint64(msg[8+8*i:16+8*i], math.Float64bits(value))
+	}
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handlePeerEvent writes a PEER EVENT api message consisting of the event,
+// the number of neighbours after the event and the identity of the neighbour,
+// which is all zeros for the ViewEmpty and ViewRestored events.
+func (apiEndpoint *APIEndpoint) handlePeerEvent(_payload AnyMessage) error {
+	payload := _payload.(APIPeerEventMSGPayload)
+	size := 2 + 2 + 2 + 2 + len(payload.Peer)
+	msg := make([]byte, 8, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(PeerEvent))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(payload.Event))
+	binary.BigEndian.PutUint16(msg[6:8], payload.Neighbours)
+	msg = append(msg, payload.Peer[:]...)
+
+	// Write message to client
+	_, err := apiEndpoint.conn.Write(msg)
+	return err
+}
+
+// handleRPSPeer writes an RPS PEER api message consisting of the port, the
+// number of port mappings, which is always 0, the flags, the IP address and
+// the host key of the peer. The lowest bit of the flags is set iff the IP
+// address is an IPv6 address. The first address of the peer is used.
+func (apiEndpoint *APIEndpoint) handleRPSPeer(_payload AnyMessage) error {
+	payload := _payload.(APIRPSPeerMSGPayload)
+	if len(payload.Peer.Addrs) == 0 {
+		return fmt.Errorf("APIEndpoint: Peer %s has no address", payload.Peer.ID)
+	}
+	addr, err := utils.ParseIPPort(payload.Peer.Addrs[0])
+	if err != nil {
+		return err
+	}
+	var flags uint8
+	ip := addr.IP.To4()
+	if ip == nil {
+		flags |= 1
+		ip = addr.IP.To16()
+	}
+	size := 2 + 2 + 2 + 1 + 1 + len(ip) + len(payload.PubKey)
+	if size > 65535 {
+		return fmt.Errorf("APIEndpoint: Host key is too large")
+	}
+	msg := make([]byte, 8, size)
+	binary.BigEndian.PutUint16(msg[0:2], uint16(size))
+	binary.BigEndian.PutUint16(msg[2:4], uint16(RPSPeer))
+	binary.BigEndian.PutUint16(msg[4:6], uint16(addr.Port))
+	msg[6] = 0
+	msg[7] = flags
+	msg = append(msg, ip...)
+	msg = append(msg, payload.PubKey...)
+
+	// Write message to client
+	_, err = apiEndpoint.conn.Write(msg)
+	return err
+}
+
 // RunReaderGoroutine runs the goroutine that will read from
 // the api connection, process the segments and route the
 // corresponding InternalMessage to the Central controller.
@@ -396,6 +902,48 @@ func (apiEndpoint *APIEndpoint) writerRoutine() {
 					log.Println("Error in writerRoutine():", err)
 					continue
 				}
+			case APIRetractionMSG:
+				err := apiEndpoint.handleGossipRetraction(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIKVValueMSG:
+				err := apiEndpoint.handleKVValue(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIDirectReceiveMSG:
+				err := apiEndpoint.handleDirectReceive(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIDirectAckMSG:
+				err := apiEndpoint.handleDirectAck(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIAggregateEstimateMSG:
+				err := apiEndpoint.handleAggregateEstimate(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIPeerEventMSG:
+				err := apiEndpoint.handlePeerEvent(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
+			case APIRPSPeerMSG:
+				err := apiEndpoint.handleRPSPeer(im.Payload)
+				if err != nil {
+					log.Println("Error in writerRoutine():", err)
+					continue
+				}
 			default:
 				log.Println("Error in writerRoutine(): invalid internal message type used")
 				break
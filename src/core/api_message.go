@@ -1,5 +1,10 @@
 package core
 
+import (
+	"fmt"
+	"time"
+)
+
 // APIMessageType is the 16-bit unsigned integer that
 // specifies the 'message type' of an api message as
 // described in the specifications.pdf .
@@ -14,8 +19,86 @@ const (
 	GossipNotification
 	// GossipValidation is the enumeration of 'GOSSIP VALIDATION' api message
 	GossipValidation
+	// GossipRetract is the enumeration of 'GOSSIP RETRACT' api message
+	GossipRetract
+	// GossipRetraction is the enumeration of 'GOSSIP RETRACTION' api message
+	GossipRetraction
+	// KVPut is the enumeration of 'KV PUT' api message
+	KVPut
+	// KVGet is the enumeration of 'KV GET' api message
+	KVGet
+	// KVValue is the enumeration of 'KV VALUE' api message
+	KVValue
+	// KVSubscribe is the enumeration of 'KV SUBSCRIBE' api message
+	KVSubscribe
+	// KVChange is the enumeration of 'KV CHANGE' api message
+	KVChange
+	// DirectSend is the enumeration of 'DIRECT SEND' api message
+	DirectSend
+	// DirectReceive is the enumeration of 'DIRECT RECEIVE' api message
+	DirectReceive
+	// DirectAck is the enumeration of 'DIRECT ACK' api message
+	DirectAck
+	// AggregateContribute is the enumeration of 'AGGREGATE CONTRIBUTE' api message
+	AggregateContribute
+	// AggregateQuery is the enumeration of 'AGGREGATE QUERY' api message
+	AggregateQuery
+	// AggregateResult is the enumeration of 'AGGREGATE RESULT' api message
+	AggregateResult
+	// AdminBan is the enumeration of 'ADMIN BAN' api message
+	AdminBan
+	// AdminUnban is the enumeration of 'ADMIN UNBAN' api message
+	AdminUnban
+)
+
+const (
+	// RPSQuery is the enumeration of 'RPS QUERY' api message. The RPS api
+	// messages have the numbers of the random peer sampling module in
+	// the specifications.pdf, so that other modules can use them as is.
+	RPSQuery APIMessageType = iota + 540
+	// RPSPeer is the enumeration of 'RPS PEER' api message
+	RPSPeer
 )
 
+const (
+	// PeerSubscribe is the enumeration of 'PEER SUBSCRIBE' api message. The
+	// peer event api messages have numbers above the ranges of the modules
+	// in the specifications.pdf, so that they clash with none of them.
+	PeerSubscribe APIMessageType = iota + 700
+	// PeerEvent is the enumeration of 'PEER EVENT' api message
+	PeerEvent
+)
+
+// PeerEventType is the 16-bit unsigned integer that specifies
+// the event of a PEER EVENT api message.
+type PeerEventType uint16
+
+const (
+	// PeerUp means that a connection with a neighbour is established.
+	PeerUp PeerEventType = iota
+	// PeerDown means that the connection with a neighbour is closed.
+	PeerDown
+	// ViewEmpty means that the node has no neighbours anymore, i.e. it is isolated.
+	ViewEmpty
+	// ViewRestored means that the node has neighbours again after being isolated.
+	ViewRestored
+)
+
+func (event PeerEventType) String() string {
+	switch event {
+	case PeerUp:
+		return "PeerUp"
+	case PeerDown:
+		return "PeerDown"
+	case ViewEmpty:
+		return "ViewEmpty"
+	case ViewRestored:
+		return "ViewRestored"
+	default:
+		return fmt.Sprintf("PeerEventType(%d)", uint16(event))
+	}
+}
+
 // APIListenerCrashedMSGPayload is the payload type of an InternalMessage
 // with type APIListenerCrashedMSG.
 type APIListenerCrashedMSGPayload error
@@ -68,3 +151,92 @@ type APINotificationMSGPayload GossipNotificationMSGPayload
 // APIValidationMSGPayload is the payload type of an InternalMessage
 // with type APIValidationMSG.
 type APIValidationMSGPayload GossipValidationMSGPayload
+
+// APIRetractMSGPayload is the payload type of an InternalMessage
+// with type APIRetractMSG.
+type APIRetractMSGPayload struct {
+	// Item is the gossip item to be retracted.
+	Item *GossipItem
+}
+
+// APIRetractionMSGPayload is the payload type of an InternalMessage
+// with type APIRetractionMSG.
+type APIRetractionMSGPayload GossipRetractionMSGPayload
+
+// APIKVValueMSGPayload is the payload type of an InternalMessage
+// with type APIKVValueMSG.
+type APIKVValueMSGPayload GossipKVValueMSGPayload
+
+// APIDirectSendMSGPayload is the payload type of an InternalMessage
+// with type APIDirectSendMSG.
+type APIDirectSendMSGPayload struct {
+	// Who is the api client who sent the directed message.
+	Who APIClient
+	// RequestID is chosen by the api client to match the acknowledgement.
+	RequestID   uint16
+	Destination Identity
+	// TTL is the requested time to live. If 0, then the default maxTTL will be used.
+	TTL      uint8
+	WantAck  bool
+	DataType GossipItemDataType
+	Data     []byte
+}
+
+// APIDirectReceiveMSGPayload is the payload type of an InternalMessage
+// with type APIDirectReceiveMSG.
+type APIDirectReceiveMSGPayload GossipDirectNotificationMSGPayload
+
+// APIDirectAckMSGPayload is the payload type of an InternalMessage
+// with type APIDirectAckMSG.
+type APIDirectAckMSGPayload struct {
+	// Who is the api client who sent the acknowledged directed message.
+	Who       APIClient
+	RequestID uint16
+	// From is the identity of the node who acknowledged the delivery.
+	From Identity
+}
+
+// APIAdminBanMSGPayload is the payload type of an InternalMessage
+// with type APIAdminBanMSG.
+type APIAdminBanMSGPayload struct {
+	// Entry is either a hex encoded identity, an IP address or a CIDR network.
+	Entry string
+	// Expires is the time the ban expires. It is zero if the ban never expires.
+	Expires time.Time
+}
+
+// APIAdminUnbanMSGPayload is the payload type of an InternalMessage
+// with type APIAdminUnbanMSG.
+type APIAdminUnbanMSGPayload struct {
+	// Entry is either a hex encoded identity, an IP address or a CIDR network.
+	Entry string
+}
+
+// APIAggregateEstimateMSGPayload is the payload type of an InternalMessage
+// with type APIAggregateEstimateMSG.
+type APIAggregateEstimateMSGPayload GossipAggregateEstimateMSGPayload
+
+// APIPeerSubscribeMSGPayload is the payload type of an InternalMessage
+// with type APIPeerSubscribeMSG.
+type APIPeerSubscribeMSGPayload struct {
+	// Who is the api client who (un)subscribes.
+	Who APIClient
+	// Subscribe is true iff the api client wants to receive the peer
+	// lifecycle events, and false iff it does not want them anymore.
+	Subscribe bool
+}
+
+// APIPeerEventMSGPayload is the payload type of an InternalMessage
+// with type APIPeerEventMSG.
+type APIPeerEventMSGPayload struct {
+	Event PeerEventType
+	// Peer is the identity of the neighbour for PeerUp and PeerDown
+	// events. It is the zero identity for the other events.
+	Peer Identity
+	// Neighbours is the number of neighbours after the event.
+	Neighbours uint16
+}
+
+// APIRPSPeerMSGPayload is the payload type of an InternalMessage
+// with type APIRPSPeerMSG.
+type APIRPSPeerMSGPayload MembershipRPSPeerMSGPayload
@@ -5,12 +5,13 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"gossip/src/crypto/securecomm"
 	"gossip/src/datastruct/indexedmap"
 	"gossip/src/datastruct/set"
+	"gossip/src/utils"
 	"log"
-	"math"
 	mrand "math/rand"
 	"net"
 	"os"
@@ -30,7 +31,9 @@ func init() {
 	// Register all of the event handler methods.
 	centralControllerHandlers[PeerAddMSG] = (*CentralController).peerAddHandler
 	centralControllerHandlers[PeerRemoveMSG] = (*CentralController).peerRemoveHandler
-	centralControllerHandlers[ProbePeerRequestMSG] = (*CentralController).probePeerRequestHandler
+	centralControllerHandlers[SWIMPingMSG] = (*CentralController).swimPingHandler
+	centralControllerHandlers[SWIMAckMSG] = (*CentralController).swimAckHandler
+	centralControllerHandlers[SWIMPingRequestMSG] = (*CentralController).swimPingRequestHandler
 	centralControllerHandlers[MembershipPushRequestMSG] = (*CentralController).membershipPushRequestHandler
 	centralControllerHandlers[MembershipPullRequestMSG] = (*CentralController).membershipPullRequestHandler
 	centralControllerHandlers[MembershipPullReplyMSG] = (*CentralController).membershipPullReplyHandler
@@ -60,6 +63,22 @@ func init() {
 	centralControllerHandlers[CentralCloseMSG] = (*CentralController).closeHandler
 	centralControllerHandlers[IncomingAPIMSG] = (*CentralController).incomingAPIHandler
 	centralControllerHandlers[IncomingP2PMSG] = (*CentralController).incomingP2PHandler
+	centralControllerHandlers[PeerBanMSG] = (*CentralController).peerBanHandler
+	centralControllerHandlers[MembershipRPSPeerMSG] = (*CentralController).membershipRPSPeerHandler
+	centralControllerHandlers[GossipPeerMisbehavedMSG] = (*CentralController).gossipPeerMisbehavedHandler
+	centralControllerHandlers[P2PEndpointMalformedMSG] = (*CentralController).p2pEndpointMalformedHandler
+	centralControllerHandlers[GossipTombstonePushMSG] = (*CentralController).gossipTombstonePushHandler
+	centralControllerHandlers[GossipRetractionMSG] = (*CentralController).gossipRetractionHandler
+	centralControllerHandlers[GossipKVValueMSG] = (*CentralController).gossipKVValueHandler
+	centralControllerHandlers[GossipKVDigestMSG] = (*CentralController).gossipKVDigestHandler
+	centralControllerHandlers[GossipKVDigestReplyMSG] = (*CentralController).gossipKVDigestReplyHandler
+	centralControllerHandlers[GossipKVUpdateMSG] = (*CentralController).gossipKVUpdateHandler
+	centralControllerHandlers[GossipDirectNotificationMSG] = (*CentralController).gossipDirectNotificationHandler
+	centralControllerHandlers[GossipAggregatePushMSG] = (*CentralController).gossipAggregatePushHandler
+	centralControllerHandlers[GossipAggregateEstimateMSG] = (*CentralController).gossipAggregateEstimateHandler
+	centralControllerHandlers[GossipNetworkSizeMSG] = (*CentralController).gossipNetworkSizeHandler
+	centralControllerHandlers[PeerIdentifyMSG] = (*CentralController).peerIdentifyHandler
+	centralControllerHandlers[PeerIdentifiedMSG] = (*CentralController).peerIdentifiedHandler
 
 	// Create a set of valid event types while the Central controller is stopping.
 	centralControllerStopMessages = set.New().Add(PeerRemoveMSG).
@@ -80,13 +99,18 @@ type CentralControllerState struct {
 }
 
 // CentralControllerViewListKeyType is the key type of CentralController::viewList.
-type CentralControllerViewListKeyType Peer
+type CentralControllerViewListKeyType Identity
 
 // CentralControllerViewListValueType is the value type of CentralController::viewList.
 type CentralControllerViewListValueType *PeerInfoCentral
 
 // CentralController contains core logic of the gossip module.
 //
+// NOTE: There is a single connection between any pair of peers, which serves
+// both directions. If the connection was started by the remote peer, then its
+// *PeerInfoCentral is shared between 'incomingViewList' and either 'viewList'
+// or 'awaitingRemovalViewList', and it is only closed by the remote peer.
+//
 // NOTE: An outgoing p2p endpoint can be removed iff:
 // (both reader and writer goroutines have stopped) AND
 // (
@@ -99,38 +123,85 @@ type CentralControllerViewListValueType *PeerInfoCentral
 type CentralController struct {
 	// p2pConfig contains the trusted identity path and RSA key information.
 	p2pConfig *securecomm.Config
-	// bootstrapper is the TCP\IP address of the bootstrapping peer.
-	bootstrapper string
-	// apiAddr is the TCP\IP address to listen for incoming API connections.
-	apiAddr string
-	// p2pAddr is the TCP\IP address to listen for incoming P2P connections.
-	p2pAddr string
+	// bootstrapper is the list of TCP\IP addresses of the bootstrapping peer.
+	bootstrapper []string
+	// apiAddrs are the TCP\IP addresses to listen for incoming API connections.
+	apiAddrs []string
+	// p2pAddrs are the TCP\IP addresses to listen for incoming P2P connections.
+	p2pAddrs []string
+	// advertiseAddrs are the TCP\IP addresses the other peers use for connecting to
+	// this peer. They are what this peer advertises in the membership messages.
+	advertiseAddrs []string
 	// apiListener is the api listener goroutine
 	apiListener *APIListener
 	// apiListener is the p2p listener goroutine
 	p2pListener *P2PListener
-	// viewList is the current map of (Peer, *PeerInfoCentral) pairs for gossiping. It is of size O(n^0.25).
+	// viewList is the current map of (Identity, *PeerInfoCentral) pairs for gossiping. It is of size O(n^0.25).
+	// Every map of peers below is keyed by the identities of the peers, never by their addresses.
 	viewList *indexedmap.IndexedMap
-	// awaitingRemovalViewList is the current map of (Peer, *PeerInfoCentral) pairs awaiting deletion.
+	// awaitingRemovalViewList is the current map of (Identity, *PeerInfoCentral) pairs awaiting deletion.
 	// As soon as the 'usageCounter' of a peer in this map reaches 0, they are removed.
-	awaitingRemovalViewList map[Peer]*PeerInfoCentral
+	awaitingRemovalViewList map[Identity]*PeerInfoCentral
 	// activelyCreatedPeers is a set of Peers which are currently being created and cannot
 	// be removed yet with a PeerRemoveMSG. If peer remove command for such a Peer arrives,
 	// then the value of that peer is set to 'true', otherwise it is by default 'false'.
-	activelyCreatedPeers map[Peer]bool
+	activelyCreatedPeers map[Identity]bool
 	// activelyProbedPeers is a set of Peers which are currently being probed and cannot
 	// be opened connection with a PeerAddMSG. If peer add command for such a Peer arrives,
 	// then the value of that peer is set to 'true', otherwise it is by default 'false'.
-	activelyProbedPeers map[Peer]bool
-	// incomingViewList is the current map of (Peer, *PeerInfoCentral) pairs where the remote
-	// peer is the one who started the communication. Note that NO peer may use their
-	// P2P listen address (IP, port) pair for starting a communication with another peer.
-	// So, it is safe to start a communication to a peer that is inside 'incomingViewList'.
-	incomingViewList    map[Peer]*PeerInfoCentral
+	activelyProbedPeers map[Identity]bool
+	// pendingPings holds the pings to the peers which are being either created
+	// or probed, until the creation or the probing is done.
+	pendingPings map[Identity][]SWIMPingMSGPayload
+	// incomingViewList is the current map of (Identity, *PeerInfoCentral) pairs where the remote
+	// peer is the one who started the communication. A peer that is inside 'incomingViewList'
+	// is added to the view list by sharing its connection instead of starting another one.
+	// The advertised addresses of the peers in 'incomingViewList' are unknown.
+	incomingViewList    map[Identity]*PeerInfoCentral
 	incomingViewListMAX uint16
+	// retiredPeers is a map of p2p endpoints, which were replaced by another connection
+	// with the same peer, to their infos. They are removed as soon as they are stopped.
+	retiredPeers map[*P2PEndpoint]*PeerInfoCentral
+	// banList is the list of banned identities, IP addresses and CIDR networks,
+	// either configured, banned by an admin or banned by the Membership controller.
+	// It is shared with the secure listeners, the handshakes and the Membership
+	// controller. The connections of the banned peers are refused.
+	banList *securecomm.BanList
+	// diversity is the policy for the diversity of the peers. Only its subnet
+	// constraints apply to the incoming p2p endpoints.
+	diversity DiversityPolicy
+	// diversityStats counts the incoming p2p endpoints refused by the diversity policy.
+	diversityStats DiversityStats
+	// neighbours is the set of identities of the connected peers, as last
+	// announced to the API clients subscribed to the peer lifecycle events.
+	neighbours map[Identity]bool
 	// apiClients is a map of currently active API client connections.
 	apiClients    map[APIClient]*APIClientInfoCentral
 	apiClientsMAX uint16
+	// identity is the identity of this node.
+	identity Identity
+	// directMaxTTL is the maximum number of hops a directed message may travel.
+	directMaxTTL uint8
+	// configuredMaxTTL is the maximum TTL given by the configuration. If it
+	// is 0, then directMaxTTL is derived from the estimated network size.
+	configuredMaxTTL uint8
+	// sizeParams are the parameters derived from the estimated network size.
+	// The Gossiper derives them again when the estimate changes enough.
+	sizeParams NetworkSizeParams
+	// directSeen is a map of the directed messages routed recently to the time
+	// they were first seen. Any directed message in this map is not routed again.
+	directSeen map[DirectMessageID]time.Time
+	// directAwaitingAck is a map of the directed messages sent by API clients
+	// of this node to the API clients waiting for their acknowledgements.
+	directAwaitingAck map[DirectMessageID]*DirectAckInfoCentral
+	// peerLeavesSeen is a map of the identities of the peers who announced to
+	// leave recently to the time of their announcements. Any announcement which
+	// is not newer than the one in this map is not forwarded again.
+	peerLeavesSeen map[Identity]time.Time
+	// tombstonesSeen is a map of the gossip items retracted by a valid tombstone
+	// to the time the tombstone was issued. The tombstones of these items are not
+	// verified again, since every peer pushes them for several gossip rounds.
+	tombstonesSeen map[GossipItemID]time.Time
 	// membershipController is the variable holding all the necessary variables
 	// to communicate with the Membership controller goroutine.
 	membershipController *MembershipController
@@ -158,96 +229,228 @@ const (
 	connectionTimeout       = 2 * time.Second
 	closureTimeout          = 6 * time.Second
 	closureCheckTimeout     = 500 * time.Millisecond
+	tombstoneRetention      = 1 * time.Hour
+	// tombstoneClockSkew is the maximum time a valid tombstone may be issued
+	// in the future, allowing for the clock skew between the peers.
+	tombstoneClockSkew    = 1 * time.Minute
+	directMessageLifetime = 1 * time.Minute
+	// peerLeaveTTL is the number of times a leave announcement is forwarded,
+	// so that it only reaches the neighbourhood of the peer who left.
+	peerLeaveTTL = 1
+	// peerLeaveLifetime is the maximum age of a valid leave announcement.
+	peerLeaveLifetime = 1 * time.Minute
+	// peerHistoryCap is the maximum number of peers remembered for healing a partition.
+	peerHistoryCap = 256
+	// defaultSamplerValidationInterval is the sampler validation interval
+	// used when it is not configured.
+	defaultSamplerValidationInterval = 300 * time.Second
+	// samplerValidationAttempts is the number of consecutive validation pings
+	// a sampled peer has to miss, before its samplers are reinitialised.
+	samplerValidationAttempts = 3
+	// maxPendingPings is the maximum number of pings held back for a peer,
+	// while a connection to it is being either created or probed.
+	maxPendingPings = 4
+	// maxPeerAddrs is the maximum number of addresses a peer may advertise.
+	maxPeerAddrs = 4
+	// initialNetworkSize is the network size the parameters are derived from
+	// until the aggregation estimates the network size for the first time.
+	initialNetworkSize = 1e4
+	// minNetworkSize is the smallest network size the parameters are derived from.
+	minNetworkSize = 16
+	// minViewListCap is the smallest capacity of the viewList, so that
+	// BRAHMS has room for pushed, pulled and sampled peers in small networks.
+	minViewListCap = 8
+	// networkSizeHysteresis is the factor by which the estimated network
+	// size has to change, before the parameters are derived from it again.
+	networkSizeHysteresis = 2
 )
 
+// CentralControllerConfig is the struct for configuration parameters of
+// a CentralController. Every address is either IPv4 or IPv6, and every address
+// setting is a list, since a node may listen on and be reachable at several addresses.
+type CentralControllerConfig struct {
+	// TrustedIdentitiesPath is the path to the folder containing the
+	// empty files whose names are hex encoded 'identity' of the trusted peers.
+	// This folder HAS TO contain the identity of the 'bootstrapper' !!!
+	TrustedIdentitiesPath string
+	// HostKeyPath is the path to the .pem file of the host key.
+	HostKeyPath string
+	// PubKeyPath is the path to the .pem file of the public key of the host key.
+	PubKeyPath string
+	// Bootstrapper are the addresses of the bootstrapper, which are tried in order.
+	// The bootstrapper does not have to be reachable at startup, it is
+	// identified and contacted once it is reachable.
+	Bootstrapper []string
+	// APIAddrs are the addresses the API is listened on.
+	APIAddrs []string
+	// P2PAddrs are the addresses the P2P connections are listened on.
+	P2PAddrs []string
+	// AdvertiseAddrs are the addresses advertised to the other peers. If empty,
+	// then they are detected from P2PAddrs and the outbound IP addresses.
+	AdvertiseAddrs []string
+	// StaticPeers are the peers that are always kept in the view list, in the form
+	// "<hex identity>@<address>". A peer with several addresses is given by an
+	// entry per address.
+	StaticPeers []string
+	// BanListPath is the file the ban list is persisted in. It is not persisted if empty.
+	BanListPath string
+	// BannedPeers are the identities, IP addresses and CIDR networks
+	// banned permanently by the configuration.
+	BannedPeers []string
+	// PeerHistoryPath is the file the long-lived set of the peers seen in the
+	// view list is persisted in, which is used for healing a partition of the
+	// network even after a restart. It is not persisted if empty.
+	PeerHistoryPath string
+	// CipherSuites are the cipher suites offered and accepted in the handshakes
+	// of the P2P connections in the order of preference. The default is used if empty.
+	CipherSuites []securecomm.CipherSuite
+	// RekeyBytes and RekeyInterval are the number of bytes sent and the time
+	// duration, after which the key of a direction of a P2P connection is
+	// ratcheted forward. If one of them is 0, then its default is used.
+	RekeyBytes    uint64
+	RekeyInterval time.Duration
+	// CacheSize is the number of gossip items cached, which
+	// is also the maximum number of API clients.
+	CacheSize uint16
+	// Degree is the number of peers a gossip item is sent to in a round.
+	Degree uint8
+	// MaxTTL is the maximum number of hops to propagate a gossip item
+	// or a directed message. If it is 0, then it is derived from the estimated
+	// network size, like the sizes of the view list and the sample list.
+	MaxTTL uint8
+	// SamplerValidationInterval is the time duration between each
+	// validation of the sampled peers. If it is 0, then a default is used.
+	SamplerValidationInterval time.Duration
+	// Diversity is the policy for the diversity of the addresses of the
+	// peers in the view list and of the incoming connections.
+	Diversity DiversityPolicy
+	// Policies are the gossiping rules of the data types.
+	Policies map[GossipItemDataType]GossipDataTypePolicy
+}
+
 // NewCentralController is a constructor function for the centralController class.
-//
-// trustedIdentitiesPath parameter is the path to the folder containing the
-// empty files whose names are hex encoded 'identity' of the trusted peers.
-// This folder HAS TO contain the identity of the 'bootstrapper' !!!
-func NewCentralController(
-	trustedIdentitiesPath, hostKeyPath, pubKeyPath, bootstrapper, apiAddr, p2pAddr string,
-	cacheSize uint16, degree, maxTTL uint8,
-) (*CentralController, error) {
+func NewCentralController(config CentralControllerConfig) (*CentralController, error) {
 	// Check the validity of trusted identities path
-	s, err := os.Stat(trustedIdentitiesPath)
+	s, err := os.Stat(config.TrustedIdentitiesPath)
 	if os.IsNotExist(err) {
 		return nil, err
 	} else if !s.IsDir() {
-		return nil, fmt.Errorf("trustedIdentitiesPath is not a directory: %q", trustedIdentitiesPath)
+		return nil, fmt.Errorf("trustedIdentitiesPath is not a directory: %q", config.TrustedIdentitiesPath)
 	}
 	// Check the validity of host key path (.pem file expected)
-	s, err = os.Stat(hostKeyPath)
+	s, err = os.Stat(config.HostKeyPath)
 	if os.IsNotExist(err) {
 		return nil, err
 	} else if s.IsDir() {
-		return nil, fmt.Errorf("hostKeyPath is a directory: %q", hostKeyPath)
+		return nil, fmt.Errorf("hostKeyPath is a directory: %q", config.HostKeyPath)
 	}
 	// Check the validity of each TCP\IP address provided
-	_, err = net.ResolveTCPAddr("tcp", bootstrapper)
-	if err != nil && bootstrapper != "" {
-		return nil, err
+	if len(config.APIAddrs) == 0 || len(config.P2PAddrs) == 0 {
+		return nil, fmt.Errorf("at least one API and one P2P listen address is required")
+	}
+	for _, addr := range append(append(append([]string{}, config.Bootstrapper...), config.APIAddrs...), config.P2PAddrs...) {
+		_, err = net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// Get the outbound ip address for TCP/UDP connections.
-	// ipAddr, err := utils.GetOutboundIP()
-	// if err != nil {
-	// 	return nil, err
-	// }
-	_, err = net.ResolveTCPAddr("tcp", apiAddr)
+	config.AdvertiseAddrs, err = resolveAdvertiseAddrs(config.AdvertiseAddrs, config.P2PAddrs)
 	if err != nil {
 		return nil, err
 	}
-	// apiAddr = fmt.Sprintf("%s:%d", ipAddr, addr.Port)
-	_, err = net.ResolveTCPAddr("tcp", p2pAddr)
+	statics, err := parseStaticPeers(config.StaticPeers)
 	if err != nil {
 		return nil, err
 	}
-	// p2pAddr = fmt.Sprintf("%s:%d", ipAddr, addr.Port)
 	// Check the validity of the integer arguments
-	if cacheSize == 0 || degree == 0 || degree > 10 {
-		return nil, fmt.Errorf("invalid CentralController arguments, 'cache_size': %d, 'degree': %d", cacheSize, degree)
+	if config.CacheSize == 0 || config.Degree == 0 || config.Degree > 10 {
+		return nil, fmt.Errorf("invalid CentralController arguments, 'cache_size': %d, 'degree': %d", config.CacheSize, config.Degree)
 	}
 
-	if maxTTL == 0 {
-		maxTTL = uint8(math.Ceil(math.Log2(maxPeers) / math.Log2(math.Max(2, float64(degree)))))
+	if config.SamplerValidationInterval == 0 {
+		config.SamplerValidationInterval = defaultSamplerValidationInterval
+	}
+	// Until the network size is estimated, the parameters depending on
+	// it are derived from an initial guess.
+	sizeParams := NewNetworkSizeParams(initialNetworkSize, config.Degree)
+	directMaxTTL := config.MaxTTL
+	if directMaxTTL == 0 {
+		directMaxTTL = sizeParams.MaxTTL
 	}
-	viewListCap := uint16(math.Max(1, math.Floor(math.Pow(maxPeers, 0.25))))
 	centralController := CentralController{
-		bootstrapper:            bootstrapper,
-		apiAddr:                 apiAddr,
-		p2pAddr:                 p2pAddr,
+		bootstrapper:            config.Bootstrapper,
+		apiAddrs:                config.APIAddrs,
+		p2pAddrs:                config.P2PAddrs,
+		advertiseAddrs:          config.AdvertiseAddrs,
 		viewList:                indexedmap.New(),
-		awaitingRemovalViewList: map[Peer]*PeerInfoCentral{},
-		activelyCreatedPeers:    map[Peer]bool{},
-		activelyProbedPeers:     map[Peer]bool{},
-		incomingViewList:        map[Peer]*PeerInfoCentral{},
-		incomingViewListMAX:     2 * viewListCap,
+		awaitingRemovalViewList: map[Identity]*PeerInfoCentral{},
+		activelyCreatedPeers:    map[Identity]bool{},
+		activelyProbedPeers:     map[Identity]bool{},
+		pendingPings:            map[Identity][]SWIMPingMSGPayload{},
+		incomingViewList:        map[Identity]*PeerInfoCentral{},
+		incomingViewListMAX:     2 * sizeParams.ViewListCap,
+		retiredPeers:            map[*P2PEndpoint]*PeerInfoCentral{},
+		diversity:               config.Diversity,
+		diversityStats:          DiversityStats{},
+		neighbours:              map[Identity]bool{},
 		apiClients:              map[APIClient]*APIClientInfoCentral{},
-		apiClientsMAX:           cacheSize,
+		apiClientsMAX:           config.CacheSize,
+		directMaxTTL:            directMaxTTL,
+		configuredMaxTTL:        config.MaxTTL,
+		sizeParams:              sizeParams,
+		directSeen:              map[DirectMessageID]time.Time{},
+		directAwaitingAck:       map[DirectMessageID]*DirectAckInfoCentral{},
+		peerLeavesSeen:          map[Identity]time.Time{},
+		tombstonesSeen:          map[GossipItemID]time.Time{},
 		MsgInQueue:              make(chan InternalMessage, inQueueSize),
 	}
 	// Create a P2P secure config.
-	p2pConfig, err := securecomm.NewConfig(trustedIdentitiesPath, hostKeyPath, pubKeyPath, cacheSize)
+	p2pConfig, err := securecomm.NewConfig(config.TrustedIdentitiesPath, config.HostKeyPath, config.PubKeyPath, config.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	p2pConfig.AdvertisedAddrs = config.AdvertiseAddrs
+	banList, err := securecomm.NewBanList(config.BanListPath, config.BannedPeers)
 	if err != nil {
 		return nil, err
 	}
+	p2pConfig.BanList = banList
+	if len(config.CipherSuites) != 0 {
+		p2pConfig.CipherSuites = config.CipherSuites
+	}
+	if config.RekeyBytes != 0 {
+		p2pConfig.RekeyBytes = config.RekeyBytes
+	}
+	if config.RekeyInterval != 0 {
+		p2pConfig.RekeyInterval = config.RekeyInterval
+	}
+	history, err := NewPeerHistory(config.PeerHistoryPath, peerHistoryCap)
+	if err != nil {
+		return nil, err
+	}
+	centralController.banList = banList
 	centralController.p2pConfig = p2pConfig
+	if centralController.identity, err = IdentityOf(p2pConfig.HostKey.Public()); err != nil {
+		return nil, err
+	}
 
-	apiListener, err := NewAPIListener(apiAddr, centralController.MsgInQueue)
+	apiListener, err := NewAPIListener(config.APIAddrs, centralController.MsgInQueue)
 	if err != nil {
 		return nil, err
 	}
 	centralController.apiListener = apiListener
 
 	// Create a new p2p listener.
-	p2pListener, err := NewP2PListener(p2pAddr, centralController.MsgInQueue, centralController.p2pConfig)
+	p2pListener, err := NewP2PListener(config.P2PAddrs, centralController.MsgInQueue, centralController.p2pConfig)
 	if err != nil {
 		return nil, err
 	}
 	centralController.p2pListener = p2pListener
 	// Create a new Membership controller.
 	membershipController, err := NewMembershipController(
-		bootstrapper, p2pAddr, alpha, beta, membershipRoundDuration, maxPeers, viewListCap,
+		Peer{Addrs: config.Bootstrapper}, Peer{ID: centralController.identity, Addrs: config.AdvertiseAddrs}, statics, p2pConfig.HostKey, banList,
+		history, config.Diversity, alpha, beta,
+		membershipRoundDuration, config.SamplerValidationInterval, sizeParams,
 		make(chan InternalMessage, outQueueSize), centralController.MsgInQueue,
 	)
 	if err != nil {
@@ -256,8 +459,8 @@ func NewCentralController(
 	centralController.membershipController = membershipController
 	// Create a new Gossiper.
 	gossiper, err := NewGossiper(
-		cacheSize, degree, maxTTL, gossipRoundDuration, maxPeers,
-		make(chan InternalMessage, outQueueSize), centralController.MsgInQueue,
+		config.CacheSize, config.Degree, config.MaxTTL, gossipRoundDuration, sizeParams, config.Policies,
+		centralController.identity, p2pConfig.HostKey, make(chan InternalMessage, outQueueSize), centralController.MsgInQueue,
 	)
 	if err != nil {
 		return nil, err
@@ -267,6 +470,48 @@ func NewCentralController(
 	return &centralController, nil
 }
 
+// resolveAdvertiseAddrs returns the addresses to be advertised to the other peers.
+// Explicitly configured addresses have to be (ip, port) pairs with specific IP
+// addresses. Otherwise the listen addresses are used, except the ones bound to
+// all interfaces, which are replaced by the IP addresses of the outbound interfaces.
+func resolveAdvertiseAddrs(advertiseAddrs, p2pAddrs []string) ([]string, error) {
+	if len(advertiseAddrs) == 0 {
+		for _, p2pAddr := range p2pAddrs {
+			addr, err := net.ResolveTCPAddr("tcp", p2pAddr)
+			if err != nil {
+				return nil, err
+			}
+			if addr.IP != nil && !addr.IP.IsUnspecified() {
+				advertiseAddrs = append(advertiseAddrs, addr.String())
+				continue
+			}
+			port := fmt.Sprint(addr.Port)
+			// A socket bound to all IPv6 interfaces also accepts IPv4 connections.
+			if addr.IP == nil || addr.IP.To4() == nil {
+				if ipAddr, err := utils.GetOutboundIPv6(); err == nil {
+					advertiseAddrs = append(advertiseAddrs, net.JoinHostPort(ipAddr, port))
+				}
+			}
+			// Get the outbound ip address for TCP/UDP connections.
+			if ipAddr, err := utils.GetOutboundIP(); err == nil {
+				advertiseAddrs = append(advertiseAddrs, net.JoinHostPort(ipAddr, port))
+			}
+		}
+		if len(advertiseAddrs) == 0 {
+			return nil, fmt.Errorf("cannot detect the advertise address, set 'advertise_address'")
+		}
+	}
+	if len(advertiseAddrs) > maxPeerAddrs {
+		return nil, fmt.Errorf("at most %d advertise addresses are allowed", maxPeerAddrs)
+	}
+	for _, advertiseAddr := range advertiseAddrs {
+		if addr, err := utils.ParseIPPort(advertiseAddr); err != nil || addr.IP.IsUnspecified() {
+			return nil, fmt.Errorf("advertise address has to contain a specific IP address: %q", advertiseAddr)
+		}
+	}
+	return advertiseAddrs, nil
+}
+
 // recover method tries to catch a panic in the Run method. If there is a
 // panic, it logs and closes all submodules as soon as possible.
 func (centralController *CentralController) recover() {
@@ -290,37 +535,52 @@ func (centralController *CentralController) recover() {
 // peerAddHandler is the method called by the Run method for when
 // it receives an internal message of type PeerAddMSG.
 func (centralController *CentralController) peerAddHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	peer, ok := payload.(Peer)
 	if !ok {
 		return nil
 	}
 	// If the peer is already in the view list, then nothing more to do.
-	if centralController.viewList.IsMember(peer) {
+	if centralController.viewList.IsMember(peer.ID) {
+		return nil
+	}
+	// If the peer is banned, then refuse it and let the
+	// Membership controller remove it from its viewList.
+	if centralController.isBanned(peer) {
+		log.Println("Central controller: refusing to add the banned peer", peer.ID)
+		log.Println("Central controller -> Membership controller, PeerDisconnectedMSG,", peer)
+		centralController.membershipController.MsgInQueue <- InternalMessage{
+			Type: PeerDisconnectedMSG, Payload: peer}
 		return nil
 	}
 	// If the peer is in the removal view list, then move it back to the view list.
-	if info, isMember := centralController.awaitingRemovalViewList[peer]; isMember {
-		delete(centralController.awaitingRemovalViewList, peer)
-		centralController.viewList.Put(peer, info)
+	if info, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
+		delete(centralController.awaitingRemovalViewList, peer.ID)
+		centralController.viewList.Put(peer.ID, info)
+		return nil
+	}
+	// If the peer has already connected to us, then share its connection.
+	if info, isMember := centralController.incomingViewList[peer.ID]; isMember {
+		centralController.viewList.Put(peer.ID, info)
 		return nil
 	}
 	// If the peer is already being created, then
 	// signal it to be not removed later.
-	if _, isMember := centralController.activelyCreatedPeers[peer]; isMember {
-		centralController.activelyCreatedPeers[peer] = false
+	if _, isMember := centralController.activelyCreatedPeers[peer.ID]; isMember {
+		centralController.activelyCreatedPeers[peer.ID] = false
 		return nil
 	}
 	// If the peer is currently being probed, then signal it to be added later.
-	if _, isMember := centralController.activelyProbedPeers[peer]; isMember {
-		centralController.activelyProbedPeers[peer] = true
+	if _, isMember := centralController.activelyProbedPeers[peer.ID]; isMember {
+		centralController.activelyProbedPeers[peer.ID] = true
 		return nil
 	}
 	// Register this peer as an actively created peer to not be removed later.
-	centralController.activelyCreatedPeers[peer] = false
+	centralController.activelyCreatedPeers[peer.ID] = false
 	// Create an endpoint for the outgoing p2p connection.
 	go func(peer Peer) {
 		endp, _ := NewP2PEndpoint(
-			peer.Addr, centralController.p2pConfig,
+			peer, centralController.p2pConfig,
 			make(chan InternalMessage, outQueueSize),
 			centralController.MsgInQueue, true)
 		log.Println("Central controller -> Central controller, OutgoingP2PCreatedMSG,", endp)
@@ -333,73 +593,82 @@ func (centralController *CentralController) peerAddHandler(payload AnyMessage) e
 // peerRemoveHandler is the method called by the Run method for when
 // it receives an internal message of type PeerRemoveMSG.
 func (centralController *CentralController) peerRemoveHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	peer, ok := payload.(Peer)
 	if !ok {
 		return nil
 	}
 	// If the peer is in the removal view list, then nothing more to do.
-	if _, isMember := centralController.awaitingRemovalViewList[peer]; isMember {
+	if _, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
 		return nil
 	}
 	// If the peer is in the view list, then send a command to close.
-	if centralController.viewList.IsMember(peer) {
-		value := centralController.viewList.GetValue(peer)
+	if centralController.viewList.IsMember(peer.ID) {
+		value := centralController.viewList.GetValue(peer.ID)
 		info := value.(*PeerInfoCentral)
-		centralController.viewList.Remove(peer)
-		centralController.awaitingRemovalViewList[peer] = info
+		centralController.viewList.Remove(peer.ID)
+		centralController.awaitingRemovalViewList[peer.ID] = info
 		// If there is no gossip item using this outgoing peer.
 		if info.usageCounter <= 0 {
-			// If the p2p endpoint has already stopped.
-			if info.state.HaveBothStopped() {
-				delete(centralController.awaitingRemovalViewList, peer)
-			} else {
-				info.endpoint.Close()
-			}
+			centralController.releaseRemovedPeer(info)
 		}
 		return nil
 	}
 	// If the peer is currently being created, signal it to be removed later.
-	if _, isMember := centralController.activelyCreatedPeers[peer]; isMember {
-		centralController.activelyCreatedPeers[peer] = true
+	if _, isMember := centralController.activelyCreatedPeers[peer.ID]; isMember {
+		centralController.activelyCreatedPeers[peer.ID] = true
 		return nil
 	}
 	// If the peer is currently being probed, then signal it to be not added later.
-	if _, isMember := centralController.activelyProbedPeers[peer]; isMember {
-		centralController.activelyProbedPeers[peer] = false
+	if _, isMember := centralController.activelyProbedPeers[peer.ID]; isMember {
+		centralController.activelyProbedPeers[peer.ID] = false
 		return nil
 	}
 
 	return nil
 }
 
-// probePeerRequestHandler is the method called by the Run method for when
-// it receives an internal message of type ProbePeerRequestMSG.
-func (centralController *CentralController) probePeerRequestHandler(payload AnyMessage) error {
-	peer, ok := payload.(Peer)
+// swimPingHandler is the method called by the Run method for when
+// it receives an internal message of type SWIMPingMSG.
+func (centralController *CentralController) swimPingHandler(payload AnyMessage) error {
+	ping, ok := payload.(SWIMPingMSGPayload)
 	if !ok {
 		return nil
 	}
-	// Check if the peer is already in either the view list or the removal view list
-	// or the actively created peer list.
-	_, isMember := centralController.awaitingRemovalViewList[peer]
-	_, isMember2 := centralController.activelyCreatedPeers[peer]
-	if centralController.viewList.IsMember(peer) || isMember || isMember2 {
-		payload := ProbePeerReplyMSGPayload{Probed: peer, ProbeResult: true}
-		log.Println("Central controller -> Membership controller, ProbePeerReplyMSG,", payload)
-		centralController.membershipController.MsgInQueue <- InternalMessage{
-			Type:    ProbePeerReplyMSG,
-			Payload: payload,
+	peer := ping.To
+	// If there is a connection with the peer, then ping it over the connection.
+	info, _ := centralController.viewListInfo(peer.ID)
+	_, isMember := centralController.incomingViewList[peer.ID]
+	if info != nil || isMember {
+		centralController.sendToPeer(peer, InternalMessage{Type: SWIMPingMSG, Payload: ping})
+		return nil
+	}
+	// If the peer is banned, then drop the ping.
+	if centralController.isBanned(peer) {
+		return nil
+	}
+	// If the peer is being either created or probed, then hold the ping back
+	// until it is done. If too many pings are held back, then drop the oldest
+	// one and let the failure detector resort to indirect pings.
+	_, isMember = centralController.activelyCreatedPeers[peer.ID]
+	_, isMember2 := centralController.activelyProbedPeers[peer.ID]
+	if isMember || isMember2 {
+		pending := append(centralController.pendingPings[peer.ID], ping)
+		if len(pending) > maxPendingPings {
+			pending = pending[1:]
 		}
+		centralController.pendingPings[peer.ID] = pending
 		return nil
 	}
 	// Register the peer for probing.
-	centralController.activelyProbedPeers[peer] = false
-	// Start a goroutine to probe the peer.
+	centralController.activelyProbedPeers[peer.ID] = false
+	// Start a goroutine to ping the peer over a short-lived connection.
 	go func(peer Peer) {
-		conn, err := net.DialTimeout("tcp", peer.Addr, connectionTimeout)
-		probeResult := (err == nil)
-		conn.Close()
-		payload := CentralProbePeerReplyMSGPayload{Probed: peer, ProbeResult: probeResult}
+		ack, err := probePeer(peer, ping, centralController.p2pConfig)
+		if err != nil {
+			log.Println("Probing peer", peer.Addrs, "failed:", err)
+		}
+		payload := CentralProbePeerReplyMSGPayload{Probed: peer, Ack: ack}
 		log.Println("Central controller -> Central controller, CentralProbePeerReplyMSG,", payload)
 		centralController.MsgInQueue <- InternalMessage{
 			Type:    CentralProbePeerReplyMSG,
@@ -410,6 +679,66 @@ func (centralController *CentralController) probePeerRequestHandler(payload AnyM
 	return nil
 }
 
+// peerIdentifyHandler is the method called by the Run method for when
+// it receives an internal message of type PeerIdentifyMSG.
+func (centralController *CentralController) peerIdentifyHandler(payload AnyMessage) error {
+	msg, ok := payload.(PeerIdentifyMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Start a goroutine to identify the peer over a short-lived connection.
+	go func(addrs []string) {
+		payload := PeerIdentifiedMSGPayload{Peer: Peer{Addrs: addrs}}
+		id, err := identifyPeer(addrs, centralController.p2pConfig)
+		if err != nil {
+			log.Println("Identifying peer", addrs, "failed:", err)
+		} else {
+			payload.Peer.ID = id
+		}
+		log.Println("Central controller -> Central controller, PeerIdentifiedMSG,", payload)
+		centralController.MsgInQueue <- InternalMessage{Type: PeerIdentifiedMSG, Payload: payload}
+	}(msg.Addrs)
+
+	return nil
+}
+
+// peerIdentifiedHandler is the method called by the Run method for when
+// it receives an internal message of type PeerIdentifiedMSG.
+func (centralController *CentralController) peerIdentifiedHandler(payload AnyMessage) error {
+	msg, ok := payload.(PeerIdentifiedMSGPayload)
+	if !ok {
+		return nil
+	}
+	log.Println("Central controller -> Membership controller, PeerIdentifiedMSG,", msg)
+	centralController.membershipController.MsgInQueue <- InternalMessage{Type: PeerIdentifiedMSG, Payload: msg}
+
+	return nil
+}
+
+// swimAckHandler is the method called by the Run method for when
+// it receives an internal message of type SWIMAckMSG.
+func (centralController *CentralController) swimAckHandler(payload AnyMessage) error {
+	ack, ok := payload.(SWIMAckMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.sendToPeer(ack.To, InternalMessage{Type: SWIMAckMSG, Payload: ack})
+
+	return nil
+}
+
+// swimPingRequestHandler is the method called by the Run method for when
+// it receives an internal message of type SWIMPingRequestMSG.
+func (centralController *CentralController) swimPingRequestHandler(payload AnyMessage) error {
+	pr, ok := payload.(SWIMPingRequestMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.sendToOutgoingPeer(pr.To, InternalMessage{Type: SWIMPingRequestMSG, Payload: pr})
+
+	return nil
+}
+
 // membershipPushRequestHandler  is the method called by the Run method for when
 // it receives an internal message of type MembershipPushRequestMSG.
 func (centralController *CentralController) membershipPushRequestHandler(payload AnyMessage) error {
@@ -420,10 +749,10 @@ func (centralController *CentralController) membershipPushRequestHandler(payload
 	var info *PeerInfoCentral
 	// Check if the peer to be sent is either in the view list or
 	// in the awaiting removal view list.
-	if centralController.viewList.IsMember(pr.To) {
-		value := centralController.viewList.GetValue(pr.To)
+	if centralController.viewList.IsMember(pr.To.ID) {
+		value := centralController.viewList.GetValue(pr.To.ID)
 		info = value.(*PeerInfoCentral)
-	} else if _info, isMember := centralController.awaitingRemovalViewList[pr.To]; isMember {
+	} else if _info, isMember := centralController.awaitingRemovalViewList[pr.To.ID]; isMember {
 		info = _info
 	} else {
 		return nil
@@ -449,10 +778,10 @@ func (centralController *CentralController) membershipPullRequestHandler(payload
 	var info *PeerInfoCentral
 	// Check if the peer to be sent is either in the view list or
 	// in the awaiting removal view list.
-	if centralController.viewList.IsMember(peer) {
-		value := centralController.viewList.GetValue(peer)
+	if centralController.viewList.IsMember(peer.ID) {
+		value := centralController.viewList.GetValue(peer.ID)
 		info = value.(*PeerInfoCentral)
-	} else if _info, isMember := centralController.awaitingRemovalViewList[peer]; isMember {
+	} else if _info, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
 		info = _info
 	} else {
 		return nil
@@ -475,18 +804,57 @@ func (centralController *CentralController) membershipPullReplyHandler(payload A
 	if !ok {
 		return nil
 	}
-	// Check if the peer to be sent is in the incoming view list.
-	info, isMember := centralController.incomingViewList[pr.To]
+	centralController.sendToPeer(pr.To, InternalMessage{Type: MembershipPullReplyMSG, Payload: payload})
+
+	return nil
+}
+
+// gossipNetworkSizeHandler is the method called by the Run method for when
+// it receives an internal message of type GossipNetworkSizeMSG. The new
+// parameters are applied and forwarded to the Membership controller.
+func (centralController *CentralController) gossipNetworkSizeHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipNetworkSizeMSGPayload)
+	if !ok {
+		return nil
+	}
+	params := NetworkSizeParams(msg)
+	centralController.sizeParams = params
+	centralController.incomingViewListMAX = 2 * params.ViewListCap
+	if centralController.configuredMaxTTL == 0 {
+		centralController.directMaxTTL = params.MaxTTL
+	}
+	log.Println("Central controller -> Membership controller, MembershipNetworkSizeMSG,", params)
+	centralController.membershipController.MsgInQueue <- InternalMessage{
+		Type:    MembershipNetworkSizeMSG,
+		Payload: MembershipNetworkSizeMSGPayload(params),
+	}
+
+	return nil
+}
+
+// membershipRPSPeerHandler is the method called by the Run method for when
+// it receives an internal message of type MembershipRPSPeerMSG.
+func (centralController *CentralController) membershipRPSPeerHandler(payload AnyMessage) error {
+	msg, ok := payload.(MembershipRPSPeerMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check if the api client to send the message exists.
+	info, isMember := centralController.apiClients[msg.Who]
 	if !isMember {
 		return nil
 	}
 	// Check if the writer goroutine is running.
-	if info.state.writerState != PeerWriterRUNNING {
+	if info.state.writerState != APIClientWriterRUNNING {
 		return nil
 	}
-	// Send the internal message to the p2p endpoint.
-	log.Println("Central controller -> P2P Endpoint, MembershipPullReplyMSG,", payload)
-	info.endpoint.MsgInQueue <- InternalMessage{Type: MembershipPullReplyMSG, Payload: payload}
+	// Send the internal message to the api endpoint.
+	payload2 := APIRPSPeerMSGPayload(msg)
+	log.Println("Central controller -> API Endpoint, APIRPSPeerMSG,", payload2.Who, payload2.Peer)
+	info.endpoint.MsgInQueue <- InternalMessage{
+		Type:    APIRPSPeerMSG,
+		Payload: payload2,
+	}
 
 	return nil
 }
@@ -537,11 +905,10 @@ func (centralController *CentralController) randomPeerListRequestHandler(payload
 	randomIndexes := mrand.Perm(size)[:mathutils.Min(msg.Num, size)]
 	for _, i := range randomIndexes {
 		key, _ := centralController.viewList.KeyAtIndex(i)
-		peer := key.(Peer)
-		RandomPeers = append(RandomPeers, peer)
-		// Increment the usage counter
 		value := centralController.viewList.GetValue(key)
 		info := value.(*PeerInfoCentral)
+		RandomPeers = append(RandomPeers, info.endpoint.peer)
+		// Increment the usage counter
 		info.usageCounter++
 	}
 	// Send the random list of peers as a response back to the Gossiper submodule.
@@ -569,20 +936,15 @@ func (centralController *CentralController) randomPeerListReleaseHandler(payload
 	// Decrement the usage counters of each peer.
 	for _, peer := range peerList {
 		// Check if the peer is either in the view list or the removal view list.
-		if centralController.viewList.IsMember(peer) {
-			value := centralController.viewList.GetValue(peer)
+		if centralController.viewList.IsMember(peer.ID) {
+			value := centralController.viewList.GetValue(peer.ID)
 			info := value.(*PeerInfoCentral)
 			info.usageCounter--
-		} else if info, isMember := centralController.awaitingRemovalViewList[peer]; isMember {
+		} else if info, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
 			info.usageCounter--
 			// If there is no gossip item using this outgoing peer.
 			if info.usageCounter <= 0 {
-				// If the p2p endpoint has already stopped.
-				if info.state.HaveBothStopped() {
-					delete(centralController.awaitingRemovalViewList, peer)
-				} else {
-					info.endpoint.Close()
-				}
+				centralController.releaseRemovedPeer(info)
 			}
 		} else {
 			// An outgoing p2p endpoint should not have been deleted before
@@ -590,17 +952,623 @@ func (centralController *CentralController) randomPeerListReleaseHandler(payload
 			// a shutdown, this event handler cannot be called, so the code must
 			// have never reached here!
 			// Log this unexpected event.
-			log.Println("Outgoing P2P endpoint", peer.Addr, "was deleted before (usageCounter <= 0).")
+			log.Println("Outgoing P2P endpoint", peer.Addrs, "was deleted before (usageCounter <= 0).")
+		}
+	}
+
+	return nil
+}
+
+// gossipNotificationHandler is the method called by the Run method for when
+// it receives an internal message of type GossipNotificationMSG.
+func (centralController *CentralController) gossipNotificationHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipNotificationMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check if the api client to send the message exists.
+	info, isMember := centralController.apiClients[msg.Who]
+	if !isMember {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != APIClientWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the api endpoint.
+	payload2 := APINotificationMSGPayload{Who: msg.Who, Item: msg.Item, ID: msg.ID}
+	log.Println("Central controller -> API Endpoint, APINotificationMSG,", payload2)
+	info.endpoint.MsgInQueue <- InternalMessage{
+		Type:    APINotificationMSG,
+		Payload: payload2,
+	}
+
+	return nil
+}
+
+// gossipPushHandler is the method called by the Run method for when
+// it receives an internal message of type GossipPushMSG.
+func (centralController *CentralController) gossipPushHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipPushMSGPayload)
+	if !ok {
+		return nil
+	}
+	var info *PeerInfoCentral
+	// Check if the peer to be sent is either in the view list or
+	// in the awaiting removal view list.
+	if centralController.viewList.IsMember(msg.To.ID) {
+		value := centralController.viewList.GetValue(msg.To.ID)
+		info = value.(*PeerInfoCentral)
+	} else if _info, isMember := centralController.awaitingRemovalViewList[msg.To.ID]; isMember {
+		info = _info
+	} else {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != PeerWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the p2p endpoint.
+	log.Println("Central controller -> P2P Endpoint, GossipPushMSG,", payload)
+	info.endpoint.MsgInQueue <- InternalMessage{Type: GossipPushMSG, Payload: payload}
+
+	return nil
+}
+
+// gossipTombstonePushHandler is the method called by the Run method for when
+// it receives an internal message of type GossipTombstonePushMSG.
+func (centralController *CentralController) gossipTombstonePushHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipTombstonePushMSGPayload)
+	if !ok {
+		return nil
+	}
+	var info *PeerInfoCentral
+	// Check if the peer to be sent is either in the view list or
+	// in the awaiting removal view list.
+	if centralController.viewList.IsMember(msg.To.ID) {
+		value := centralController.viewList.GetValue(msg.To.ID)
+		info = value.(*PeerInfoCentral)
+	} else if _info, isMember := centralController.awaitingRemovalViewList[msg.To.ID]; isMember {
+		info = _info
+	} else {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != PeerWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the p2p endpoint.
+	log.Println("Central controller -> P2P Endpoint, GossipTombstonePushMSG,", payload)
+	info.endpoint.MsgInQueue <- InternalMessage{Type: GossipTombstonePushMSG, Payload: payload}
+
+	return nil
+}
+
+// gossipRetractionHandler is the method called by the Run method for when
+// it receives an internal message of type GossipRetractionMSG.
+func (centralController *CentralController) gossipRetractionHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipRetractionMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check if the api client to send the message exists.
+	info, isMember := centralController.apiClients[msg.Who]
+	if !isMember {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != APIClientWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the api endpoint.
+	payload2 := APIRetractionMSGPayload{Who: msg.Who, Tombstone: msg.Tombstone}
+	log.Println("Central controller -> API Endpoint, APIRetractionMSG,", payload2)
+	info.endpoint.MsgInQueue <- InternalMessage{
+		Type:    APIRetractionMSG,
+		Payload: payload2,
+	}
+
+	return nil
+}
+
+// sendToOutgoingPeer is the method for sending the internal message to the p2p
+// endpoint of a peer which is either in the view list or in the awaiting
+// removal view list. The message is dropped if there is no such endpoint.
+// Returns false iff the message is dropped.
+func (centralController *CentralController) sendToOutgoingPeer(peer Peer, im InternalMessage) bool {
+	var info *PeerInfoCentral
+	if centralController.viewList.IsMember(peer.ID) {
+		value := centralController.viewList.GetValue(peer.ID)
+		info = value.(*PeerInfoCentral)
+	} else if _info, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
+		info = _info
+	} else {
+		return false
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != PeerWriterRUNNING {
+		return false
+	}
+	log.Println("Central controller -> P2P Endpoint,", im)
+	info.endpoint.MsgInQueue <- im
+	return true
+}
+
+// sendToPeer is the method for sending the internal message to the p2p endpoint
+// of a peer over any connection with it, preferring the one started by the peer.
+// It is used for replies. The message is dropped if there is no such endpoint.
+func (centralController *CentralController) sendToPeer(peer Peer, im InternalMessage) {
+	info, isMember := centralController.incomingViewList[peer.ID]
+	if !isMember {
+		info, _ = centralController.viewListInfo(peer.ID)
+	}
+	if info == nil {
+		return
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != PeerWriterRUNNING {
+		return
+	}
+	log.Println("Central controller -> P2P Endpoint,", im)
+	info.endpoint.MsgInQueue <- im
+}
+
+// gossipKVValueHandler is the method called by the Run method for when
+// it receives an internal message of type GossipKVValueMSG.
+func (centralController *CentralController) gossipKVValueHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVValueMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check if the api client to send the message exists.
+	info, isMember := centralController.apiClients[msg.Who]
+	if !isMember {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != APIClientWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the api endpoint.
+	payload2 := APIKVValueMSGPayload(msg)
+	log.Println("Central controller -> API Endpoint, APIKVValueMSG,", payload2)
+	info.endpoint.MsgInQueue <- InternalMessage{
+		Type:    APIKVValueMSG,
+		Payload: payload2,
+	}
+
+	return nil
+}
+
+// gossipKVDigestHandler is the method called by the Run method for when
+// it receives an internal message of type GossipKVDigestMSG.
+func (centralController *CentralController) gossipKVDigestHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVDigestMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.sendToOutgoingPeer(msg.To, InternalMessage{Type: GossipKVDigestMSG, Payload: msg})
+
+	return nil
+}
+
+// gossipKVDigestReplyHandler is the method called by the Run method for when
+// it receives an internal message of type GossipKVDigestReplyMSG.
+func (centralController *CentralController) gossipKVDigestReplyHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVDigestReplyMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.sendToPeer(msg.To, InternalMessage{Type: GossipKVDigestReplyMSG, Payload: msg})
+
+	return nil
+}
+
+// gossipKVUpdateHandler is the method called by the Run method for when
+// it receives an internal message of type GossipKVUpdateMSG.
+func (centralController *CentralController) gossipKVUpdateHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVUpdateMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.sendToOutgoingPeer(msg.To, InternalMessage{Type: GossipKVUpdateMSG, Payload: msg})
+
+	return nil
+}
+
+// gossipAggregatePushHandler is the method called by the Run method for when
+// it receives an internal message of type GossipAggregatePushMSG.
+func (centralController *CentralController) gossipAggregatePushHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipAggregatePushMSGPayload)
+	if !ok {
+		return nil
+	}
+	if !centralController.sendToOutgoingPeer(msg.To, InternalMessage{Type: GossipAggregatePushMSG, Payload: msg}) {
+		// Give the mass back, since the peer will never receive it.
+		payload2 := GossipAggregatePushFailedMSGPayload(msg)
+		log.Println("Central controller -> Gossiper, GossipAggregatePushFailedMSG,", payload2.To)
+		centralController.gossiper.MsgInQueue <- InternalMessage{Type: GossipAggregatePushFailedMSG, Payload: payload2}
+	}
+
+	return nil
+}
+
+// gossipAggregateEstimateHandler is the method called by the Run method for when
+// it receives an internal message of type GossipAggregateEstimateMSG.
+func (centralController *CentralController) gossipAggregateEstimateHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipAggregateEstimateMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check if the api client to send the message exists.
+	info, isMember := centralController.apiClients[msg.Who]
+	if !isMember {
+		return nil
+	}
+	// Check if the writer goroutine is running.
+	if info.state.writerState != APIClientWriterRUNNING {
+		return nil
+	}
+	// Send the internal message to the api endpoint.
+	payload2 := APIAggregateEstimateMSGPayload(msg)
+	log.Println("Central controller -> API Endpoint, APIAggregateEstimateMSG,", payload2)
+	info.endpoint.MsgInQueue <- InternalMessage{
+		Type:    APIAggregateEstimateMSG,
+		Payload: payload2,
+	}
+
+	return nil
+}
+
+// connectedPeers returns the info of every p2p endpoint whose writer
+// goroutine is running, both outgoing and incoming. Shared connections
+// are returned only once.
+func (centralController *CentralController) connectedPeers() []*PeerInfoCentral {
+	infos := map[*PeerInfoCentral]bool{}
+	for _, valueAndIndex := range centralController.viewList.Iterate() {
+		infos[valueAndIndex.Value.(*PeerInfoCentral)] = true
+	}
+	for _, info := range centralController.awaitingRemovalViewList {
+		infos[info] = true
+	}
+	for _, info := range centralController.incomingViewList {
+		infos[info] = true
+	}
+	runningInfos := make([]*PeerInfoCentral, 0, len(infos))
+	for info := range infos {
+		if info.state.writerState == PeerWriterRUNNING {
+			runningInfos = append(runningInfos, info)
+		}
+	}
+	return runningInfos
+}
+
+// publishPeerEvents is the method for comparing the connected peers with the
+// neighbours announced last, and for notifying the API clients subscribed to
+// the peer lifecycle events about the differences. It is called by every
+// handler that may add or remove a connection.
+func (centralController *CentralController) publishPeerEvents() {
+	current := map[Identity]bool{}
+	for _, info := range centralController.connectedPeers() {
+		current[info.endpoint.peer.ID] = true
+	}
+	wasEmpty := len(centralController.neighbours) == 0
+	var events []APIPeerEventMSGPayload
+	for id := range centralController.neighbours {
+		if !current[id] {
+			delete(centralController.neighbours, id)
+			events = append(events, APIPeerEventMSGPayload{
+				Event: PeerDown, Peer: id, Neighbours: uint16(len(centralController.neighbours))})
+		}
+	}
+	for id := range current {
+		if !centralController.neighbours[id] {
+			centralController.neighbours[id] = true
+			events = append(events, APIPeerEventMSGPayload{
+				Event: PeerUp, Peer: id, Neighbours: uint16(len(centralController.neighbours))})
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+	if isEmpty := len(centralController.neighbours) == 0; isEmpty && !wasEmpty {
+		log.Println("Central controller: this node has no neighbours anymore!")
+		events = append(events, APIPeerEventMSGPayload{Event: ViewEmpty})
+	} else if !isEmpty && wasEmpty {
+		log.Println("Central controller: this node has neighbours again.")
+		events = append(events, APIPeerEventMSGPayload{
+			Event: ViewRestored, Neighbours: uint16(len(centralController.neighbours))})
+	}
+	for client, info := range centralController.apiClients {
+		if info.subscribesPeerEvents {
+			centralController.sendPeerEvents(client, info, events)
+		}
+	}
+}
+
+// sendPeerEvents is the method for sending the peer lifecycle events to the API client.
+func (centralController *CentralController) sendPeerEvents(
+	client APIClient, info *APIClientInfoCentral, events []APIPeerEventMSGPayload) {
+	// Check if the writer goroutine is running.
+	if info.state.writerState != APIClientWriterRUNNING {
+		return
+	}
+	for _, payload := range events {
+		log.Println("Central controller -> API Endpoint, APIPeerEventMSG,", client.addr, payload)
+		info.endpoint.MsgInQueue <- InternalMessage{Type: APIPeerEventMSG, Payload: payload}
+	}
+}
+
+// viewListInfo returns the info of the peer if it is in either the view list
+// or the removal view list, and true iff it is in the removal view list.
+// The info is nil if the peer is in neither of them.
+func (centralController *CentralController) viewListInfo(id Identity) (*PeerInfoCentral, bool) {
+	if centralController.viewList.IsMember(id) {
+		return centralController.viewList.GetValue(id).(*PeerInfoCentral), false
+	}
+	if info, isMember := centralController.awaitingRemovalViewList[id]; isMember {
+		return info, true
+	}
+	return nil, false
+}
+
+// isShared returns true iff the info belongs to a connection started by the
+// remote peer, which is also used as the outgoing connection to the peer.
+func (centralController *CentralController) isShared(info *PeerInfoCentral) bool {
+	return !info.endpoint.isOutgoing && centralController.incomingViewList[info.endpoint.peer.ID] == info
+}
+
+// keepsOwnConnection returns true iff the connection started by this node is
+// kept rather than the one started by the peer, when both of them exist. Both
+// peers agree on keeping the connection started by the smaller identity.
+func (centralController *CentralController) keepsOwnConnection(peer Peer) bool {
+	return bytes.Compare(centralController.identity[:], peer.ID[:]) < 0
+}
+
+// releaseRemovedPeer is the method called when a peer in the removal view list
+// is not used by any gossip item anymore. Its p2p endpoint is closed, unless
+// the connection is shared with the incoming view list.
+func (centralController *CentralController) releaseRemovedPeer(info *PeerInfoCentral) {
+	// If the p2p endpoint has already stopped or the connection is still in use.
+	if info.state.HaveBothStopped() || centralController.isShared(info) {
+		delete(centralController.awaitingRemovalViewList, info.endpoint.peer.ID)
+	} else {
+		info.endpoint.Close()
+	}
+}
+
+// retirePeer is the method for closing a p2p endpoint which was replaced by
+// another connection with the same peer. If its goroutines are still running,
+// then they are tracked in retiredPeers until they stop.
+func (centralController *CentralController) retirePeer(info *PeerInfoCentral) {
+	log.Println("P2P endpoint", info.endpoint.peer.ID, "is replaced by another connection.")
+	if !info.state.HaveBothStopped() {
+		centralController.retiredPeers[info.endpoint] = info
+		info.endpoint.Close()
+		return
+	}
+	go func() {
+		if info.endpoint.conn == nil {
+			return
+		}
+		info.endpoint.conn.Close()
+	}()
+}
+
+// retiredPeerClosed is the method called when either the reader or the
+// writer goroutine of a retired p2p endpoint is closed.
+func (centralController *CentralController) retiredPeerClosed(info *PeerInfoCentral, isReader bool) error {
+	if isReader {
+		if info.state.readerState == PeerReaderSTOPPED {
+			return nil
+		}
+		info.state.readerState = PeerReaderSTOPPED
+	} else {
+		if info.state.writerState == PeerWriterSTOPPED {
+			return nil
+		}
+		info.state.writerState = PeerWriterSTOPPED
+	}
+	centralController.state.totalGoroutines--
+	if info.state.HaveBothStopped() {
+		delete(centralController.retiredPeers, info.endpoint)
+		// Close the connection inside the endpoint.
+		go func() {
+			if info.endpoint.conn == nil {
+				return
+			}
+			info.endpoint.conn.Close()
+		}()
+		// Check if all submodules (goroutines) are closed.
+		if centralController.state.totalGoroutines <= 0 {
+			// Signal for graceful closure.
+			return &CloseError{}
+		}
+	}
+
+	return nil
+}
+
+// forgetOldDirectMessages is the method for removing the expired
+// entries of directSeen and directAwaitingAck.
+func (centralController *CentralController) forgetOldDirectMessages(now time.Time) {
+	for id, seen := range centralController.directSeen {
+		if now.Sub(seen) > directMessageLifetime {
+			delete(centralController.directSeen, id)
+		}
+	}
+	for id, info := range centralController.directAwaitingAck {
+		if now.After(info.expires) {
+			delete(centralController.directAwaitingAck, id)
+		}
+	}
+}
+
+// routeDirectMessage is the method for either delivering the directed message
+// if it is addressed to this node or forwarding it to the next hops. If the
+// destination is one of the connected peers, then the message is only sent
+// to it. Otherwise, it is flooded to every connected peer except the one it
+// came from until its TTL runs out.
+func (centralController *CentralController) routeDirectMessage(msg *DirectMessage, from Peer) {
+	now := time.Now()
+	centralController.forgetOldDirectMessages(now)
+	if _, isMember := centralController.directSeen[msg.ID]; isMember {
+		return
+	}
+	centralController.directSeen[msg.ID] = now
+	if msg.Destination == centralController.identity {
+		centralController.deliverDirectMessage(msg)
+		return
+	}
+	if msg.TTL == 0 {
+		return
+	}
+	forwarded := *msg
+	forwarded.TTL--
+	infos := centralController.connectedPeers()
+	for _, info := range infos {
+		if info.endpoint.peer.ID == msg.Destination {
+			log.Println("Central controller -> P2P Endpoint, DirectMSG,", &forwarded)
+			info.endpoint.MsgInQueue <- InternalMessage{Type: DirectMSG, Payload: forwarded}
+			return
+		}
+	}
+	for _, info := range infos {
+		if info.endpoint.peer.ID != from.ID {
+			log.Println("Central controller -> P2P Endpoint, DirectMSG,", &forwarded)
+			info.endpoint.MsgInQueue <- InternalMessage{Type: DirectMSG, Payload: forwarded}
+		}
+	}
+}
+
+// announceLeave is the method for sending a signed leave announcement of
+// this node to every connected peer.
+func (centralController *CentralController) announceLeave() {
+	leave, err := NewPeerLeave(centralController.p2pConfig.HostKey, time.Now(), peerLeaveTTL)
+	if err != nil {
+		log.Println("Central controller: cannot announce to leave:", err)
+		return
+	}
+	for _, info := range centralController.connectedPeers() {
+		log.Println("Central controller -> P2P Endpoint, PeerLeaveMSG,", leave)
+		info.endpoint.MsgInQueue <- InternalMessage{Type: PeerLeaveMSG, Payload: *leave}
+	}
+}
+
+// forgetOldPeerLeaves is the method for removing the expired entries of peerLeavesSeen.
+func (centralController *CentralController) forgetOldPeerLeaves(now time.Time) {
+	for id, when := range centralController.peerLeavesSeen {
+		if now.Sub(when) > peerLeaveLifetime {
+			delete(centralController.peerLeavesSeen, id)
+		}
+	}
+}
+
+// forgetOldTombstones is the method for removing the expired entries of tombstonesSeen.
+func (centralController *CentralController) forgetOldTombstones(now time.Time) {
+	for id, issued := range centralController.tombstonesSeen {
+		if now.Sub(issued) > tombstoneRetention {
+			delete(centralController.tombstonesSeen, id)
+		}
+	}
+}
+
+// verifyKVEntries is the method for checking that all the key-value entries
+// received from a peer are signed by their trusted writers. Returns false and
+// reports the peer if any of them is not.
+func (centralController *CentralController) verifyKVEntries(entries []*KVEntry, from Peer) bool {
+	for _, entry := range entries {
+		if entry == nil {
+			centralController.reportPeer(from, MalformedMessage)
+			return false
+		}
+		if err := entry.Verify(centralController.p2pConfig); err != nil {
+			log.Println("Central controller: invalid key-value entry from", from, err)
+			centralController.reportPeer(from, InvalidKVEntry)
+			return false
 		}
 	}
+	return true
+}
 
-	return nil
+// routePeerLeave is the method for handling a leave announcement of a peer.
+// A valid announcement that is not seen before is passed to the Membership
+// controller, and forwarded to every connected peer except the one it came
+// from and the one who left until its TTL runs out.
+func (centralController *CentralController) routePeerLeave(leave *PeerLeave, from Peer) {
+	now := time.Now()
+	centralController.forgetOldPeerLeaves(now)
+	id := leave.ID()
+	if seen, isMember := centralController.peerLeavesSeen[id]; id == centralController.identity ||
+		(isMember && !leave.When.After(seen)) {
+		return
+	}
+	if err := leave.Verify(now, peerLeaveLifetime); err != nil {
+		log.Println("Central controller: invalid leave announcement from", from, err)
+		centralController.reportPeer(from, InvalidPeerLeave)
+		return
+	}
+	centralController.peerLeavesSeen[id] = leave.When
+	log.Println("Central controller -> Membership controller, MembershipPeerLeftMSG,", leave)
+	centralController.membershipController.MsgInQueue <- InternalMessage{
+		Type:    MembershipPeerLeftMSG,
+		Payload: MembershipPeerLeftMSGPayload(leave),
+	}
+	if leave.TTL == 0 {
+		return
+	}
+	forwarded := *leave
+	forwarded.TTL--
+	for _, info := range centralController.connectedPeers() {
+		if peerID := info.endpoint.peer.ID; peerID != from.ID && peerID != id {
+			log.Println("Central controller -> P2P Endpoint, PeerLeaveMSG,", &forwarded)
+			info.endpoint.MsgInQueue <- InternalMessage{Type: PeerLeaveMSG, Payload: forwarded}
+		}
+	}
 }
 
-// gossipNotificationHandler is the method called by the Run method for when
-// it receives an internal message of type GossipNotificationMSG.
-func (centralController *CentralController) gossipNotificationHandler(payload AnyMessage) error {
-	msg, ok := payload.(GossipNotificationMSGPayload)
+// deliverDirectMessage is the method for handling a directed message
+// addressed to this node.
+func (centralController *CentralController) deliverDirectMessage(msg *DirectMessage) {
+	if msg.IsAck {
+		info, isMember := centralController.directAwaitingAck[msg.AckFor]
+		if !isMember {
+			return
+		}
+		delete(centralController.directAwaitingAck, msg.AckFor)
+		apiInfo, isMember := centralController.apiClients[info.who]
+		if !isMember || apiInfo.state.writerState != APIClientWriterRUNNING {
+			return
+		}
+		payload := APIDirectAckMSGPayload{Who: info.who, RequestID: info.requestID, From: msg.Source}
+		log.Println("Central controller -> API Endpoint, APIDirectAckMSG,", payload)
+		apiInfo.endpoint.MsgInQueue <- InternalMessage{Type: APIDirectAckMSG, Payload: payload}
+		return
+	}
+	// Let the Gossiper deliver the message to the interested API clients.
+	payload := GossipDirectIncomingMSGPayload{Message: msg}
+	log.Println("Central controller -> Gossiper, GossipDirectIncomingMSG,", msg)
+	centralController.gossiper.MsgInQueue <- InternalMessage{Type: GossipDirectIncomingMSG, Payload: payload}
+	if msg.WantAck {
+		ack := &DirectMessage{
+			ID:          NewDirectMessageID(),
+			Destination: msg.Source,
+			TTL:         centralController.directMaxTTL,
+			IsAck:       true,
+			AckFor:      msg.ID,
+		}
+		if err := ack.Sign(centralController.p2pConfig.HostKey); err != nil {
+			log.Println("Central controller: cannot sign the acknowledgement:", err)
+			return
+		}
+		centralController.routeDirectMessage(ack, Peer{})
+	}
+}
+
+// gossipDirectNotificationHandler is the method called by the Run method for when
+// it receives an internal message of type GossipDirectNotificationMSG.
+func (centralController *CentralController) gossipDirectNotificationHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipDirectNotificationMSGPayload)
 	if !ok {
 		return nil
 	}
@@ -614,45 +1582,16 @@ func (centralController *CentralController) gossipNotificationHandler(payload An
 		return nil
 	}
 	// Send the internal message to the api endpoint.
-	payload2 := APINotificationMSGPayload{Who: msg.Who, Item: msg.Item, ID: msg.ID}
-	log.Println("Central controller -> API Endpoint, APINotificationMSG,", payload2)
+	payload2 := APIDirectReceiveMSGPayload(msg)
+	log.Println("Central controller -> API Endpoint, APIDirectReceiveMSG,", payload2)
 	info.endpoint.MsgInQueue <- InternalMessage{
-		Type:    APINotificationMSG,
+		Type:    APIDirectReceiveMSG,
 		Payload: payload2,
 	}
 
 	return nil
 }
 
-// gossipPushHandler is the method called by the Run method for when
-// it receives an internal message of type GossipPushMSG.
-func (centralController *CentralController) gossipPushHandler(payload AnyMessage) error {
-	msg, ok := payload.(GossipPushMSGPayload)
-	if !ok {
-		return nil
-	}
-	var info *PeerInfoCentral
-	// Check if the peer to be sent is either in the view list or
-	// in the awaiting removal view list.
-	if centralController.viewList.IsMember(msg.To) {
-		value := centralController.viewList.GetValue(msg.To)
-		info = value.(*PeerInfoCentral)
-	} else if _info, isMember := centralController.awaitingRemovalViewList[msg.To]; isMember {
-		info = _info
-	} else {
-		return nil
-	}
-	// Check if the writer goroutine is running.
-	if info.state.writerState != PeerWriterRUNNING {
-		return nil
-	}
-	// Send the internal message to the p2p endpoint.
-	log.Println("Central controller -> P2P Endpoint, GossipPushMSG,", payload)
-	info.endpoint.MsgInQueue <- InternalMessage{Type: GossipPushMSG, Payload: payload}
-
-	return nil
-}
-
 // gossipPullRequestHandler is the method called by the Run method for when
 // it receives an internal message of type GossipPullRequestMSG.
 func (centralController *CentralController) gossipPullRequestHandler(payload AnyMessage) error {
@@ -663,10 +1602,10 @@ func (centralController *CentralController) gossipPullRequestHandler(payload Any
 	var info *PeerInfoCentral
 	// Check if the peer to be sent is either in the view list or
 	// in the awaiting removal view list.
-	if centralController.viewList.IsMember(peer) {
-		value := centralController.viewList.GetValue(peer)
+	if centralController.viewList.IsMember(peer.ID) {
+		value := centralController.viewList.GetValue(peer.ID)
 		info = value.(*PeerInfoCentral)
-	} else if _info, isMember := centralController.awaitingRemovalViewList[peer]; isMember {
+	} else if _info, isMember := centralController.awaitingRemovalViewList[peer.ID]; isMember {
 		info = _info
 	} else {
 		return nil
@@ -689,18 +1628,7 @@ func (centralController *CentralController) gossipPullReplyHandler(payload AnyMe
 	if !ok {
 		return nil
 	}
-	// Check if the peer to be sent is in the incoming view list.
-	info, isMember := centralController.incomingViewList[msg.To]
-	if !isMember {
-		return nil
-	}
-	// Check if the writer goroutine is running.
-	if info.state.writerState != PeerWriterRUNNING {
-		return nil
-	}
-	// Send the internal message to the p2p endpoint.
-	log.Println("Central controller -> P2P Endpoint, GossipPullReplyMSG,", payload)
-	info.endpoint.MsgInQueue <- InternalMessage{Type: GossipPullReplyMSG, Payload: payload}
+	centralController.sendToPeer(msg.To, InternalMessage{Type: GossipPullReplyMSG, Payload: payload})
 
 	return nil
 }
@@ -931,24 +1859,32 @@ func (centralController *CentralController) p2pListenerClosedHandler(payload Any
 // incomingP2PCreatedHandler is the method called by the Run method for when
 // it receives an internal message of type IncomingP2PCreatedMSG.
 func (centralController *CentralController) incomingP2PCreatedHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	endp, ok := payload.(*P2PEndpoint)
 	if !ok {
 		return nil
 	}
 	// Check if the peer of this endpoint already exists.
-	_, isMember := centralController.incomingViewList[endp.peer]
+	_, isMember := centralController.incomingViewList[endp.peer.ID]
 	if isMember {
 		// Log this unexpected event.
-		log.Println("Incoming P2P endpoint", endp.peer.Addr, "already exists!")
+		log.Println("Incoming P2P endpoint", endp.peer.ID, "already exists!")
 	}
+	// Check if there is a running connection started by this node to the peer,
+	// which is to be kept instead of this one.
+	outInfo, isInRemovalList := centralController.viewListInfo(endp.peer.ID)
+	hasOwnConnection := outInfo != nil && outInfo.endpoint.isOutgoing &&
+		outInfo.state.writerState == PeerWriterRUNNING
 	// Check if there is enough capacity left for the incoming p2p endpoint.
-	// Also check if the Central controller is stopping.
+	// Also check if the Central controller is stopping or the peer is banned.
 	if len(centralController.incomingViewList) >= int(centralController.incomingViewListMAX) ||
-		isMember || centralController.state.isStopping {
+		isMember || centralController.state.isStopping || centralController.isEndpointBanned(endp) ||
+		endp.peer.ID == centralController.identity || !centralController.allowIncomingDiverse(endp) ||
+		(hasOwnConnection && centralController.keepsOwnConnection(endp.peer)) {
 		// Close the connection inside the endpoint.
 		go func() {
 			if endp.conn == nil {
-				log.Println("endp.conn is nil", endp.peer.Addr)
+				log.Println("endp.conn is nil", endp.peer.Addrs)
 				return
 			}
 			endp.conn.Close()
@@ -961,13 +1897,28 @@ func (centralController *CentralController) incomingP2PCreatedHandler(payload An
 	// Account for the reader and writer goroutines.
 	centralController.state.totalGoroutines += 2
 	// Add the peer into the incoming view list.
-	centralController.incomingViewList[endp.peer] = &PeerInfoCentral{
+	info := &PeerInfoCentral{
 		endpoint: endp,
 		state: PeerState{
 			readerState: PeerReaderRUNNING,
 			writerState: PeerWriterRUNNING},
 		hasCrashed: false,
 	}
+	centralController.incomingViewList[endp.peer.ID] = info
+	// If there is also a connection started by this node, then
+	// this connection replaces it in both directions.
+	if outInfo != nil && outInfo.endpoint.isOutgoing {
+		info.usageCounter = outInfo.usageCounter
+		if isInRemovalList {
+			centralController.awaitingRemovalViewList[endp.peer.ID] = info
+			if info.usageCounter <= 0 {
+				centralController.releaseRemovedPeer(info)
+			}
+		} else {
+			centralController.viewList.Put(endp.peer.ID, info)
+		}
+		centralController.retirePeer(outInfo)
+	}
 
 	return nil
 }
@@ -980,16 +1931,38 @@ func (centralController *CentralController) outgoingPeerCompletelyClosed(
 	// Check if the p2p endpoint is not supposed to be closed.
 	if info.hasCrashed {
 		// Log the unexpected closure.
-		log.Println(fmt.Sprintf("%s%s", fmt.Sprintln("Outgoing P2P endpoint", peer.Addr, "has crashed."), err))
+		log.Println(fmt.Sprintf("%s%s", fmt.Sprintln("Outgoing P2P endpoint", peer.Addrs, "has crashed."), err))
 	} else {
 		// Log the graceful closure.
-		log.Println("Outgoing P2P endpoint", peer.Addr, "is closed.")
+		log.Println("Outgoing P2P endpoint", peer.Addrs, "is closed.")
 	}
+	centralController.viewListPeerClosed(info, isInRemovalList)
+	// Close the connection inside the endpoint.
+	go func() {
+		if info.endpoint.conn == nil {
+			log.Println("info.endpoint.conn is nil", info.endpoint.peer.Addrs)
+			return
+		}
+		info.endpoint.conn.Close()
+	}()
+	// Check if all submodules (goroutines) are closed.
+	if centralController.state.totalGoroutines <= 0 {
+		// Signal for graceful closure.
+		return &CloseError{}
+	}
+
+	return nil
+}
+
+// viewListPeerClosed is the method for updating the view list and the removal
+// view list when the connection of a peer in one of them is completely closed.
+func (centralController *CentralController) viewListPeerClosed(info *PeerInfoCentral, isInRemovalList bool) {
+	peer := info.endpoint.peer
 	if centralController.state.isStopping {
 		// If it was the User that ordered the closure, remove as soon as
 		// both the reader and the writer goroutines are stopped.
-		centralController.viewList.Remove(peer)
-		delete(centralController.awaitingRemovalViewList, peer)
+		centralController.viewList.Remove(peer.ID)
+		delete(centralController.awaitingRemovalViewList, peer.ID)
 	} else if !isInRemovalList {
 		// If this p2p endpoint was not removed by the Membership controller, then
 		// let the Membership controller know about the abruptly removed endpoint.
@@ -1000,45 +1973,24 @@ func (centralController *CentralController) outgoingPeerCompletelyClosed(
 			// If this p2p endpoint was not removed by the Membership controller, then
 			// it must not have gracefully closed!
 			// Log this unexpected event.
-			log.Println("Outgoing P2P endpoint", peer.Addr, "is closed without "+
+			log.Println("P2P endpoint", peer.ID, "is closed without "+
 				"the explicit request of neither the User nor the Membership controller!")
 		}
 	} else if info.usageCounter <= 0 {
 		// If this p2p endpoint was removed by the orders of the Membership
 		// controller, then check if the usage counter reached 0. If so, then
 		// delete it.
-		delete(centralController.awaitingRemovalViewList, peer)
-	}
-	// Close the connection inside the endpoint.
-	go func() {
-		if info.endpoint.conn == nil {
-			log.Println("info.endpoint.conn is nil", info.endpoint.peer.Addr)
-			return
-		}
-		info.endpoint.conn.Close()
-	}()
-	// Check if all submodules (goroutines) are closed.
-	if centralController.state.totalGoroutines <= 0 {
-		// Signal for graceful closure.
-		return &CloseError{}
+		delete(centralController.awaitingRemovalViewList, peer.ID)
 	}
-
-	return nil
 }
 
 // outgoingPeerClosed is the method called when either the reader or the writer
 // goroutine of an outgoing p2p endpoint is closed.
 func (centralController *CentralController) outgoingPeerClosed(
 	endp *P2PEndpoint, isReader bool, err error) error {
-	var info *PeerInfoCentral
 	// Check if the peer exists in either the view list or the removal view list.
-	_info, isInRemovalList := centralController.awaitingRemovalViewList[endp.peer]
-	if centralController.viewList.IsMember(endp.peer) {
-		value := centralController.viewList.GetValue(endp.peer)
-		info = value.(*PeerInfoCentral)
-	} else if isInRemovalList {
-		info = _info
-	} else {
+	info, isInRemovalList := centralController.viewListInfo(endp.peer.ID)
+	if info == nil || info.endpoint != endp {
 		return nil
 	}
 	// Check if the reader or the writer closed.
@@ -1072,9 +2024,9 @@ func (centralController *CentralController) outgoingPeerClosed(
 // incomingPeerClosed is the method called when an incoming p2p endpoint is closed.
 func (centralController *CentralController) incomingPeerClosed(
 	endp *P2PEndpoint, isReader bool, err error) error {
-	info, isMember := centralController.incomingViewList[endp.peer]
+	info, isMember := centralController.incomingViewList[endp.peer.ID]
 	// Check if the peer exists in the incoming view list.
-	if !isMember {
+	if !isMember || info.endpoint != endp {
 		return nil
 	}
 	// Check if the reader or the writer closed.
@@ -1096,11 +2048,16 @@ func (centralController *CentralController) incomingPeerClosed(
 	}
 	// Check if both reader and writer are stopped.
 	if info.state.HaveBothStopped() {
-		delete(centralController.incomingViewList, endp.peer)
+		delete(centralController.incomingViewList, endp.peer.ID)
+		// If the connection is shared with the view list or
+		// the removal view list, then update them as well.
+		if viewListInfo, isInRemovalList := centralController.viewListInfo(endp.peer.ID); viewListInfo == info {
+			centralController.viewListPeerClosed(info, isInRemovalList)
+		}
 		// Close the connection inside the endpoint.
 		go func() {
 			if endp.conn == nil {
-				log.Println("endp.conn is nil", endp.peer.Addr)
+				log.Println("endp.conn is nil", endp.peer.Addrs)
 				return
 			}
 			endp.conn.Close()
@@ -1108,10 +2065,10 @@ func (centralController *CentralController) incomingPeerClosed(
 		// Check if the p2p endpoint is not supposed to be closed.
 		if info.hasCrashed {
 			// Log the unexpected closure.
-			log.Println(fmt.Sprintf("%s%s", fmt.Sprintln("Incoming P2P endpoint", endp.peer.Addr, "has crashed."), err))
+			log.Println(fmt.Sprintf("%s%s", fmt.Sprintln("Incoming P2P endpoint", endp.peer.Addrs, "has crashed."), err))
 		} else {
 			// Log the graceful closure.
-			log.Println("Incoming P2P endpoint", endp.peer.Addr, "is closed.")
+			log.Println("Incoming P2P endpoint", endp.peer.Addrs, "is closed.")
 		}
 		// Check if all submodules (goroutines) are closed.
 		if centralController.state.totalGoroutines <= 0 {
@@ -1129,10 +2086,14 @@ func (centralController *CentralController) incomingPeerClosed(
 // p2pEndpointCrashedHandler is the method called by the Run method for when
 // it receives an internal message of type P2PEndpointCrashedMSG.
 func (centralController *CentralController) p2pEndpointCrashedHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	msg, ok := payload.(P2PEndpointCrashedMSGPayload)
 	if !ok {
 		return nil
 	}
+	if info, isMember := centralController.retiredPeers[msg.endp]; isMember {
+		return centralController.retiredPeerClosed(info, msg.isReader)
+	}
 	if msg.endp.isOutgoing {
 		return centralController.outgoingPeerClosed(msg.endp, msg.isReader, msg.err)
 	}
@@ -1142,10 +2103,14 @@ func (centralController *CentralController) p2pEndpointCrashedHandler(payload An
 // p2pEndpointClosedHandler is the method called by the Run method for when
 // it receives an internal message of type P2PEndpointClosedMSG.
 func (centralController *CentralController) p2pEndpointClosedHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	msg, ok := payload.(P2PEndpointClosedMSGPayload)
 	if !ok {
 		return nil
 	}
+	if info, isMember := centralController.retiredPeers[msg.endp]; isMember {
+		return centralController.retiredPeerClosed(info, msg.isReader)
+	}
 	if msg.endp.isOutgoing {
 		return centralController.outgoingPeerClosed(msg.endp, msg.isReader, nil)
 	}
@@ -1155,46 +2120,67 @@ func (centralController *CentralController) p2pEndpointClosedHandler(payload Any
 // outgoingP2PCreatedHandler is the method called by the Run method for when
 // it receives an internal message of type OutgoingP2PCreatedMSG.
 func (centralController *CentralController) outgoingP2PCreatedHandler(payload AnyMessage) error {
+	defer centralController.publishPeerEvents()
 	endp, ok := payload.(*P2PEndpoint)
 	if !ok {
 		return nil
 	}
 	// Check if the peer of this endpoint was registered.
-	isToBeRemoved, isMember := centralController.activelyCreatedPeers[endp.peer]
+	isToBeRemoved, isMember := centralController.activelyCreatedPeers[endp.peer.ID]
 	if !isMember {
 		// Log this unexpected event.
-		log.Println("Outgoing P2P endpoint", endp.peer.Addr, "was created without registration!")
+		log.Println("Outgoing P2P endpoint", endp.peer.Addrs, "was created without registration!")
 		// Close the connection inside the endpoint.
 		go func() {
 			if endp.conn == nil {
-				log.Println("endp.conn is nil", endp.peer.Addr)
+				log.Println("endp.conn is nil", endp.peer.Addrs)
 				return
 			}
 			endp.conn.Close()
 		}()
 		return nil
 	}
-	delete(centralController.activelyCreatedPeers, endp.peer)
-	// Start running the reader and writer goroutines.
-	endp.RunReaderGoroutine()
-	endp.RunWriterGoroutine()
-	// Account for the reader and writer goroutines.
-	centralController.state.totalGoroutines += 2
-	// Add the peer into the view list.
-	centralController.viewList.Put(endp.peer,
-		&PeerInfoCentral{
-			endpoint: endp, usageCounter: 0,
-			state: PeerState{
-				readerState: PeerReaderRUNNING,
-				writerState: PeerWriterRUNNING},
-			hasCrashed: false,
-		})
+	delete(centralController.activelyCreatedPeers, endp.peer.ID)
+	inInfo, hasIncoming := centralController.incomingViewList[endp.peer.ID]
+	if hasIncoming && (endp.conn == nil || !centralController.keepsOwnConnection(endp.peer)) {
+		// The peer has connected to us in the meantime and its connection
+		// is kept, so share it instead of this one.
+		go func() {
+			if endp.conn == nil {
+				return
+			}
+			endp.conn.Close()
+		}()
+		centralController.viewList.Put(endp.peer.ID, inInfo)
+	} else {
+		// Start running the reader and writer goroutines.
+		endp.RunReaderGoroutine()
+		endp.RunWriterGoroutine()
+		// Account for the reader and writer goroutines.
+		centralController.state.totalGoroutines += 2
+		// Add the peer into the view list.
+		centralController.viewList.Put(endp.peer.ID,
+			&PeerInfoCentral{
+				endpoint: endp, usageCounter: 0,
+				state: PeerState{
+					readerState: PeerReaderRUNNING,
+					writerState: PeerWriterRUNNING},
+				hasCrashed: false,
+			})
+		// If the peer has connected to us in the meantime, then
+		// this connection replaces it in both directions.
+		if hasIncoming {
+			delete(centralController.incomingViewList, endp.peer.ID)
+			centralController.retirePeer(inInfo)
+		}
+	}
 	// If this peer was attempted to be removed before creation
 	// was done, then let it be removed.
 	if isToBeRemoved {
 		log.Println("Central controller -> Central controller, PeerRemoveMSG,", endp.peer)
 		centralController.MsgInQueue <- InternalMessage{Type: PeerRemoveMSG, Payload: endp.peer}
 	}
+	centralController.sendPendingPings(endp.peer.ID)
 
 	return nil
 }
@@ -1207,19 +2193,21 @@ func (centralController *CentralController) centralProbePeerReplyHandler(payload
 		return nil
 	}
 	// Check if for some unexpected reason the probed peer is not registered.
-	addPeer, isMember := centralController.activelyProbedPeers[msg.Probed]
+	addPeer, isMember := centralController.activelyProbedPeers[msg.Probed.ID]
 	if !isMember {
 		// Log this unexpected event.
-		log.Println("Peer", msg.Probed.Addr, "was probed without registration!")
+		log.Println("Peer", msg.Probed.Addrs, "was probed without registration!")
 		return nil
 	}
-	delete(centralController.activelyProbedPeers, msg.Probed)
-	// Send the probe results back to the Membership controller.
-	payload2 := ProbePeerReplyMSGPayload{Probed: msg.Probed, ProbeResult: msg.ProbeResult}
-	log.Println("Central controller -> Membership controller, ProbePeerReplyMSG,", payload2)
-	centralController.membershipController.MsgInQueue <- InternalMessage{
-		Type:    ProbePeerReplyMSG,
-		Payload: payload2,
+	delete(centralController.activelyProbedPeers, msg.Probed.ID)
+	// Send the ack back to the Membership controller.
+	if msg.Ack != nil {
+		payload2 := SWIMIncomingAckMSGPayload{From: msg.Probed, Seq: msg.Ack.Seq, Updates: msg.Ack.Updates}
+		log.Println("Central controller -> Membership controller, SWIMIncomingAckMSG,", payload2)
+		centralController.membershipController.MsgInQueue <- InternalMessage{
+			Type:    SWIMIncomingAckMSG,
+			Payload: payload2,
+		}
 	}
 	// If this peer was attempted to be added before probing
 	// was done, then let it be added.
@@ -1227,6 +2215,134 @@ func (centralController *CentralController) centralProbePeerReplyHandler(payload
 		log.Println("Central controller -> Central controller, PeerAddMSG,", msg.Probed)
 		centralController.MsgInQueue <- InternalMessage{Type: PeerAddMSG, Payload: msg.Probed}
 	}
+	centralController.sendPendingPings(msg.Probed.ID)
+
+	return nil
+}
+
+// sendPendingPings is the method for sending the pings held back while
+// the peer of the identity was being either created or probed.
+func (centralController *CentralController) sendPendingPings(id Identity) {
+	pending := centralController.pendingPings[id]
+	delete(centralController.pendingPings, id)
+	for _, ping := range pending {
+		centralController.swimPingHandler(ping)
+	}
+}
+
+// isBanned returns true iff the peer is currently banned.
+func (centralController *CentralController) isBanned(peer Peer) bool {
+	return isPeerBanned(centralController.banList, peer)
+}
+
+// endpointIP returns the remote IP address of the connection
+// of the p2p endpoint, or nil if there is no connection.
+func endpointIP(endp *P2PEndpoint) net.IP {
+	if endp.conn == nil {
+		return nil
+	}
+	if addr, ok := endp.conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// isEndpointBanned returns true iff either the peer of the p2p endpoint
+// or the remote IP address of its connection is currently banned.
+func (centralController *CentralController) isEndpointBanned(endp *P2PEndpoint) bool {
+	return centralController.isBanned(endp.peer) || centralController.banList.IsIPBanned(endpointIP(endp))
+}
+
+// allowIncomingDiverse is the method for checking whether the incoming p2p
+// endpoint satisfies the subnet constraints of the diversity policy together
+// with the other incoming p2p endpoints. Every refusal is logged together
+// with the number of refusals by the same constraint.
+func (centralController *CentralController) allowIncomingDiverse(endp *P2PEndpoint) bool {
+	ip := endpointIP(endp)
+	if ip == nil {
+		return true
+	}
+	policy := DiversityPolicy{
+		MaxPeersPerSubnet:     centralController.diversity.MaxPeersPerSubnet,
+		MaxPeersPerWideSubnet: centralController.diversity.MaxPeersPerWideSubnet,
+	}
+	filter := NewDiversityFilter(&policy, 0, centralController.diversityStats)
+	for _, info := range centralController.incomingViewList {
+		if otherIP := endpointIP(info.endpoint); otherIP != nil {
+			filter.Record([]net.IP{otherIP}, Identity{})
+		}
+	}
+	allowed, constraint := filter.Allow([]net.IP{ip}, Identity{})
+	if !allowed {
+		log.Println("Central controller:", constraint, "refused the incoming P2P endpoint from", ip.String()+",",
+			centralController.diversityStats[constraint], "times in total")
+	}
+	return allowed
+}
+
+// disconnectBannedPeers is the method for closing the connections of the
+// banned peers. The Membership controller is informed about the closed
+// connections of the peers in the view list as usual.
+func (centralController *CentralController) disconnectBannedPeers() {
+	for _, info := range centralController.connectedPeers() {
+		if centralController.isEndpointBanned(info.endpoint) {
+			log.Println("Central controller: disconnecting the banned peer", info.endpoint.peer.ID)
+			info.endpoint.Close()
+		}
+	}
+}
+
+// peerBanHandler is the method called by the Run method for when
+// it receives an internal message of type PeerBanMSG.
+func (centralController *CentralController) peerBanHandler(payload AnyMessage) error {
+	msg, ok := payload.(PeerBanMSGPayload)
+	if !ok {
+		return nil
+	}
+	log.Println("Peer", msg.Peer.Addrs, "is banned until", msg.Until)
+	if err := centralController.banList.Add(msg.Peer.ID.String(), msg.Until); err != nil {
+		log.Println("Central controller: cannot persist the ban list:", err)
+	}
+	// The outgoing p2p endpoint is removed by the Membership controller
+	// as usual, but the incoming one has to be closed right here.
+	if info, isMember := centralController.incomingViewList[msg.Peer.ID]; isMember {
+		info.endpoint.Close()
+	}
+
+	return nil
+}
+
+// reportPeer is the method for informing the Membership
+// controller about a peer who violated the protocol.
+func (centralController *CentralController) reportPeer(peer Peer, reason PeerMisbehaviour) {
+	payload := PeerMisbehavedMSGPayload{Peer: peer, Reason: reason}
+	log.Println("Central controller -> Membership controller, PeerMisbehavedMSG,", payload)
+	centralController.membershipController.MsgInQueue <- InternalMessage{
+		Type:    PeerMisbehavedMSG,
+		Payload: payload,
+	}
+}
+
+// gossipPeerMisbehavedHandler is the method called by the Run method for when
+// it receives an internal message of type GossipPeerMisbehavedMSG.
+func (centralController *CentralController) gossipPeerMisbehavedHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipPeerMisbehavedMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.reportPeer(msg.Peer, msg.Reason)
+
+	return nil
+}
+
+// p2pEndpointMalformedHandler is the method called by the Run method for when
+// it receives an internal message of type P2PEndpointMalformedMSG.
+func (centralController *CentralController) p2pEndpointMalformedHandler(payload AnyMessage) error {
+	peer, ok := payload.(P2PEndpointMalformedMSGPayload)
+	if !ok {
+		return nil
+	}
+	centralController.reportPeer(Peer(peer), MalformedMessage)
 
 	return nil
 }
@@ -1260,6 +2376,135 @@ func (centralController *CentralController) incomingAPIHandler(message AnyMessag
 		}
 		log.Println("Central controller -> Gossiper, GossipValidationMSG,", im)
 		centralController.gossiper.MsgInQueue <- im
+	case GossipKVPutMSG:
+		_, ok := im.Payload.(GossipKVPutMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipKVPutMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipKVGetMSG:
+		_, ok := im.Payload.(GossipKVGetMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipKVGetMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipKVSubscribeMSG:
+		_, ok := im.Payload.(GossipKVSubscribeMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipKVSubscribeMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipAggregateContributeMSG:
+		_, ok := im.Payload.(GossipAggregateContributeMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipAggregateContributeMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipAggregateQueryMSG:
+		_, ok := im.Payload.(GossipAggregateQueryMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipAggregateQueryMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case APIDirectSendMSG:
+		msg, ok := im.Payload.(APIDirectSendMSGPayload)
+		if !ok {
+			return nil
+		}
+		// Calculate the TTL for the directed message.
+		ttl := centralController.directMaxTTL
+		if msg.TTL != 0 && msg.TTL < ttl {
+			ttl = msg.TTL
+		}
+		direct := &DirectMessage{
+			ID:          NewDirectMessageID(),
+			Destination: msg.Destination,
+			TTL:         ttl,
+			WantAck:     msg.WantAck,
+			DataType:    msg.DataType,
+			Data:        msg.Data,
+		}
+		if err := direct.Sign(centralController.p2pConfig.HostKey); err != nil {
+			log.Println("Central controller: cannot sign the directed message:", err)
+			return nil
+		}
+		if msg.WantAck {
+			centralController.directAwaitingAck[direct.ID] = &DirectAckInfoCentral{
+				who: msg.Who, requestID: msg.RequestID, expires: time.Now().Add(directMessageLifetime),
+			}
+		}
+		centralController.routeDirectMessage(direct, Peer{})
+	case APIRetractMSG:
+		msg, ok := im.Payload.(APIRetractMSGPayload)
+		if !ok || msg.Item == nil {
+			return nil
+		}
+		// Sign the tombstone with our own identity, so that other peers can verify it.
+		tombstone, err := NewGossipTombstone(msg.Item, centralController.p2pConfig.HostKey)
+		if err != nil {
+			log.Println("Central controller: cannot sign the tombstone:", err)
+			return nil
+		}
+		payload := GossipRetractMSGPayload{Tombstone: tombstone}
+		log.Println("Central controller -> Gossiper, GossipRetractMSG,", payload)
+		centralController.gossiper.MsgInQueue <- InternalMessage{Type: GossipRetractMSG, Payload: payload}
+	case APIAdminBanMSG:
+		msg, ok := im.Payload.(APIAdminBanMSGPayload)
+		if !ok {
+			return nil
+		}
+		// The entry is banned even if the ban list cannot be persisted.
+		if err := centralController.banList.Add(msg.Entry, msg.Expires); err != nil {
+			log.Println("Central controller: cannot ban", msg.Entry, err)
+		} else {
+			log.Println("Central controller:", msg.Entry, "is banned by an admin until", msg.Expires)
+		}
+		centralController.disconnectBannedPeers()
+	case MembershipRPSQueryMSG:
+		_, ok := im.Payload.(MembershipRPSQueryMSGPayload)
+		if !ok {
+			return nil
+		}
+		log.Println("Central controller -> Membership controller, MembershipRPSQueryMSG,", im)
+		centralController.membershipController.MsgInQueue <- im
+	case APIPeerSubscribeMSG:
+		msg, ok := im.Payload.(APIPeerSubscribeMSGPayload)
+		if !ok {
+			return nil
+		}
+		info, isMember := centralController.apiClients[msg.Who]
+		if !isMember || info.subscribesPeerEvents == msg.Subscribe {
+			return nil
+		}
+		info.subscribesPeerEvents = msg.Subscribe
+		if !msg.Subscribe {
+			return nil
+		}
+		// Let the new subscriber know the current neighbours, or that there is none.
+		events := []APIPeerEventMSGPayload{{Event: ViewEmpty}}
+		if len(centralController.neighbours) != 0 {
+			events = events[:0]
+			for id := range centralController.neighbours {
+				events = append(events, APIPeerEventMSGPayload{
+					Event: PeerUp, Peer: id, Neighbours: uint16(len(centralController.neighbours))})
+			}
+		}
+		centralController.sendPeerEvents(msg.Who, info, events)
+	case APIAdminUnbanMSG:
+		msg, ok := im.Payload.(APIAdminUnbanMSGPayload)
+		if !ok {
+			return nil
+		}
+		if removed, err := centralController.banList.Remove(msg.Entry); err != nil {
+			log.Println("Central controller: cannot unban", msg.Entry, err)
+		} else if removed {
+			log.Println("Central controller:", msg.Entry, "is unbanned by an admin")
+		}
 	default:
 		log.Println("unexpected incoming API message of type", im.Type)
 		break
@@ -1279,6 +2524,15 @@ func (centralController *CentralController) incomingP2PHandler(message AnyMessag
 	case MembershipIncomingPullRequestMSG:
 		log.Println("Central controller -> Membership controller, MembershipIncomingPullRequestMSG,", im)
 		centralController.membershipController.MsgInQueue <- im
+	case SWIMIncomingPingMSG:
+		log.Println("Central controller -> Membership controller, SWIMIncomingPingMSG,", im)
+		centralController.membershipController.MsgInQueue <- im
+	case SWIMIncomingAckMSG:
+		log.Println("Central controller -> Membership controller, SWIMIncomingAckMSG,", im)
+		centralController.membershipController.MsgInQueue <- im
+	case SWIMIncomingPingRequestMSG:
+		log.Println("Central controller -> Membership controller, SWIMIncomingPingRequestMSG,", im)
+		centralController.membershipController.MsgInQueue <- im
 	case MembershipIncomingPullReplyMSG:
 		log.Println("Central controller -> Membership controller, MembershipIncomingPullReplyMSG,", im)
 		centralController.membershipController.MsgInQueue <- im
@@ -1291,6 +2545,74 @@ func (centralController *CentralController) incomingP2PHandler(message AnyMessag
 	case GossipIncomingPullReplyMSG:
 		log.Println("Central controller -> Gossiper, GossipIncomingPullReplyMSG,", im)
 		centralController.gossiper.MsgInQueue <- im
+	case GossipKVIncomingDigestMSG:
+		log.Println("Central controller -> Gossiper, GossipKVIncomingDigestMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipKVIncomingDigestReplyMSG:
+		msg, ok := im.Payload.(GossipKVIncomingDigestReplyMSGPayload)
+		if !ok || !centralController.verifyKVEntries(msg.Entries, msg.From) {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipKVIncomingDigestReplyMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipKVIncomingUpdateMSG:
+		msg, ok := im.Payload.(GossipKVIncomingUpdateMSGPayload)
+		if !ok || !centralController.verifyKVEntries(msg.Entries, msg.From) {
+			return nil
+		}
+		log.Println("Central controller -> Gossiper, GossipKVIncomingUpdateMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case GossipAggregateIncomingPushMSG:
+		log.Println("Central controller -> Gossiper, GossipAggregateIncomingPushMSG,", im)
+		centralController.gossiper.MsgInQueue <- im
+	case DirectIncomingMSG:
+		msg, ok := im.Payload.(DirectIncomingMSGPayload)
+		if !ok || msg.Message == nil {
+			return nil
+		}
+		// Only route the new messages which are signed by their trusted sources.
+		if _, isMember := centralController.directSeen[msg.Message.ID]; isMember {
+			return nil
+		}
+		if err := msg.Message.Verify(centralController.p2pConfig); err != nil {
+			log.Println("Central controller: invalid directed message from", msg.From, err)
+			centralController.reportPeer(msg.From, InvalidDirectMessage)
+			return nil
+		}
+		centralController.routeDirectMessage(msg.Message, msg.From)
+	case PeerIncomingLeaveMSG:
+		msg, ok := im.Payload.(PeerIncomingLeaveMSGPayload)
+		if !ok || msg.Leave == nil {
+			return nil
+		}
+		centralController.routePeerLeave(msg.Leave, msg.From)
+	case GossipIncomingTombstoneMSG:
+		msg, ok := im.Payload.(GossipIncomingTombstoneMSGPayload)
+		if !ok {
+			return nil
+		}
+		// Only forward the new tombstones issued by trusted identities.
+		now := time.Now()
+		centralController.forgetOldTombstones(now)
+		tombstones := make([]*GossipTombstone, 0, len(msg.Tombstones))
+		for _, tombstone := range msg.Tombstones {
+			if _, isMember := centralController.tombstonesSeen[tombstone.ItemID]; isMember {
+				continue
+			}
+			if err := tombstone.Verify(centralController.p2pConfig, now, tombstoneRetention, tombstoneClockSkew); err != nil {
+				log.Println("Central controller: invalid tombstone from", msg.From, err)
+				centralController.reportPeer(msg.From, InvalidTombstone)
+				return nil
+			}
+			centralController.tombstonesSeen[tombstone.ItemID] = tombstone.Time
+			tombstones = append(tombstones, tombstone)
+		}
+		if len(tombstones) == 0 {
+			return nil
+		}
+		payload := GossipIncomingTombstoneMSGPayload{From: msg.From, Tombstones: tombstones}
+		log.Println("Central controller -> Gossiper, GossipIncomingTombstoneMSG,", payload)
+		centralController.gossiper.MsgInQueue <- InternalMessage{Type: GossipIncomingTombstoneMSG, Payload: payload}
 	}
 	return nil
 }
@@ -1314,6 +2636,9 @@ func (centralController *CentralController) closeHandler(payload AnyMessage) err
 	}
 	// Log the graceful closure.
 	log.Println("Central controller is closing.")
+	// Let the connected peers know before closing the connections, so that
+	// they do not have to detect the failure of this node.
+	centralController.announceLeave()
 	// Before closing the Central controller, make sure to have already
 	// closed all other submodules (goroutines)!
 	centralController.apiListener.Close()
@@ -1330,12 +2655,12 @@ func (centralController *CentralController) closeHandler(payload AnyMessage) err
 		info.endpoint.Close()
 	}
 	// Signal all actively created outgoing p2p endpoints to be removed later.
-	for peer := range centralController.activelyCreatedPeers {
-		centralController.activelyCreatedPeers[peer] = true
+	for id := range centralController.activelyCreatedPeers {
+		centralController.activelyCreatedPeers[id] = true
 	}
 	// Signal all actively probed peers to be not added later.
-	for peer := range centralController.activelyProbedPeers {
-		centralController.activelyProbedPeers[peer] = false
+	for id := range centralController.activelyProbedPeers {
+		centralController.activelyProbedPeers[id] = false
 	}
 	for _, info := range centralController.incomingViewList {
 		info.endpoint.Close()
@@ -1403,18 +2728,32 @@ func (centralController *CentralController) String() string {
 	reprFormat := "*CentralController{\n" +
 		"\tp2pConfig: %v,\n" +
 		"\tbootstrapper: %q,\n" +
-		"\tapiAddr: %q,\n" +
-		"\tp2pAddr: %q,\n" +
+		"\tapiAddrs: %q,\n" +
+		"\tp2pAddrs: %q,\n" +
+		"\tadvertiseAddrs: %q,\n" +
 		"\tapiListener: %v,\n" +
 		"\tp2pListener: %v,\n" +
 		"\tviewList: %v,\n" +
 		"\tawaitingRemovalViewList: %s,\n" +
 		"\tactivelyCreatedPeers: %s,\n" +
 		"\tactivelyProbedPeers: %s,\n" +
+		"\tpendingPings: %v,\n" +
 		"\tincomingViewList: %s,\n" +
 		"\tincomingViewListMAX: %d,\n" +
+		"\tretiredPeers: %s,\n" +
+		"\tbanList: %s,\n" +
+		"\tdiversity: %+v,\n" +
+		"\tdiversityStats: %v,\n" +
+		"\tneighbours: %v,\n" +
 		"\tapiClients: %s,\n" +
 		"\tapiClientsMAX: %d,\n" +
+		"\tidentity: %s,\n" +
+		"\tdirectMaxTTL: %d,\n" +
+		"\tconfiguredMaxTTL: %d,\n" +
+		"\tsizeParams: %s,\n" +
+		"\tdirectSeen: %v,\n" +
+		"\tdirectAwaitingAck: %s,\n" +
+		"\tpeerLeavesSeen: %v,\n" +
 		"\tmembershipController: %s,\n" +
 		"\tgossiper: %s,\n" +
 		"\tstate: %v,\n" +
@@ -1422,18 +2761,32 @@ func (centralController *CentralController) String() string {
 	return fmt.Sprintf(reprFormat,
 		centralController.p2pConfig,
 		centralController.bootstrapper,
-		centralController.apiAddr,
-		centralController.p2pAddr,
+		centralController.apiAddrs,
+		centralController.p2pAddrs,
+		centralController.advertiseAddrs,
 		centralController.apiListener,
 		centralController.p2pListener,
 		centralController.viewList,
 		centralController.awaitingRemovalViewList,
 		centralController.activelyCreatedPeers,
 		centralController.activelyProbedPeers,
+		centralController.pendingPings,
 		centralController.incomingViewList,
 		centralController.incomingViewListMAX,
+		centralController.retiredPeers,
+		centralController.banList,
+		centralController.diversity,
+		centralController.diversityStats,
+		centralController.neighbours,
 		centralController.apiClients,
 		centralController.apiClientsMAX,
+		centralController.identity,
+		centralController.directMaxTTL,
+		centralController.configuredMaxTTL,
+		centralController.sizeParams,
+		centralController.directSeen,
+		centralController.directAwaitingAck,
+		centralController.peerLeavesSeen,
 		centralController.membershipController,
 		centralController.gossiper,
 		centralController.state,
@@ -7,8 +7,9 @@ type OutgoingP2PCreatedMSGPayload *P2PEndpoint
 // CentralProbePeerReplyMSGPayload is the payload type of an InternalMessage
 // with type CentralProbePeerReplyMSG.
 type CentralProbePeerReplyMSGPayload struct {
-	Probed      Peer
-	ProbeResult bool
+	Probed Peer
+	// Ack is the ack of the probed peer. If it is nil, then the peer did not answer.
+	Ack *SWIMAckMSGPayload
 }
 
 // CentralCrashMSGPayload is the payload type of an InternalMessage
@@ -18,3 +19,27 @@ type CentralCrashMSGPayload error
 // CentralCloseMSGPayload is the payload type of an InternalMessage
 // with type CentralCloseMSG.
 type CentralCloseMSGPayload void
+
+// DirectMSGPayload is the payload type of an InternalMessage
+// with type DirectMSG.
+type DirectMSGPayload DirectMessage
+
+// DirectIncomingMSGPayload is the payload type of an InternalMessage
+// with type DirectIncomingMSG.
+type DirectIncomingMSGPayload struct {
+	// From is the remote peer who forwarded the directed message.
+	From    Peer
+	Message *DirectMessage
+}
+
+// PeerLeaveMSGPayload is the payload type of an InternalMessage
+// with type PeerLeaveMSG.
+type PeerLeaveMSGPayload PeerLeave
+
+// PeerIncomingLeaveMSGPayload is the payload type of an InternalMessage
+// with type PeerIncomingLeaveMSG.
+type PeerIncomingLeaveMSGPayload struct {
+	// From is the remote peer who forwarded the leave announcement.
+	From  Peer
+	Leave *PeerLeave
+}
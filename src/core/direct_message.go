@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+	"time"
+)
+
+// directMessageContext separates the signatures of directed messages
+// from any other signature made with the host keys.
+const directMessageContext = "gossip direct message"
+
+// DirectMessageID is the random identifier of a directed message, used for
+// detecting duplicates while flooding and for matching acknowledgements.
+type DirectMessageID [16]byte
+
+// NewDirectMessageID returns a new random DirectMessageID.
+func NewDirectMessageID() DirectMessageID {
+	var id DirectMessageID
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func (id DirectMessageID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// DirectMessage is a message addressed to a single node by its identity.
+// It is routed hop-by-hop over the existing p2p connections. Every message,
+// including the acknowledgements, is signed by its source, so that the
+// peers on the route can neither forge nor alter it.
+type DirectMessage struct {
+	ID DirectMessageID
+	// Source is the identity of the node who sent the message.
+	Source Identity
+	// Destination is the identity of the node to deliver the message.
+	Destination Identity
+	// TTL is the remaining number of hops the message may travel.
+	TTL uint8
+	// WantAck is true iff the destination has to acknowledge the delivery.
+	WantAck bool
+	// IsAck is true iff the message is the acknowledgement of the message
+	// with ID AckFor. Acknowledgements carry no data.
+	IsAck  bool
+	AckFor DirectMessageID
+	// DataType and Data are the contents of the message, delivered to the
+	// API clients interested in the data type.
+	DataType GossipItemDataType
+	Data     []byte
+	// PubKey is the canonically encoded public key of the source,
+	// whose hash is Source.
+	PubKey []byte
+	// Sig is the signature of every field except TTL made with the host
+	// key of the source. TTL is not signed, since every hop decrements it.
+	Sig []byte
+}
+
+// Sign sets the source of the message to the host and signs the message
+// with the host key.
+func (msg *DirectMessage) Sign(hostKey crypto.Signer) error {
+	pubKey, err := securecomm.MarshalPublicKey(hostKey.Public())
+	if err != nil {
+		return err
+	}
+	msg.PubKey = pubKey
+	msg.Source = sha256.Sum256(pubKey)
+	sig, err := securecomm.Sign(hostKey, crypto.SHA256, msg.signedBytes())
+	if err != nil {
+		return err
+	}
+	msg.Sig = sig
+	return nil
+}
+
+// signedBytes returns the serialization of every field of the message except TTL and Sig.
+func (msg *DirectMessage) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(directMessageContext)
+	buf.Write(msg.ID[:])
+	buf.Write(msg.Source[:])
+	buf.Write(msg.Destination[:])
+	binary.Write(&buf, binary.BigEndian, msg.WantAck)
+	binary.Write(&buf, binary.BigEndian, msg.IsAck)
+	buf.Write(msg.AckFor[:])
+	binary.Write(&buf, binary.BigEndian, uint16(msg.DataType))
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg.Data)))
+	buf.Write(msg.Data)
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg.PubKey)))
+	buf.Write(msg.PubKey)
+	return buf.Bytes()
+}
+
+// Verify checks that the message is signed by its source and that the
+// source is one of the trusted identities, which is not banned.
+func (msg *DirectMessage) Verify(config *securecomm.Config) error {
+	if Identity(sha256.Sum256(msg.PubKey)) != msg.Source {
+		return fmt.Errorf("directed message of %s is signed by another key", msg.Source)
+	}
+	pubKey, err := securecomm.ParsePublicKey(msg.PubKey)
+	if err != nil {
+		return fmt.Errorf("directed message has %s", err)
+	}
+	if err := securecomm.Verify(pubKey, crypto.SHA256, msg.signedBytes(), msg.Sig); err != nil {
+		return err
+	}
+	return securecomm.CheckIdentity(pubKey, config)
+}
+
+func (msg *DirectMessage) String() string {
+	return fmt.Sprintf("{ID: %s, Source: %s, Destination: %s, TTL: %d, WantAck: %t, IsAck: %t, DataType: %d}",
+		msg.ID, msg.Source, msg.Destination, msg.TTL, msg.WantAck, msg.IsAck, msg.DataType)
+}
+
+// DirectAckInfoCentral holds the API client who sent a directed message and
+// is waiting for its acknowledgement. This struct is meant to be used as a
+// value in a map[DirectMessageID]*DirectAckInfoCentral by the Central controller.
+type DirectAckInfoCentral struct {
+	who       APIClient
+	requestID uint16
+	expires   time.Time
+}
+
+func (info *DirectAckInfoCentral) String() string {
+	return fmt.Sprintf("{who: %s, requestID: %d, expires: %s}", info.who.addr, info.requestID, info.expires)
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"fmt"
+	"gossip/src/utils"
+	"net"
+)
+
+// DiversityPolicy holds the optional constraints on the diversity of the
+// peers, which make it harder for an attacker controlling few networks to
+// eclipse this node. A zero value of a field disables that constraint.
+type DiversityPolicy struct {
+	// MaxPeersPerSubnet is the maximum number of peers from the same /24 IPv4
+	// or /48 IPv6 subnet, both in a new view list and among the incoming p2p
+	// endpoints.
+	MaxPeersPerSubnet uint16
+	// MaxPeersPerWideSubnet is the maximum number of peers from the same /16
+	// IPv4 or /32 IPv6 subnet, both in a new view list and among the incoming
+	// p2p endpoints.
+	MaxPeersPerWideSubnet uint16
+	// MaxSourceShare is the maximum percentage of the pulled peers of a new
+	// view list that may come from the pull reply of the same peer.
+	MaxSourceShare uint8
+}
+
+// Validate checks whether the policy is self-consistent.
+func (policy *DiversityPolicy) Validate() error {
+	if policy.MaxSourceShare > 100 {
+		return fmt.Errorf("source share must be a percentage, not %d", policy.MaxSourceShare)
+	}
+	return nil
+}
+
+// DiversityConstraint is a const type for the constraints of a DiversityPolicy.
+type DiversityConstraint uint8
+
+const (
+	// SubnetConstraint is DiversityPolicy::MaxPeersPerSubnet.
+	SubnetConstraint DiversityConstraint = iota
+	// WideSubnetConstraint is DiversityPolicy::MaxPeersPerWideSubnet.
+	WideSubnetConstraint
+	// SourceShareConstraint is DiversityPolicy::MaxSourceShare.
+	SourceShareConstraint
+)
+
+func (constraint DiversityConstraint) String() string {
+	switch constraint {
+	case SubnetConstraint:
+		return "SubnetConstraint"
+	case WideSubnetConstraint:
+		return "WideSubnetConstraint"
+	case SourceShareConstraint:
+		return "SourceShareConstraint"
+	default:
+		return fmt.Sprintf("DiversityConstraint(%d)", uint8(constraint))
+	}
+}
+
+// DiversityStats counts how many peers each diversity constraint rejected,
+// which helps tuning the DiversityPolicy.
+type DiversityStats map[DiversityConstraint]uint64
+
+// subnetsOf returns the /24 and /16 subnets of an IPv4 address,
+// or the /48 and /32 subnets of an IPv6 address.
+func subnetsOf(ip net.IP) (subnet, wideSubnet string) {
+	bits, wideBits, size := 48, 32, 8*net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits, wideBits, size = ip4, 24, 16, 8*net.IPv4len
+	}
+	subnet = (&net.IPNet{IP: ip.Mask(net.CIDRMask(bits, size)), Mask: net.CIDRMask(bits, size)}).String()
+	wideSubnet = (&net.IPNet{IP: ip.Mask(net.CIDRMask(wideBits, size)), Mask: net.CIDRMask(wideBits, size)}).String()
+	return
+}
+
+// subnetSetsOf returns the sets of the subnets and the wide subnets of the IP addresses.
+func subnetSetsOf(ips []net.IP) (subnets, wideSubnets map[string]bool) {
+	subnets, wideSubnets = map[string]bool{}, map[string]bool{}
+	for _, ip := range ips {
+		subnet, wideSubnet := subnetsOf(ip)
+		subnets[subnet] = true
+		wideSubnets[wideSubnet] = true
+	}
+	return
+}
+
+// DiversityFilter applies a DiversityPolicy to a set of peers being built.
+// ALWAYS USE THE CONSTRUCTOR FOR A NEW DIVERSITY FILTER!
+type DiversityFilter struct {
+	policy *DiversityPolicy
+	// maxPerSource is the maximum number of peers from the same source.
+	maxPerSource int
+	subnets      map[string]int
+	wideSubnets  map[string]int
+	sources      map[Identity]int
+	stats        DiversityStats
+}
+
+// NewDiversityFilter is the constructor function for DiversityFilter. The
+// source share of the policy is applied to at most 'sourced' peers. Every
+// rejection is counted in stats.
+func NewDiversityFilter(policy *DiversityPolicy, sourced int, stats DiversityStats) *DiversityFilter {
+	maxPerSource := 0
+	if policy.MaxSourceShare != 0 {
+		maxPerSource = sourced * int(policy.MaxSourceShare) / 100
+		if maxPerSource < 1 {
+			maxPerSource = 1
+		}
+	}
+	return &DiversityFilter{
+		policy:       policy,
+		maxPerSource: maxPerSource,
+		subnets:      map[string]int{},
+		wideSubnets:  map[string]int{},
+		sources:      map[Identity]int{},
+		stats:        stats,
+	}
+}
+
+// Allow checks whether the peer, whose IP addresses are given, fits into
+// the constraints and records it if so. The source is the peer who sent
+// it, or the zero identity if the source share does not apply.
+func (filter *DiversityFilter) Allow(ips []net.IP, source Identity) (bool, DiversityConstraint) {
+	subnets, wideSubnets := subnetSetsOf(ips)
+	reject := func(constraint DiversityConstraint) (bool, DiversityConstraint) {
+		filter.stats[constraint]++
+		return false, constraint
+	}
+	if max := int(filter.policy.MaxPeersPerSubnet); max != 0 {
+		for subnet := range subnets {
+			if filter.subnets[subnet] >= max {
+				return reject(SubnetConstraint)
+			}
+		}
+	}
+	if max := int(filter.policy.MaxPeersPerWideSubnet); max != 0 {
+		for wideSubnet := range wideSubnets {
+			if filter.wideSubnets[wideSubnet] >= max {
+				return reject(WideSubnetConstraint)
+			}
+		}
+	}
+	if filter.maxPerSource != 0 && source != (Identity{}) && filter.sources[source] >= filter.maxPerSource {
+		return reject(SourceShareConstraint)
+	}
+	filter.Record(ips, source)
+	return true, 0
+}
+
+// Record records the peer, whose IP addresses are given, without checking
+// the constraints, e.g. for the peers already accepted earlier.
+func (filter *DiversityFilter) Record(ips []net.IP, source Identity) {
+	subnets, wideSubnets := subnetSetsOf(ips)
+	for subnet := range subnets {
+		filter.subnets[subnet]++
+	}
+	for wideSubnet := range wideSubnets {
+		filter.wideSubnets[wideSubnet]++
+	}
+	if source != (Identity{}) {
+		filter.sources[source]++
+	}
+}
+
+// AllowPeer is the same as Allow for the advertised addresses of the peer.
+func (filter *DiversityFilter) AllowPeer(peer Peer, source Identity) (bool, DiversityConstraint) {
+	ips := make([]net.IP, 0, len(peer.Addrs))
+	for _, addr := range peer.Addrs {
+		if tcpAddr, err := utils.ParseIPPort(addr); err == nil {
+			ips = append(ips, tcpAddr.IP)
+		}
+	}
+	return filter.Allow(ips, source)
+}
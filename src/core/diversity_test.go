@@ -0,0 +1,81 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDiversityFilterSubnets(t *testing.T) {
+	policy := &DiversityPolicy{MaxPeersPerSubnet: 2, MaxPeersPerWideSubnet: 3}
+	stats := DiversityStats{}
+	filter := NewDiversityFilter(policy, 0, stats)
+
+	tests := []struct {
+		ip         string
+		allowed    bool
+		constraint DiversityConstraint
+	}{
+		{"10.0.1.1", true, 0},
+		{"10.0.1.2", true, 0},
+		// The third peer of 10.0.1.0/24.
+		{"10.0.1.3", false, SubnetConstraint},
+		{"10.0.2.1", true, 0},
+		// The fourth peer of 10.0.0.0/16.
+		{"10.0.3.1", false, WideSubnetConstraint},
+		{"10.1.1.1", true, 0},
+		{"2001:db8:1::1", true, 0},
+		{"2001:db8:1::2", true, 0},
+		// The third peer of 2001:db8:1::/48.
+		{"2001:db8:1:ffff::3", false, SubnetConstraint},
+	}
+	for _, test := range tests {
+		allowed, constraint := filter.Allow([]net.IP{net.ParseIP(test.ip)}, Identity{})
+		if allowed != test.allowed || (!allowed && constraint != test.constraint) {
+			t.Errorf("Allow(%s) = %t, %s, want %t, %s", test.ip, allowed, constraint, test.allowed, test.constraint)
+		}
+	}
+	if stats[SubnetConstraint] != 2 || stats[WideSubnetConstraint] != 1 {
+		t.Errorf("stats = %v, want 2 subnet and 1 wide subnet rejections", stats)
+	}
+}
+
+func TestDiversityFilterSourceShare(t *testing.T) {
+	policy := &DiversityPolicy{MaxSourceShare: 50}
+	filter := NewDiversityFilter(policy, 4, DiversityStats{})
+	source := Identity{1}
+	for i := 0; i < 2; i++ {
+		if allowed, constraint := filter.Allow([]net.IP{net.IPv4(10, byte(i), 0, 1)}, source); !allowed {
+			t.Fatalf("Allow of peer %d from the source = false, %s, want true", i, constraint)
+		}
+	}
+	if allowed, constraint := filter.Allow([]net.IP{net.IPv4(10, 9, 0, 1)}, source); allowed || constraint != SourceShareConstraint {
+		t.Fatalf("Allow of a third peer from the source = %t, %s, want false, %s", allowed, constraint, SourceShareConstraint)
+	}
+	// The source share does not apply to the peers without a source.
+	if allowed, _ := filter.Allow([]net.IP{net.IPv4(10, 9, 0, 1)}, Identity{}); !allowed {
+		t.Fatalf("Allow of a peer without a source = false, want true")
+	}
+}
+
+func TestDiversityFilterRecordAndAllowPeer(t *testing.T) {
+	policy := &DiversityPolicy{MaxPeersPerSubnet: 1}
+	filter := NewDiversityFilter(policy, 0, DiversityStats{})
+	filter.Record([]net.IP{net.ParseIP("192.0.2.1")}, Identity{})
+	peer := Peer{ID: Identity{2}, Addrs: []string{"192.0.2.7:6001"}}
+	if allowed, constraint := filter.AllowPeer(peer, Identity{}); allowed || constraint != SubnetConstraint {
+		t.Fatalf("AllowPeer(%v) = %t, %s, want false, %s", peer, allowed, constraint, SubnetConstraint)
+	}
+	peer = Peer{ID: Identity{3}, Addrs: []string{"[2001:db8::1]:6001"}}
+	if allowed, _ := filter.AllowPeer(peer, Identity{}); !allowed {
+		t.Fatalf("AllowPeer(%v) = false, want true", peer)
+	}
+}
+
+func TestDiversityPolicyValidate(t *testing.T) {
+	if err := (&DiversityPolicy{MaxSourceShare: 100}).Validate(); err != nil {
+		t.Errorf("Validate of a 100%% source share = %v, want nil", err)
+	}
+	if err := (&DiversityPolicy{MaxSourceShare: 101}).Validate(); err == nil {
+		t.Errorf("Validate of a 101%% source share = nil, want an error")
+	}
+}
@@ -0,0 +1,378 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"sort"
+	"time"
+)
+
+// SWIMMemberState is the state of a member according to the failure detector.
+type SWIMMemberState uint8
+
+const (
+	// SWIMAlive means that the member is believed to be alive.
+	SWIMAlive SWIMMemberState = iota
+	// SWIMSuspect means that the member did not answer to a probe and
+	// will be declared as failed unless it refutes the suspicion in time.
+	SWIMSuspect
+	// SWIMFailed means that the failure of the member is confirmed.
+	SWIMFailed
+)
+
+func (state SWIMMemberState) String() string {
+	switch state {
+	case SWIMAlive:
+		return "alive"
+	case SWIMSuspect:
+		return "suspect"
+	case SWIMFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("SWIMMemberState(%d)", uint8(state))
+	}
+}
+
+// SWIMUpdate is a membership update disseminated by piggybacking on the
+// pings and the acks of the failure detector. Updates with a higher
+// incarnation number override the ones with a lower incarnation number,
+// and only the member itself can increase its own incarnation number.
+type SWIMUpdate struct {
+	// Peer is the member (with p2p listen address) the update is about.
+	Peer        Peer
+	State       SWIMMemberState
+	Incarnation uint64
+}
+
+// SWIMMemberInfo holds the state of a member monitored by the failure detector.
+type SWIMMemberInfo struct {
+	// peer is the member with its last known p2p listen address.
+	peer        Peer
+	state       SWIMMemberState
+	incarnation uint64
+	// suspectDeadline is the time the suspected member is declared as failed.
+	suspectDeadline time.Time
+}
+
+func (info *SWIMMemberInfo) String() string {
+	return fmt.Sprintf("{peer: %s, state: %s, incarnation: %d, suspectDeadline: %s}",
+		info.peer.Addrs, info.state, info.incarnation, info.suspectDeadline)
+}
+
+// SWIMProbeInfo holds the probe of the current protocol period.
+type SWIMProbeInfo struct {
+	target       Peer
+	seq          uint64
+	sentAt       time.Time
+	indirectSent bool
+	acked        bool
+}
+
+func (info *SWIMProbeInfo) String() string {
+	return fmt.Sprintf("{target: %s, seq: %d, sentAt: %s, indirectSent: %t, acked: %t}",
+		info.target.Addrs, info.seq, info.sentAt, info.indirectSent, info.acked)
+}
+
+// SWIMForwardInfo holds a ping sent on behalf of a remote peer who asked
+// for an indirect probe, so that its ack can be forwarded to the requester.
+type SWIMForwardInfo struct {
+	requester Peer
+	seq       uint64
+	expires   time.Time
+}
+
+func (info *SWIMForwardInfo) String() string {
+	return fmt.Sprintf("{requester: %s, seq: %d, expires: %s}", info.requester.Addrs, info.seq, info.expires)
+}
+
+// swimUpdateInfo holds an update to be disseminated and the number
+// of times it will still be piggybacked.
+type swimUpdateInfo struct {
+	update        SWIMUpdate
+	transmissions int
+}
+
+func (info *swimUpdateInfo) String() string {
+	return fmt.Sprintf("{update: %+v, transmissions: %d}", info.update, info.transmissions)
+}
+
+// FailureDetectorConfig holds the parameters of the failure detector.
+type FailureDetectorConfig struct {
+	// protocolPeriod is the duration of probing a single member.
+	protocolPeriod time.Duration
+	// pingTimeout is the duration to wait for an ack before asking
+	// other members for an indirect probe.
+	pingTimeout time.Duration
+	// indirectProbes is the number of members asked for an indirect probe.
+	indirectProbes int
+	// suspicionMult is multiplied by log(n) protocol periods to get the
+	// time a suspected member has for refuting the suspicion.
+	suspicionMult float64
+	// retransmitMult is multiplied by log(n) to get the number of
+	// times an update is piggybacked.
+	retransmitMult float64
+	// maxPiggyback is the maximum number of updates piggybacked on a message.
+	maxPiggyback int
+}
+
+// FailureDetector is the state of a SWIM-style failure detector as described
+// in the paper: https://www.cs.cornell.edu/projects/Quicksilver/public_pdfs/SWIM.pdf
+// Every protocol period, a single member is probed with a direct ping. If it
+// doesn't answer in time, then other members are asked to ping it. If it still
+// doesn't answer, then it is suspected and eventually declared as failed.
+// Suspicions and failures are disseminated by piggybacking on the pings and
+// the acks. It is not a goroutine on its own, it is driven by the Membership
+// controller, which sends its messages over the authenticated p2p connections.
+type FailureDetector struct {
+	config FailureDetectorConfig
+	// self is this node (with p2p listen address).
+	self Peer
+	// incarnation is the incarnation number of this node, which is
+	// increased for refuting any suspicion about this node.
+	incarnation uint64
+	// members is the map of the identities of monitored members to their states.
+	members map[Identity]*SWIMMemberInfo
+	// probeOrder is the randomly shuffled order in which members are probed.
+	probeOrder []Identity
+	// nextSeq is the sequence number of the next ping.
+	nextSeq uint64
+	// probe is the probe of the current protocol period, if there is one.
+	probe *SWIMProbeInfo
+	// forwards is the map of the sequence numbers of the pings sent on
+	// behalf of other members to the requesters.
+	forwards map[uint64]*SWIMForwardInfo
+	// updates is the map of the identities of members to their updates to be disseminated.
+	updates map[Identity]*swimUpdateInfo
+}
+
+// NewFailureDetector is the constructor function for struct type FailureDetector.
+func NewFailureDetector(config FailureDetectorConfig, self Peer) *FailureDetector {
+	return &FailureDetector{
+		config:   config,
+		self:     self,
+		members:  map[Identity]*SWIMMemberInfo{},
+		forwards: map[uint64]*SWIMForwardInfo{},
+		updates:  map[Identity]*swimUpdateInfo{},
+	}
+}
+
+// logN returns the logarithm of the number of monitored members, at least 1.
+func (fd *FailureDetector) logN() float64 {
+	return math.Max(1, math.Log2(float64(len(fd.members)+1)))
+}
+
+// suspicionTimeout returns the time a suspected member has for refuting the suspicion.
+func (fd *FailureDetector) suspicionTimeout() time.Duration {
+	return time.Duration(fd.config.suspicionMult * fd.logN() * float64(fd.config.protocolPeriod))
+}
+
+// disseminate is the method for piggybacking the update on the next messages.
+func (fd *FailureDetector) disseminate(update SWIMUpdate) {
+	fd.updates[update.Peer.ID] = &swimUpdateInfo{
+		update:        update,
+		transmissions: int(math.Ceil(fd.config.retransmitMult * fd.logN())),
+	}
+}
+
+// Track is the method for monitoring exactly the given set of peers, keyed
+// by their identities. New peers start as alive, while the peers not in the
+// set are forgotten. The addresses of the monitored peers are updated.
+func (fd *FailureDetector) Track(peers map[Identity]Peer) {
+	for id := range fd.members {
+		if _, isMember := peers[id]; !isMember {
+			delete(fd.members, id)
+		}
+	}
+	for id, peer := range peers {
+		if info, isMember := fd.members[id]; isMember {
+			info.peer = peer
+		} else if id != fd.self.ID {
+			fd.members[id] = &SWIMMemberInfo{peer: peer, state: SWIMAlive}
+		}
+	}
+}
+
+// Forget is the method for no longer monitoring the peer, e.g. since it
+// announced to leave the network, and for no longer disseminating updates
+// about it.
+func (fd *FailureDetector) Forget(peer Peer) {
+	delete(fd.members, peer.ID)
+	delete(fd.updates, peer.ID)
+}
+
+// IsSuspected returns true iff the peer is either suspected or failed.
+func (fd *FailureDetector) IsSuspected(peer Peer) bool {
+	info, isMember := fd.members[peer.ID]
+	return isMember && info.state != SWIMAlive
+}
+
+// NextSeq returns a fresh sequence number for a ping.
+func (fd *FailureDetector) NextSeq() uint64 {
+	fd.nextSeq++
+	return fd.nextSeq
+}
+
+// Probe returns the probe of the current protocol period, if there is one.
+func (fd *FailureDetector) Probe() *SWIMProbeInfo {
+	return fd.probe
+}
+
+// StartProbe is the method for starting the probe of the next member in
+// a round-robin fashion. Returns false if there is no member to probe.
+func (fd *FailureDetector) StartProbe(now time.Time) (*SWIMProbeInfo, bool) {
+	fd.probe = nil
+	for attempts := 0; attempts < 2; attempts++ {
+		for len(fd.probeOrder) > 0 {
+			target := fd.probeOrder[0]
+			fd.probeOrder = fd.probeOrder[1:]
+			if info, isMember := fd.members[target]; isMember && info.state != SWIMFailed {
+				fd.probe = &SWIMProbeInfo{target: info.peer, seq: fd.NextSeq(), sentAt: now}
+				return fd.probe, true
+			}
+		}
+		// Start a new round-robin in a new random order.
+		for id := range fd.members {
+			fd.probeOrder = append(fd.probeOrder, id)
+		}
+		mrand.Shuffle(len(fd.probeOrder), func(i, j int) {
+			fd.probeOrder[i], fd.probeOrder[j] = fd.probeOrder[j], fd.probeOrder[i]
+		})
+	}
+	return nil, false
+}
+
+// Ack is the method for handling an ack with the given sequence number.
+// Returns true iff it acknowledges the probe of the current protocol period.
+func (fd *FailureDetector) Ack(seq uint64) bool {
+	if fd.probe == nil || fd.probe.seq != seq || fd.probe.acked {
+		return false
+	}
+	fd.probe.acked = true
+	// A suspected member who answers to a probe is alive again.
+	if info, isMember := fd.members[fd.probe.target.ID]; isMember && info.state == SWIMSuspect {
+		info.state = SWIMAlive
+		info.suspectDeadline = time.Time{}
+	}
+	return true
+}
+
+// AddForward is the method for remembering a ping sent on behalf of a requester.
+func (fd *FailureDetector) AddForward(seq uint64, requester Peer, requesterSeq uint64, now time.Time) {
+	fd.forwards[seq] = &SWIMForwardInfo{requester: requester, seq: requesterSeq, expires: now.Add(fd.config.protocolPeriod)}
+}
+
+// TakeForward returns and forgets the requester of the ping with the given sequence number.
+func (fd *FailureDetector) TakeForward(seq uint64) (*SWIMForwardInfo, bool) {
+	info, isMember := fd.forwards[seq]
+	delete(fd.forwards, seq)
+	return info, isMember
+}
+
+// Suspect is the method for suspecting an alive member. Returns true iff
+// the member was alive.
+func (fd *FailureDetector) Suspect(peer Peer, now time.Time) bool {
+	info, isMember := fd.members[peer.ID]
+	if !isMember || info.state != SWIMAlive {
+		return false
+	}
+	info.state = SWIMSuspect
+	info.suspectDeadline = now.Add(fd.suspicionTimeout())
+	fd.disseminate(SWIMUpdate{Peer: info.peer, State: SWIMSuspect, Incarnation: info.incarnation})
+	return true
+}
+
+// Expire is the method for declaring the suspected members, who haven't
+// refuted the suspicion in time, as failed. It also forgets the old forwards.
+// Returns the failed members.
+func (fd *FailureDetector) Expire(now time.Time) []Peer {
+	failed := make([]Peer, 0)
+	for _, info := range fd.members {
+		if info.state == SWIMSuspect && now.After(info.suspectDeadline) {
+			info.state = SWIMFailed
+			fd.disseminate(SWIMUpdate{Peer: info.peer, State: SWIMFailed, Incarnation: info.incarnation})
+			failed = append(failed, info.peer)
+		}
+	}
+	for seq, info := range fd.forwards {
+		if now.After(info.expires) {
+			delete(fd.forwards, seq)
+		}
+	}
+	return failed
+}
+
+// Apply is the method for applying a disseminated update. Updates about
+// this node are refuted. Updates about unmonitored peers are ignored.
+// Returns the new state of the member iff the update changed it.
+func (fd *FailureDetector) Apply(update SWIMUpdate, now time.Time) (SWIMMemberState, bool) {
+	if update.Peer.ID == fd.self.ID {
+		if update.State != SWIMAlive && update.Incarnation >= fd.incarnation {
+			// Refute the suspicion by increasing the incarnation number.
+			fd.incarnation = update.Incarnation + 1
+			fd.disseminate(SWIMUpdate{Peer: fd.self, State: SWIMAlive, Incarnation: fd.incarnation})
+		}
+		return SWIMAlive, false
+	}
+	info, isMember := fd.members[update.Peer.ID]
+	if !isMember {
+		return SWIMAlive, false
+	}
+	if info.state == SWIMFailed {
+		return SWIMFailed, false
+	}
+	switch update.State {
+	case SWIMAlive:
+		if update.Incarnation <= info.incarnation {
+			return info.state, false
+		}
+		info.state = SWIMAlive
+		info.suspectDeadline = time.Time{}
+	case SWIMSuspect:
+		if update.Incarnation < info.incarnation ||
+			(update.Incarnation == info.incarnation && info.state == SWIMSuspect) {
+			return info.state, false
+		}
+		info.state = SWIMSuspect
+		info.suspectDeadline = now.Add(fd.suspicionTimeout())
+	case SWIMFailed:
+		if update.Incarnation < info.incarnation {
+			return info.state, false
+		}
+		info.state = SWIMFailed
+	default:
+		return info.state, false
+	}
+	info.incarnation = update.Incarnation
+	fd.disseminate(update)
+	return info.state, true
+}
+
+// Piggyback returns the updates to be piggybacked on the next message.
+// The updates sent the fewest times are preferred.
+func (fd *FailureDetector) Piggyback() []SWIMUpdate {
+	infos := make([]*swimUpdateInfo, 0, len(fd.updates))
+	for _, info := range fd.updates {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].transmissions > infos[j].transmissions
+	})
+	if len(infos) > fd.config.maxPiggyback {
+		infos = infos[:fd.config.maxPiggyback]
+	}
+	updates := make([]SWIMUpdate, 0, len(infos))
+	for _, info := range infos {
+		updates = append(updates, info.update)
+		info.transmissions--
+		if info.transmissions <= 0 {
+			delete(fd.updates, info.update.Peer.ID)
+		}
+	}
+	return updates
+}
+
+func (fd *FailureDetector) String() string {
+	return fmt.Sprintf("{config: %+v, incarnation: %d, members: %v, probe: %v, forwards: %v, updates: %v}",
+		fd.config, fd.incarnation, fd.members, fd.probe, fd.forwards, fd.updates)
+}
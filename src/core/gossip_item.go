@@ -43,11 +43,13 @@ type GossipItemState struct {
 // GossipItemInfoGossiper contains the current state of the corresponding
 // GossipItem and the list of peers to gossip this item. The
 // 'peerList' is going to be a random subset of the current view list.
-// This struct is meant to be used as a value in a
-// map[GossipItem]*GossipItemInfoGossiper by the Gossiper.
+// The 'source' is the peer who delivered the item, which is empty for
+// the items announced by local API clients. This struct is meant to be
+// used as a value in a map[GossipItem]*GossipItemInfoGossiper by the Gossiper.
 type GossipItemInfoGossiper struct {
 	s        GossipItemState
 	peerList []Peer
+	source   Peer
 }
 
 // Cmp compares 2 GossipItemState's by essentially checking if the 'state'
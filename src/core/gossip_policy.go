@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+)
+
+// GossipDataTypePolicy holds the rules for gossiping the items of a single
+// gossip item data type. A zero value of a field means that the default
+// behaviour of the Gossiper is used for that rule.
+type GossipDataTypePolicy struct {
+	// MaxPayloadSize is the maximum number of bytes in the data of an item.
+	// If it is 0, then there is no limit other than the api message size.
+	MaxPayloadSize uint16
+	// DefaultTTL is the time to live used when an announcement doesn't
+	// request a specific one. If it is 0, then MaxTTL is used.
+	DefaultTTL uint8
+	// MaxTTL is the maximum number of hops to propagate an item. If it is 0,
+	// then the global maximum TTL of the Gossiper is used.
+	MaxTTL uint8
+	// CacheShare is the maximum percentage of the Gossiper cache that
+	// the items of this data type may occupy. If it is 0, then 100 is used.
+	CacheShare uint8
+	// ValidateBeforeForward indicates whether an item received from a peer
+	// has to be marked valid by an API client before it is gossiped further.
+	ValidateBeforeForward bool
+	// RateLimit is the maximum number of new items accepted per gossip round,
+	// both from API clients and peers. If it is 0, then there is no limit.
+	RateLimit uint16
+	// Priority decides which items stay in the Gossiper cache. When the cache
+	// is full, a new item replaces an item with the lowest and strictly lower
+	// priority, if there is any.
+	Priority uint8
+}
+
+// Validate checks whether the policy is self-consistent.
+func (policy *GossipDataTypePolicy) Validate() error {
+	if policy.CacheShare > 100 {
+		return fmt.Errorf("cache share must be a percentage, not %d", policy.CacheShare)
+	}
+	if policy.MaxTTL != 0 && policy.DefaultTTL > policy.MaxTTL {
+		return fmt.Errorf("default TTL %d is larger than the maximum TTL %d", policy.DefaultTTL, policy.MaxTTL)
+	}
+	return nil
+}
+
+// resolve returns a copy of the policy where the zero values are replaced
+// with the default behaviour of a Gossiper with the given maximum TTL.
+func (policy GossipDataTypePolicy) resolve(maxTTL uint8) *GossipDataTypePolicy {
+	if policy.MaxTTL == 0 {
+		policy.MaxTTL = maxTTL
+	}
+	if policy.DefaultTTL == 0 || policy.DefaultTTL > policy.MaxTTL {
+		policy.DefaultTTL = policy.MaxTTL
+	}
+	if policy.CacheShare == 0 {
+		policy.CacheShare = 100
+	}
+	return &policy
+}
+
+// cacheQuota returns the maximum number of items of this data type
+// allowed in a cache of the given size. It is always at least 1.
+func (policy *GossipDataTypePolicy) cacheQuota(cacheSize uint16) int {
+	quota := int(cacheSize) * int(policy.CacheShare) / 100
+	if quota < 1 {
+		return 1
+	}
+	return quota
+}
+
+// allowsPayload returns true iff the data is not too large for this policy.
+func (policy *GossipDataTypePolicy) allowsPayload(data string) bool {
+	return policy.MaxPayloadSize == 0 || len(data) <= int(policy.MaxPayloadSize)
+}
+
+func (policy *GossipDataTypePolicy) String() string {
+	return fmt.Sprintf("%+v", *policy)
+}
@@ -1,11 +1,13 @@
 package core
 
 import (
+	"crypto"
 	"fmt"
 	"gossip/src/datastruct/set"
 	mathutils "gossip/src/utils/math"
 	"log"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -22,7 +24,20 @@ func init() {
 	gossiperControllerHandlers[GossipIncomingPushMSG] = (*Gossiper).incomingPushHandler
 	gossiperControllerHandlers[GossipIncomingPullRequestMSG] = (*Gossiper).incomingPullRequestHandler
 	gossiperControllerHandlers[GossipIncomingPullReplyMSG] = (*Gossiper).incomingPullReplyHandler
+	gossiperControllerHandlers[GossipRetractMSG] = (*Gossiper).retractHandler
+	gossiperControllerHandlers[GossipIncomingTombstoneMSG] = (*Gossiper).incomingTombstoneHandler
+	gossiperControllerHandlers[GossipKVPutMSG] = (*Gossiper).kvPutHandler
+	gossiperControllerHandlers[GossipKVGetMSG] = (*Gossiper).kvGetHandler
+	gossiperControllerHandlers[GossipKVSubscribeMSG] = (*Gossiper).kvSubscribeHandler
+	gossiperControllerHandlers[GossipKVIncomingDigestMSG] = (*Gossiper).kvIncomingDigestHandler
+	gossiperControllerHandlers[GossipKVIncomingDigestReplyMSG] = (*Gossiper).kvIncomingDigestReplyHandler
+	gossiperControllerHandlers[GossipKVIncomingUpdateMSG] = (*Gossiper).kvIncomingUpdateHandler
+	gossiperControllerHandlers[GossipDirectIncomingMSG] = (*Gossiper).directIncomingHandler
+	gossiperControllerHandlers[GossipAggregateIncomingPushMSG] = (*Gossiper).aggregateIncomingPushHandler
+	gossiperControllerHandlers[GossipAggregateContributeMSG] = (*Gossiper).aggregateContributeHandler
+	gossiperControllerHandlers[GossipAggregateQueryMSG] = (*Gossiper).aggregateQueryHandler
 	gossiperControllerHandlers[GossiperCloseMSG] = (*Gossiper).closeHandler
+	gossiperControllerHandlers[GossipAggregatePushFailedMSG] = (*Gossiper).aggregatePushFailedHandler
 }
 
 // MedianCounterConfig holds the configuration for the maximum counter
@@ -39,7 +54,22 @@ type GossiperNextRoundPullPeersType Peer
 
 // GossiperPullPeersType is the type of variable stored in
 // Gossiper::pullPeers.
-type GossiperPullPeersType Peer
+type GossiperPullPeersType Identity
+
+// GossiperKVSubscribersValueType is the type of variable stored in
+// the sets of Gossiper::kvSubscribers.
+type GossiperKVSubscribersValueType string
+
+// KVConfig holds the configuration for gossiping the replicated key-value state.
+type KVConfig struct {
+	// maxEntries is the maximum number of keys in the key-value state.
+	maxEntries int
+	// maxEntriesPerNode is the maximum number of keys in the key-value
+	// state whose latest entry is written by the same node.
+	maxEntriesPerNode int
+	// digestPeriod is the number of gossip rounds between 2 digest reconciliations.
+	digestPeriod uint
+}
 
 // Gossiper is the struct for the goroutine that will be exclusively responsible for handling all p2p Gossip
 // calls. It will both share items by Gossiping itself and it will also respond to the GossipPullRequest calls.
@@ -50,6 +80,12 @@ type Gossiper struct {
 	degree uint8
 	// maxTTL is the maximum number of hops to propagate any Gossip item.
 	maxTTL uint8
+	// configuredMaxTTL is the maximum TTL given by the configuration. If it
+	// is 0, then maxTTL is derived from the estimated network size.
+	configuredMaxTTL uint8
+	// sizeParams are the parameters derived from the estimated network size.
+	// They are derived again when the estimate changes enough.
+	sizeParams NetworkSizeParams
 	// roundPeriod is the time duration between each membership round.
 	roundPeriod time.Duration
 	// mcConfig is the configuration for the "median-counter algorithm".
@@ -67,11 +103,57 @@ type Gossiper struct {
 	incomingGossips map[GossipItem]*GossipItemInfoGossiper
 	// nextRoundPullPeers are peers to whom the Gossiper will make a pull request
 	// in the next gossip round.
-	nextRoundPullPeers set.Set
-	// pullPeers are peers to whom the Gossiper sent a gossip pull request and
+	nextRoundPullPeers *PeerSet
+	// pullPeers are identities of peers to whom the Gossiper sent a gossip pull request and
 	// is waiting for a pull reply. Any gossip pull reply from a peer outside of
 	// this set will be ignored!
 	pullPeers set.Set
+	// policies is the map of data types to their gossiping rules. Data
+	// types without a policy are gossiped according to defaultPolicy.
+	policies      map[GossipItemDataType]*GossipDataTypePolicy
+	defaultPolicy *GossipDataTypePolicy
+	// configuredPolicies are the policies given by the configuration, which
+	// are resolved again whenever maxTTL changes.
+	configuredPolicies map[GossipItemDataType]GossipDataTypePolicy
+	// rateCounters is the number of new items of each data type that are
+	// accepted since the last gossip round.
+	rateCounters map[GossipItemDataType]uint16
+	// awaitingValidation contains the gossip items received from peers whose
+	// data type has to be validated by an API client before being forwarded.
+	awaitingValidation map[GossipItem]*GossipItemInfoGossiper
+	// tombstones is the map of retracted gossip items to their tombstones.
+	// A retracted gossip item is never gossiped again until its tombstone
+	// expires.
+	tombstones map[GossipItemID]*GossipTombstoneInfoGossiper
+	// announcedItems is the map of the gossip items announced by the API
+	// clients of this node to the time they were announced. Only these items
+	// can be retracted by this node, and only by this node, until they are
+	// forgotten after tombstoneRetention.
+	announcedItems map[GossipItemID]time.Time
+	// identity is the identity of this node, used for checking the
+	// issuers of the tombstones of the items announced by this node.
+	identity Identity
+	// hostKey is the host key of this node for signing the key-value
+	// entries written by this node.
+	hostKey crypto.Signer
+	// kvConfig is the configuration for the replicated key-value state.
+	kvConfig KVConfig
+	// kvStore is the replicated key-value state.
+	kvStore *KVStore
+	// kvHotKeys is the map of recently changed keys to the number of
+	// gossip rounds they will still be pushed to random peers.
+	kvHotKeys map[string]uint8
+	// kvSubscribers is the map of API clients to the set of key prefixes
+	// they want to be notified about.
+	kvSubscribers map[APIClient]set.Set
+	// kvDigestPeers are the identities of peers to whom the Gossiper sent its digest and
+	// is waiting for a digest reply. Any other digest reply will be ignored!
+	kvDigestPeers set.Set
+	// kvRoundCounter is the number of gossip rounds since the last digest reconciliation.
+	kvRoundCounter uint
+	// aggregator estimates the network size and the aggregates of
+	// the values contributed by the API clients.
+	aggregator *Aggregator
 	// MsgInQueue is the incoming message queue for
 	// the Gossiper goroutine.
 	MsgInQueue chan InternalMessage
@@ -80,28 +162,84 @@ type Gossiper struct {
 	MsgOutQueue chan InternalMessage
 }
 
-// NewGossiper is the constructor function for the Gossiper struct.
-func NewGossiper(cacheSize uint16, degree, maxTTL uint8, roundPeriod time.Duration, maxPeers float64,
+// NewGossiper is the constructor function for the Gossiper struct. If maxTTL
+// is 0, then the maximum TTL is derived from the estimated network size.
+func NewGossiper(cacheSize uint16, degree, maxTTL uint8, roundPeriod time.Duration, sizeParams NetworkSizeParams,
+	policies map[GossipItemDataType]GossipDataTypePolicy, identity Identity, hostKey crypto.Signer,
 	inQ, outQ chan InternalMessage,
 ) (*Gossiper, error) {
-	denominator := math.Log2(math.Max(2, float64(degree)))
-	logN := math.Log2(maxPeers) / denominator
-	loglogN := uint8(math.Max(1, math.Ceil(math.Log2(logN)/denominator)))
-	return &Gossiper{
+	for dataType, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid policy for data type %d: %s", dataType, err)
+		}
+	}
+	// Hard-coded parameters for the replicated key-value state.
+	kvConfig := KVConfig{maxEntries: 4096, maxEntriesPerNode: 256, digestPeriod: 5}
+	// Hard-coded parameters for the aggregation.
+	aggregationConfig := AggregationConfig{numMins: 32, epochDuration: 30 * roundPeriod}
+	gossiper := &Gossiper{
 		cacheSize:          cacheSize,
 		degree:             degree,
-		maxTTL:             maxTTL,
+		configuredMaxTTL:   maxTTL,
 		roundPeriod:        roundPeriod,
-		mcConfig:           MedianCounterConfig{bMax: loglogN, cMax: loglogN},
 		gossipList:         map[GossipItem]*GossipItemInfoGossiper{},
 		oldGossipList:      map[GossipItem]*GossipItemInfoGossiper{},
 		apiClientsToNotify: map[APIClient]*APIClientInfoGossiper{},
 		incomingGossips:    map[GossipItem]*GossipItemInfoGossiper{},
-		nextRoundPullPeers: set.New(),
+		nextRoundPullPeers: NewPeerSet(),
 		pullPeers:          set.New(),
+		configuredPolicies: policies,
+		rateCounters:       map[GossipItemDataType]uint16{},
+		awaitingValidation: map[GossipItem]*GossipItemInfoGossiper{},
+		tombstones:         map[GossipItemID]*GossipTombstoneInfoGossiper{},
+		announcedItems:     map[GossipItemID]time.Time{},
+		identity:           identity,
+		hostKey:            hostKey,
+		kvConfig:           kvConfig,
+		kvStore:            NewKVStore(kvConfig.maxEntries, kvConfig.maxEntriesPerNode),
+		kvHotKeys:          map[string]uint8{},
+		kvSubscribers:      map[APIClient]set.Set{},
+		kvDigestPeers:      set.New(),
+		aggregator:         NewAggregator(aggregationConfig),
 		MsgInQueue:         inQ,
 		MsgOutQueue:        outQ,
-	}, nil
+	}
+	gossiper.applySizeParams(sizeParams)
+	return gossiper, nil
+}
+
+// applySizeParams is the method for deriving the maximum TTL, unless it is
+// configured, and the parameters of the "median-counter algorithm" from the
+// parameters for the estimated network size. The gossip items being spread
+// keep their TTL's.
+func (gossiper *Gossiper) applySizeParams(params NetworkSizeParams) {
+	gossiper.sizeParams = params
+	gossiper.maxTTL = gossiper.configuredMaxTTL
+	if gossiper.maxTTL == 0 {
+		gossiper.maxTTL = params.MaxTTL
+	}
+	gossiper.mcConfig = MedianCounterConfig{bMax: params.CounterMax, cMax: params.CounterMax}
+	gossiper.policies = map[GossipItemDataType]*GossipDataTypePolicy{}
+	for dataType, policy := range gossiper.configuredPolicies {
+		gossiper.policies[dataType] = policy.resolve(gossiper.maxTTL)
+	}
+	gossiper.defaultPolicy = GossipDataTypePolicy{}.resolve(gossiper.maxTTL)
+}
+
+// networkSizeRound is the method for deriving new parameters once the
+// estimated network size has changed enough since they were derived last,
+// and for letting the Central controller know about them.
+func (gossiper *Gossiper) networkSizeRound() {
+	estimate := gossiper.aggregator.SizeEstimate()
+	if !gossiper.sizeParams.NeedsUpdate(estimate) {
+		return
+	}
+	params := NewNetworkSizeParams(estimate, gossiper.degree)
+	log.Println("Gossiper: the network size is estimated as", math.Round(estimate), "peers, so the new parameters are", params)
+	gossiper.applySizeParams(params)
+	payload := GossipNetworkSizeMSGPayload(params)
+	log.Println("Gossiper -> Central controller, GossipNetworkSizeMSG,", payload)
+	gossiper.MsgOutQueue <- InternalMessage{Type: GossipNetworkSizeMSG, Payload: payload}
 }
 
 // recover method tries to catch a panic in controllerRoutine if it exists, then
@@ -129,6 +267,111 @@ func (gossiper *Gossiper) recover() {
 	}
 }
 
+// policyOf returns the gossiping rules for the given data type.
+func (gossiper *Gossiper) policyOf(dataType GossipItemDataType) *GossipDataTypePolicy {
+	if policy, isMember := gossiper.policies[dataType]; isMember {
+		return policy
+	}
+	return gossiper.defaultPolicy
+}
+
+// consumeRate counts a new item of the given data type against its rate
+// limit. Returns false if the rate limit was already reached in this round.
+func (gossiper *Gossiper) consumeRate(dataType GossipItemDataType) bool {
+	policy := gossiper.policyOf(dataType)
+	if policy.RateLimit != 0 && gossiper.rateCounters[dataType] >= policy.RateLimit {
+		return false
+	}
+	gossiper.rateCounters[dataType]++
+	return true
+}
+
+// retireItem moves the item from gossipList into oldGossipList and
+// releases the peers allocated to it.
+func (gossiper *Gossiper) retireItem(item *GossipItem) {
+	// Release peers allocated to this gossip item.
+	releasedPeers := gossiper.gossipList[*item].peerList
+	payload := RandomPeerListReleaseMSGPayload{releasedPeers}
+	log.Println("Gossiper -> Central controller, RandomPeerListReleaseMSG,", payload)
+	gossiper.MsgOutQueue <- InternalMessage{
+		Type:    RandomPeerListReleaseMSG,
+		Payload: payload,
+	}
+	// Remove the item from gossipList into oldGossipList.
+	delete(gossiper.gossipList, *item)
+	// Keep the old gossip items for maxTTL gossip rounds, then remove them entirely.
+	gossiper.oldGossipList[*item] = &GossipItemInfoGossiper{
+		s: GossipItemState{state: MedianCounterStateD, ttl: gossiper.policyOf(item.DataType).MaxTTL},
+	}
+}
+
+// makeRoomFor checks whether the item can be added into gossipList according
+// to the cache share and the priority of its data type. If the cache is full,
+// an item with the lowest and strictly lower priority is retired to make room.
+func (gossiper *Gossiper) makeRoomFor(item *GossipItem) bool {
+	policy := gossiper.policyOf(item.DataType)
+	sameTypeCount := 0
+	for otherItem := range gossiper.gossipList {
+		if otherItem.DataType == item.DataType {
+			sameTypeCount++
+		}
+	}
+	if sameTypeCount >= policy.cacheQuota(gossiper.cacheSize) {
+		return false
+	}
+	if len(gossiper.gossipList) < int(gossiper.cacheSize) {
+		return true
+	}
+	var victim *GossipItem = nil
+	lowestPriority := policy.Priority
+	for otherItem := range gossiper.gossipList {
+		if otherPriority := gossiper.policyOf(otherItem.DataType).Priority; otherPriority < lowestPriority {
+			lowestPriority = otherPriority
+			victimItem := otherItem
+			victim = &victimItem
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	gossiper.retireItem(victim)
+	return true
+}
+
+// addToGossipList is the method for starting to gossip an item received from
+// a peer with the given state, if there is room for it in gossipList.
+func (gossiper *Gossiper) addToGossipList(item GossipItem, info *GossipItemInfoGossiper) {
+	if !gossiper.makeRoomFor(&item) {
+		return
+	}
+	policy := gossiper.policyOf(item.DataType)
+	switch info.s.state {
+	case MedianCounterStateB:
+		gossiper.gossipList[item] = &GossipItemInfoGossiper{
+			s:      GossipItemState{state: MedianCounterStateB, counter: 1, medianRule: 0, ttl: policy.MaxTTL},
+			source: info.source,
+		}
+		// Ask for (degree * maxTTL) random peers for this gossip item.
+		payload := RandomPeerListRequestMSGPayload{Related: &item, Num: int(gossiper.degree) * int(policy.MaxTTL)}
+		log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload)
+		gossiper.MsgOutQueue <- InternalMessage{
+			Type:    RandomPeerListRequestMSG,
+			Payload: payload}
+	case MedianCounterStateC:
+		gossiper.gossipList[item] = &GossipItemInfoGossiper{
+			s:      GossipItemState{state: MedianCounterStateC, counter: 0, medianRule: 0, ttl: policy.MaxTTL},
+			source: info.source,
+		}
+		// Ask for (degree * cMax) random peers for this gossip item, since it cannot be gossiped
+		// for more than cMax more gossip rounds in state C.
+		payload := RandomPeerListRequestMSGPayload{Related: &item, Num: int(gossiper.degree) * int(gossiper.mcConfig.cMax)}
+		log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload)
+		gossiper.MsgOutQueue <- InternalMessage{
+			Type:    RandomPeerListRequestMSG,
+			Payload: payload}
+	}
+}
+
 // pushRound is the method for performing gossip push
 // during a gossip round.
 func (gossiper *Gossiper) pushRound() {
@@ -168,35 +411,36 @@ func (gossiper *Gossiper) pushRound() {
 	}
 	// Remove the items to be removed into oldGossipList.
 	for _, itemToRemove := range itemsToRemove {
-		// Release peers allocated to this gossip item.
-		releasedPeers := gossiper.gossipList[*itemToRemove].peerList
-		payload := RandomPeerListReleaseMSGPayload{releasedPeers}
-		log.Println("Gossiper -> Central controller, RandomPeerListReleaseMSG,", payload)
-		gossiper.MsgOutQueue <- InternalMessage{
-			Type:    RandomPeerListReleaseMSG,
-			Payload: payload,
-		}
-		// Remove the item from gossipList into oldGossipList.
-		delete(gossiper.gossipList, *itemToRemove)
-		// Keep the old gossip items for maxTTL gossip rounds, then remove them entirely.
-		gossiper.oldGossipList[*itemToRemove] = &GossipItemInfoGossiper{
-			s: GossipItemState{state: MedianCounterStateD, ttl: gossiper.maxTTL},
-		}
+		gossiper.retireItem(itemToRemove)
 	}
 }
 
 // pullRound is the method for performing gossip pull requests
 // during a gossip round.
 func (gossiper *Gossiper) pullRound() {
-	for elem := range gossiper.nextRoundPullPeers.Iterate() {
-		peer := elem.(Peer)
-
+	// Tombstones are pushed to the same random peers as the pull requests.
+	tombstones := make([]*GossipTombstone, 0)
+	for _, info := range gossiper.tombstones {
+		if info.ttl > 0 {
+			tombstones = append(tombstones, info.tombstone)
+		}
+	}
+	for _, peer := range gossiper.nextRoundPullPeers.Peers() {
 		// Send the pull request message to the Central controller.
 		log.Println("Gossiper -> Central controller, GossipPullRequestMSG,", peer)
 		gossiper.MsgOutQueue <- InternalMessage{Type: GossipPullRequestMSG, Payload: peer}
+		// Send the tombstones to be spread to the Central controller.
+		if len(tombstones) > 0 {
+			payload := GossipTombstonePushMSGPayload{Tombstones: tombstones, To: peer}
+			log.Println("Gossiper -> Central controller, GossipTombstonePushMSG,", payload)
+			gossiper.MsgOutQueue <- InternalMessage{Type: GossipTombstonePushMSG, Payload: payload}
+		}
 	}
-	gossiper.pullPeers = gossiper.nextRoundPullPeers
-	gossiper.nextRoundPullPeers = set.New()
+	gossiper.pullPeers = set.New()
+	for _, peer := range gossiper.nextRoundPullPeers.Peers() {
+		gossiper.pullPeers.Add(peer.ID)
+	}
+	gossiper.nextRoundPullPeers = NewPeerSet()
 	// Ask from the Central controller for more pull peer for the next round.
 	payload := RandomPeerListRequestMSGPayload{Related: nil, Num: int(gossiper.degree)}
 	log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload)
@@ -207,8 +451,10 @@ func (gossiper *Gossiper) pullRound() {
 }
 
 // notifyClients is the method for notifying clients that are interested
-// in the given gossip item. DON'T GIVE nil GOSSIP ITEM!!!
-func (gossiper *Gossiper) notifyClients(item *GossipItem) {
+// in the given gossip item delivered by the source peer. Returns the number
+// of notified clients. DON'T GIVE nil GOSSIP ITEM!!!
+func (gossiper *Gossiper) notifyClients(item *GossipItem, source Peer) int {
+	notified := 0
 	// Inform any client of this new gossip item if they are interested.
 	for client, cInfo := range gossiper.apiClientsToNotify {
 		if cInfo.notifyDataTypes.IsMember(item.DataType) {
@@ -218,10 +464,12 @@ func (gossiper *Gossiper) notifyClients(item *GossipItem) {
 			gossiper.MsgOutQueue <- InternalMessage{
 				Type:    GossipNotificationMSG,
 				Payload: payload}
-			cInfo.validationMap[cInfo.nextAvailableID] = item
+			cInfo.validationMap[cInfo.nextAvailableID] = &GossipItemExtended{Item: item, From: source}
 			cInfo.nextAvailableID++
+			notified++
 		}
 	}
+	return notified
 }
 
 // updateRound is the method for updating the old gossip list with the
@@ -237,35 +485,20 @@ func (gossiper *Gossiper) updateRound() {
 		// if I already have the incoming gossip item, just update my own state.
 		if myInfo, isMember := gossiper.gossipList[item]; isMember {
 			myInfo.UpdateItemInfo(info)
-		} else {
+		} else if _, isMember := gossiper.awaitingValidation[item]; !isMember {
 			// Inform any client of this new gossip item if they are interested.
-			gossiper.notifyClients(&item)
-			// If we have space for new gossip items, add it.
-			if len(gossiper.gossipList) < int(gossiper.cacheSize) {
-				switch info.s.state {
-				case MedianCounterStateB:
-					gossiper.gossipList[item] = &GossipItemInfoGossiper{
-						s: GossipItemState{state: MedianCounterStateB, counter: 1, medianRule: 0, ttl: gossiper.maxTTL},
-					}
-					// Ask for (degree * maxTTL) random peers for this gossip item.
-					payload := RandomPeerListRequestMSGPayload{Related: &item, Num: int(gossiper.degree) * int(gossiper.maxTTL)}
-					log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload)
-					gossiper.MsgOutQueue <- InternalMessage{
-						Type:    RandomPeerListRequestMSG,
-						Payload: payload}
-				case MedianCounterStateC:
-					gossiper.gossipList[item] = &GossipItemInfoGossiper{
-						s: GossipItemState{state: MedianCounterStateC, counter: 0, medianRule: 0, ttl: gossiper.maxTTL},
-					}
-					// Ask for (degree * cMax) random peers for this gossip item, since it cannot be gossiped
-					// for more than cMax more gossip rounds in state C.
-					payload := RandomPeerListRequestMSGPayload{Related: &item, Num: int(gossiper.degree) * int(gossiper.mcConfig.cMax)}
-					log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload)
-					gossiper.MsgOutQueue <- InternalMessage{
-						Type:    RandomPeerListRequestMSG,
-						Payload: payload}
+			notified := gossiper.notifyClients(&item, info.source)
+			if gossiper.policyOf(item.DataType).ValidateBeforeForward {
+				// Hold the item back until a client validates it. If there is no
+				// client to validate it, then the item is not forwarded at all.
+				if notified > 0 {
+					info.s.ttl = gossiper.policyOf(item.DataType).MaxTTL
+					gossiper.awaitingValidation[item] = info
 				}
+				continue
 			}
+			// If we have space for new gossip items, add it.
+			gossiper.addToGossipList(item, info)
 		}
 	}
 	// Reset incomingGossips.
@@ -287,8 +520,31 @@ func (gossiper *Gossiper) updateRound() {
 }
 
 // updateOldGossipsRound is the method for reducing the time to live of
-// all old gossips and remove them if it reaches 0.
+// all old gossips and remove them if it reaches 0. The same is done for
+// the gossips that are still waiting for a validation.
 func (gossiper *Gossiper) updateOldGossipsRound() {
+	// Stop spreading the tombstones whose ttl reaches 0 and
+	// forget about the expired tombstones.
+	now := time.Now()
+	for id, info := range gossiper.tombstones {
+		if info.ttl > 0 {
+			info.ttl--
+		}
+		if now.After(info.expires) {
+			delete(gossiper.tombstones, id)
+		}
+	}
+	for id, announced := range gossiper.announcedItems {
+		if now.Sub(announced) > tombstoneRetention {
+			delete(gossiper.announcedItems, id)
+		}
+	}
+	for item, info := range gossiper.awaitingValidation {
+		info.s.ttl--
+		if info.s.ttl == 0 {
+			delete(gossiper.awaitingValidation, item)
+		}
+	}
 	itemsToRemove := make([]*GossipItem, 0)
 	for oldItem, info := range gossiper.oldGossipList {
 		info.s.ttl--
@@ -305,11 +561,15 @@ func (gossiper *Gossiper) updateOldGossipsRound() {
 // gossipRound is a method for executing 1 round of gossip exchange.
 // It is only executed periodically.
 func (gossiper *Gossiper) gossipRound() {
+	gossiper.kvRound()
+	gossiper.aggregationRound()
 	gossiper.pushRound()
 	gossiper.pullRound()
 	gossiper.updateRound()
 
 	gossiper.updateOldGossipsRound()
+	// Start counting the rate limits from scratch.
+	gossiper.rateCounters = map[GossipItemDataType]uint16{}
 }
 
 // randomPeerListReplyHandler is the method called by controllerRoutine for when
@@ -349,13 +609,19 @@ func (gossiper *Gossiper) announceHandler(payload AnyMessage) error {
 	if anno.Item == nil {
 		return nil
 	}
-	// Inform any client of this new gossip item if they are interested.
-	gossiper.notifyClients(anno.Item)
-	// If gossipList doesn't have space for new gossip items,
-	// then don't even consider the item.
-	if len(gossiper.gossipList) >= int(gossiper.cacheSize) {
+	policy := gossiper.policyOf(anno.Item.DataType)
+	// Make sure the gossip item is not too large for its data type.
+	if !policy.allowsPayload(anno.Item.Data) {
+		log.Println("Gossiper: announced item of data type", anno.Item.DataType, "is too large")
 		return nil
 	}
+	// A retracted gossip item cannot be announced again.
+	if gossiper.isRetracted(anno.Item) {
+		log.Println("Gossiper: announced item", anno.Item.ID(), "is retracted")
+		return nil
+	}
+	// Inform any client of this new gossip item if they are interested.
+	gossiper.notifyClients(anno.Item, Peer{})
 	// If the gossip item to announce is old OR if the
 	// gossip item is already in the gossipList, then ignore it.
 	_, isMember := gossiper.oldGossipList[*anno.Item]
@@ -363,17 +629,23 @@ func (gossiper *Gossiper) announceHandler(payload AnyMessage) error {
 	if isMember || isMember2 {
 		return nil
 	}
+	// If the data type is announced too often OR if gossipList doesn't
+	// have space for the new gossip item, then don't even consider the item.
+	if !gossiper.consumeRate(anno.Item.DataType) || !gossiper.makeRoomFor(anno.Item) {
+		return nil
+	}
 	// Calculate the TTL for the gossip item.
-	ttl := gossiper.maxTTL
+	ttl := policy.DefaultTTL
 	if anno.TTL != 0 {
 		ttl = anno.TTL
-		if ttl > gossiper.maxTTL {
-			ttl = gossiper.maxTTL
+		if ttl > policy.MaxTTL {
+			ttl = policy.MaxTTL
 		}
 	}
 	// Add the gossip item into the list of new gossips.
 	gossiper.gossipList[*anno.Item] = &GossipItemInfoGossiper{
 		s: GossipItemState{state: MedianCounterStateB, counter: 1, medianRule: 0, ttl: ttl}}
+	gossiper.announcedItems[anno.Item.ID()] = time.Now()
 	// Ask for (degree * ttl) random peers for this gossip item.
 	payload2 := RandomPeerListRequestMSGPayload{Related: anno.Item, Num: int(gossiper.degree) * int(ttl)}
 	log.Println("Gossiper -> Central controller, RandomPeerListRequestMSG,", payload2)
@@ -398,7 +670,7 @@ func (gossiper *Gossiper) notifyHandler(payload AnyMessage) error {
 		// If the client is not registered, register it.
 		gossiper.apiClientsToNotify[ntf.Who] = &APIClientInfoGossiper{
 			notifyDataTypes: set.New().Add(ntf.What),
-			validationMap:   map[uint16]*GossipItem{},
+			validationMap:   map[uint16]*GossipItemExtended{},
 			nextAvailableID: 0}
 	}
 
@@ -413,10 +685,22 @@ func (gossiper *Gossiper) unnotifyHandler(payload AnyMessage) error {
 		return nil
 	}
 	delete(gossiper.apiClientsToNotify, client)
+	delete(gossiper.kvSubscribers, client)
+	gossiper.aggregator.RemoveClient(client)
 
 	return nil
 }
 
+// reportPeer is the method for informing the Central controller
+// about a peer who violated the gossip protocol.
+func (gossiper *Gossiper) reportPeer(peer Peer, reason PeerMisbehaviour) {
+	payload := GossipPeerMisbehavedMSGPayload{Peer: peer, Reason: reason}
+	log.Println("Gossiper -> Central controller, GossipPeerMisbehavedMSG,", payload)
+	gossiper.MsgOutQueue <- InternalMessage{
+		Type:    GossipPeerMisbehavedMSG,
+		Payload: payload}
+}
+
 // validationHandler is the method called by controllerRoutine for when
 // it receives an internal message of type GossipValidationMSG.
 func (gossiper *Gossiper) validationHandler(payload AnyMessage) error {
@@ -428,12 +712,26 @@ func (gossiper *Gossiper) validationHandler(payload AnyMessage) error {
 	// and was sent the corresponding GOSSIP NOTIFICATION, then process it.
 	// Otherwise, ignore the validation call.
 	if info, isMember := gossiper.apiClientsToNotify[val.Who]; isMember {
-		if item, isMember := info.validationMap[val.ID]; isMember {
-			if !val.Valid {
-				delete(gossiper.gossipList, *item)
+		if itemExt, isMember := info.validationMap[val.ID]; isMember {
+			item := itemExt.Item
+			_, isOld := gossiper.oldGossipList[*item]
+			pendingInfo, isPending := gossiper.awaitingValidation[*item]
+			if val.Valid && isPending {
+				// The item is finally allowed to be forwarded.
+				delete(gossiper.awaitingValidation, *item)
+				gossiper.addToGossipList(*item, pendingInfo)
+			} else if !val.Valid && !isOld {
+				if _, isMember := gossiper.gossipList[*item]; isMember {
+					gossiper.retireItem(item)
+				}
 				delete(gossiper.incomingGossips, *item)
+				delete(gossiper.awaitingValidation, *item)
 				gossiper.oldGossipList[*item] = &GossipItemInfoGossiper{
-					s: GossipItemState{state: MedianCounterStateD, ttl: gossiper.maxTTL},
+					s: GossipItemState{state: MedianCounterStateD, ttl: gossiper.policyOf(item.DataType).MaxTTL},
+				}
+				// Let the peer who delivered the invalid item suffer the consequences.
+				if itemExt.From.ID != (Identity{}) {
+					gossiper.reportPeer(itemExt.From, InvalidGossipItem)
 				}
 			}
 			delete(info.validationMap, val.ID)
@@ -453,6 +751,13 @@ func (gossiper *Gossiper) checkAndAddIncomingGossip(itemExt *GossipItemExtended)
 	if itemExt.Item == nil {
 		return fmt.Errorf("itemExt.Item is nil")
 	}
+	policy := gossiper.policyOf(itemExt.Item.DataType)
+	if !policy.allowsPayload(itemExt.Item.Data) {
+		return fmt.Errorf("itemExt.Item is too large for its data type")
+	}
+	if gossiper.isRetracted(itemExt.Item) {
+		return fmt.Errorf("itemExt.Item is retracted")
+	}
 	var newInfo *GossipItemInfoGossiper = nil
 	switch itemExt.State {
 	case MedianCounterStateB:
@@ -462,7 +767,8 @@ func (gossiper *Gossiper) checkAndAddIncomingGossip(itemExt *GossipItemExtended)
 					state:      MedianCounterStateB,
 					counter:    itemExt.Counter,
 					medianRule: 0,
-					ttl:        gossiper.maxTTL}}
+					ttl:        policy.MaxTTL},
+				source: itemExt.From}
 		}
 	case MedianCounterStateC:
 		if itemExt.Counter < gossiper.mcConfig.cMax {
@@ -471,7 +777,8 @@ func (gossiper *Gossiper) checkAndAddIncomingGossip(itemExt *GossipItemExtended)
 					state:      MedianCounterStateC,
 					counter:    0,
 					medianRule: 0,
-					ttl:        gossiper.maxTTL}}
+					ttl:        policy.MaxTTL},
+				source: itemExt.From}
 		}
 	}
 	if newInfo != nil {
@@ -482,6 +789,12 @@ func (gossiper *Gossiper) checkAndAddIncomingGossip(itemExt *GossipItemExtended)
 				gossiper.incomingGossips[*itemExt.Item] = newInfo
 			}
 		} else {
+			// If the gossip item is new to us, then count it against the rate limit.
+			_, isOld := gossiper.oldGossipList[*itemExt.Item]
+			_, isKnown := gossiper.gossipList[*itemExt.Item]
+			if !isOld && !isKnown && !gossiper.consumeRate(itemExt.Item.DataType) {
+				return fmt.Errorf("rate limit of itemExt.Item data type is reached")
+			}
 			// If the gossip item is not in the incomingGossips, just add it.
 			gossiper.incomingGossips[*itemExt.Item] = newInfo
 			return nil
@@ -534,7 +847,8 @@ func (gossiper *Gossiper) incomingPullReplyHandler(payload AnyMessage) error {
 		return nil
 	}
 	// Check whether we actually asked for this pull reply.
-	if !gossiper.pullPeers.IsMember(reply.From) {
+	if !gossiper.pullPeers.IsMember(reply.From.ID) {
+		gossiper.reportPeer(reply.From, UnsolicitedPullReply)
 		return nil
 	}
 	// Process the incoming pull reply.
@@ -544,7 +858,357 @@ func (gossiper *Gossiper) incomingPullReplyHandler(payload AnyMessage) error {
 			upTo--
 		}
 	}
-	gossiper.pullPeers.Remove(reply.From)
+	gossiper.pullPeers.Remove(reply.From.ID)
+
+	return nil
+}
+
+// isRetracted returns true iff there is a tombstone for the gossip item.
+func (gossiper *Gossiper) isRetracted(item *GossipItem) bool {
+	if len(gossiper.tombstones) == 0 {
+		return false
+	}
+	_, isMember := gossiper.tombstones[item.ID()]
+	return isMember
+}
+
+// retract is the method for storing a new tombstone, removing the
+// retracted gossip item from every list and notifying the interested
+// clients. Returns false if the tombstone was already known.
+func (gossiper *Gossiper) retract(tombstone *GossipTombstone) bool {
+	if _, isMember := gossiper.tombstones[tombstone.ItemID]; isMember {
+		return false
+	}
+	gossiper.tombstones[tombstone.ItemID] = &GossipTombstoneInfoGossiper{
+		tombstone: tombstone,
+		ttl:       gossiper.policyOf(tombstone.DataType).MaxTTL,
+		expires:   time.Now().Add(tombstoneRetention),
+	}
+	// Remove the retracted gossip item. The tombstone suppresses
+	// it from now on, so it is not kept in oldGossipList either.
+	for item := range gossiper.gossipList {
+		if item.ID() == tombstone.ItemID {
+			gossiper.retireItem(&item)
+		}
+	}
+	for item := range gossiper.oldGossipList {
+		if item.ID() == tombstone.ItemID {
+			delete(gossiper.oldGossipList, item)
+		}
+	}
+	for item := range gossiper.incomingGossips {
+		if item.ID() == tombstone.ItemID {
+			delete(gossiper.incomingGossips, item)
+		}
+	}
+	for item := range gossiper.awaitingValidation {
+		if item.ID() == tombstone.ItemID {
+			delete(gossiper.awaitingValidation, item)
+		}
+	}
+	// Inform any client of this retraction if they are interested.
+	for client, cInfo := range gossiper.apiClientsToNotify {
+		if cInfo.notifyDataTypes.IsMember(tombstone.DataType) {
+			payload := GossipRetractionMSGPayload{Who: client, Tombstone: tombstone}
+			log.Println("Gossiper -> Central controller, GossipRetractionMSG,", payload)
+			gossiper.MsgOutQueue <- InternalMessage{
+				Type:    GossipRetractionMSG,
+				Payload: payload}
+		}
+	}
+	return true
+}
+
+// retractHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipRetractMSG.
+func (gossiper *Gossiper) retractHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipRetractMSGPayload)
+	if !ok || msg.Tombstone == nil {
+		return nil
+	}
+	// Only the items announced by the API clients of this node can be retracted by it.
+	if _, isMember := gossiper.announcedItems[msg.Tombstone.ItemID]; !isMember {
+		log.Println("Gossiper: item", msg.Tombstone.ItemID, "to retract is not announced by this node")
+		return nil
+	}
+	gossiper.retract(msg.Tombstone)
+
+	return nil
+}
+
+// incomingTombstoneHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipIncomingTombstoneMSG.
+func (gossiper *Gossiper) incomingTombstoneHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipIncomingTombstoneMSGPayload)
+	if !ok {
+		return nil
+	}
+	for _, tombstone := range msg.Tombstones {
+		// The items announced by the API clients of this node can only be retracted by it.
+		if _, isMember := gossiper.announcedItems[tombstone.ItemID]; isMember {
+			if issuer, err := tombstone.IssuerID(); err != nil || issuer != gossiper.identity {
+				log.Println("Gossiper: tombstone of item", tombstone.ItemID, "announced by this node is issued by another peer")
+				continue
+			}
+		}
+		gossiper.retract(tombstone)
+	}
+
+	return nil
+}
+
+// kvRound is the method for pushing the recently changed key-value entries
+// and for starting a digest reconciliation once every few gossip rounds.
+// It uses the same random peers as the pull requests of this gossip round.
+func (gossiper *Gossiper) kvRound() {
+	hotKeys := make([]string, 0, len(gossiper.kvHotKeys))
+	for key, ttl := range gossiper.kvHotKeys {
+		hotKeys = append(hotKeys, key)
+		if ttl <= 1 {
+			delete(gossiper.kvHotKeys, key)
+		} else {
+			gossiper.kvHotKeys[key] = ttl - 1
+		}
+	}
+	if len(hotKeys) > 0 {
+		entries := gossiper.kvStore.Entries(hotKeys)
+		for _, peer := range gossiper.nextRoundPullPeers.Peers() {
+			payload := GossipKVUpdateMSGPayload{To: peer, Entries: entries}
+			log.Println("Gossiper -> Central controller, GossipKVUpdateMSG,", payload)
+			gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVUpdateMSG, Payload: payload}
+		}
+	}
+	gossiper.kvRoundCounter++
+	if gossiper.kvRoundCounter < gossiper.kvConfig.digestPeriod {
+		return
+	}
+	gossiper.kvRoundCounter = 0
+	gossiper.kvDigestPeers = set.New()
+	// Reconcile with a single random peer.
+	for _, peer := range gossiper.nextRoundPullPeers.Peers() {
+		gossiper.kvDigestPeers.Add(peer.ID)
+		payload := GossipKVDigestMSGPayload{To: peer, Digest: gossiper.kvStore.Digest()}
+		log.Println("Gossiper -> Central controller, GossipKVDigestMSG,", peer)
+		gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVDigestMSG, Payload: payload}
+		break
+	}
+}
+
+// kvChanged is the method for pushing the changed key-value entry in the
+// next gossip rounds and for notifying the subscribed API clients.
+func (gossiper *Gossiper) kvChanged(entry *KVEntry) {
+	gossiper.kvHotKeys[entry.Key] = gossiper.maxTTL
+	for client, prefixes := range gossiper.kvSubscribers {
+		for elem := range prefixes.Iterate() {
+			if strings.HasPrefix(entry.Key, string(elem.(GossiperKVSubscribersValueType))) {
+				payload := GossipKVValueMSGPayload{Who: client, Key: entry.Key, Entry: entry, IsChange: true}
+				log.Println("Gossiper -> Central controller, GossipKVValueMSG,", payload)
+				gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVValueMSG, Payload: payload}
+				break
+			}
+		}
+	}
+}
+
+// kvMergeEntries is the method for merging the entries received from a peer.
+func (gossiper *Gossiper) kvMergeEntries(entries []*KVEntry) {
+	for _, entry := range entries {
+		if gossiper.kvStore.Merge(entry) {
+			gossiper.kvChanged(entry)
+		}
+	}
+}
+
+// kvPutHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVPutMSG.
+func (gossiper *Gossiper) kvPutHandler(payload AnyMessage) error {
+	put, ok := payload.(GossipKVPutMSGPayload)
+	if !ok {
+		return nil
+	}
+	entry, err := gossiper.kvStore.Put(put.Key, put.Value, gossiper.hostKey)
+	if err != nil {
+		return err
+	}
+	gossiper.kvChanged(entry)
+
+	return nil
+}
+
+// kvGetHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVGetMSG.
+func (gossiper *Gossiper) kvGetHandler(payload AnyMessage) error {
+	get, ok := payload.(GossipKVGetMSGPayload)
+	if !ok {
+		return nil
+	}
+	entry, _ := gossiper.kvStore.Get(get.Key)
+	payload2 := GossipKVValueMSGPayload{Who: get.Who, Key: get.Key, Entry: entry, IsChange: false}
+	log.Println("Gossiper -> Central controller, GossipKVValueMSG,", payload2)
+	gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVValueMSG, Payload: payload2}
+
+	return nil
+}
+
+// kvSubscribeHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVSubscribeMSG.
+func (gossiper *Gossiper) kvSubscribeHandler(payload AnyMessage) error {
+	sub, ok := payload.(GossipKVSubscribeMSGPayload)
+	if !ok {
+		return nil
+	}
+	if prefixes, isMember := gossiper.kvSubscribers[sub.Who]; isMember {
+		prefixes.Add(GossiperKVSubscribersValueType(sub.Prefix))
+	} else {
+		gossiper.kvSubscribers[sub.Who] = set.New().Add(GossiperKVSubscribersValueType(sub.Prefix))
+	}
+
+	return nil
+}
+
+// kvIncomingDigestHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVIncomingDigestMSG.
+func (gossiper *Gossiper) kvIncomingDigestHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVIncomingDigestMSGPayload)
+	if !ok {
+		return nil
+	}
+	newer, request := gossiper.kvStore.Reconcile(msg.Digest)
+	if len(newer) == 0 && len(request) == 0 {
+		return nil
+	}
+	payload2 := GossipKVDigestReplyMSGPayload{To: msg.From, Entries: newer, Request: request}
+	log.Println("Gossiper -> Central controller, GossipKVDigestReplyMSG,", payload2)
+	gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVDigestReplyMSG, Payload: payload2}
+
+	return nil
+}
+
+// kvIncomingDigestReplyHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVIncomingDigestReplyMSG.
+func (gossiper *Gossiper) kvIncomingDigestReplyHandler(payload AnyMessage) error {
+	reply, ok := payload.(GossipKVIncomingDigestReplyMSGPayload)
+	if !ok {
+		return nil
+	}
+	// Check whether we actually sent our digest to this peer.
+	if !gossiper.kvDigestPeers.IsMember(reply.From.ID) {
+		gossiper.reportPeer(reply.From, UnsolicitedPullReply)
+		return nil
+	}
+	gossiper.kvDigestPeers.Remove(reply.From.ID)
+	gossiper.kvMergeEntries(reply.Entries)
+	// Send the entries the remote peer is missing.
+	if entries := gossiper.kvStore.Entries(reply.Request); len(entries) > 0 {
+		payload2 := GossipKVUpdateMSGPayload{To: reply.From, Entries: entries}
+		log.Println("Gossiper -> Central controller, GossipKVUpdateMSG,", payload2)
+		gossiper.MsgOutQueue <- InternalMessage{Type: GossipKVUpdateMSG, Payload: payload2}
+	}
+
+	return nil
+}
+
+// kvIncomingUpdateHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipKVIncomingUpdateMSG.
+func (gossiper *Gossiper) kvIncomingUpdateHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipKVIncomingUpdateMSGPayload)
+	if !ok {
+		return nil
+	}
+	gossiper.kvMergeEntries(msg.Entries)
+
+	return nil
+}
+
+// directIncomingHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipDirectIncomingMSG.
+func (gossiper *Gossiper) directIncomingHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipDirectIncomingMSGPayload)
+	if !ok || msg.Message == nil {
+		return nil
+	}
+	// Deliver the directed message to any client interested in its data type.
+	for client, cInfo := range gossiper.apiClientsToNotify {
+		if cInfo.notifyDataTypes.IsMember(msg.Message.DataType) {
+			payload2 := GossipDirectNotificationMSGPayload{Who: client, Message: msg.Message}
+			log.Println("Gossiper -> Central controller, GossipDirectNotificationMSG,", client, msg.Message)
+			gossiper.MsgOutQueue <- InternalMessage{
+				Type:    GossipDirectNotificationMSG,
+				Payload: payload2}
+		}
+	}
+
+	return nil
+}
+
+// aggregationRound is the method for pushing half of the aggregation state
+// to a single random peer of the pull requests of this gossip round.
+func (gossiper *Gossiper) aggregationRound() {
+	gossiper.aggregator.Round()
+	gossiper.networkSizeRound()
+	for _, peer := range gossiper.nextRoundPullPeers.Peers() {
+		payload := GossipAggregatePushMSGPayload{To: peer, State: gossiper.aggregator.Push()}
+		log.Println("Gossiper -> Central controller, GossipAggregatePushMSG,", payload.To)
+		gossiper.MsgOutQueue <- InternalMessage{Type: GossipAggregatePushMSG, Payload: payload}
+		break
+	}
+}
+
+// aggregateIncomingPushHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipAggregateIncomingPushMSG.
+func (gossiper *Gossiper) aggregateIncomingPushHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipAggregateIncomingPushMSGPayload)
+	if !ok || msg.State == nil {
+		return nil
+	}
+	if err := gossiper.aggregator.Merge(msg.State); err != nil {
+		log.Println("Gossiper: invalid aggregation state from", msg.From, err)
+		gossiper.reportPeer(msg.From, MalformedMessage)
+	}
+
+	return nil
+}
+
+// aggregatePushFailedHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipAggregatePushFailedMSG.
+func (gossiper *Gossiper) aggregatePushFailedHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipAggregatePushFailedMSGPayload)
+	if !ok || msg.State == nil {
+		return nil
+	}
+	gossiper.aggregator.Restore(msg.State)
+
+	return nil
+}
+
+// aggregateContributeHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipAggregateContributeMSG.
+func (gossiper *Gossiper) aggregateContributeHandler(payload AnyMessage) error {
+	msg, ok := payload.(GossipAggregateContributeMSGPayload)
+	if !ok {
+		return nil
+	}
+	gossiper.aggregator.Contribute(msg.Who, msg.ID, msg.Value)
+
+	return nil
+}
+
+// aggregateQueryHandler is the method called by controllerRoutine for when
+// it receives an internal message of type GossipAggregateQueryMSG.
+func (gossiper *Gossiper) aggregateQueryHandler(payload AnyMessage) error {
+	query, ok := payload.(GossipAggregateQueryMSGPayload)
+	if !ok {
+		return nil
+	}
+	estimate, _ := gossiper.aggregator.Estimate(query.ID)
+	payload2 := GossipAggregateEstimateMSGPayload{
+		Who:          query.Who,
+		ID:           query.ID,
+		SizeEstimate: gossiper.aggregator.SizeEstimate(),
+		Estimate:     estimate,
+	}
+	log.Println("Gossiper -> Central controller, GossipAggregateEstimateMSG,", payload2)
+	gossiper.MsgOutQueue <- InternalMessage{Type: GossipAggregateEstimateMSG, Payload: payload2}
 
 	return nil
 }
@@ -609,6 +1273,8 @@ func (gossiper *Gossiper) String() string {
 		"\tcacheSize: %d,\n" +
 		"\tdegree: %d,\n" +
 		"\tmaxTTL: %d,\n" +
+		"\tconfiguredMaxTTL: %d,\n" +
+		"\tsizeParams: %s,\n" +
 		"\troundPeriod: %s,\n" +
 		"\tmcConfig: %v,\n" +
 		"\tgossipList: %s,\n" +
@@ -617,11 +1283,25 @@ func (gossiper *Gossiper) String() string {
 		"\tincomingGossips: %s,\n" +
 		"\tnextRoundPullPeers: %s,\n" +
 		"\tpullPeers: %s,\n" +
+		"\tpolicies: %v,\n" +
+		"\tconfiguredPolicies: %v,\n" +
+		"\tawaitingValidation: %s,\n" +
+		"\ttombstones: %s,\n" +
+		"\tannouncedItems: %v,\n" +
+		"\tidentity: %s,\n" +
+		"\tkvConfig: %v,\n" +
+		"\tkvStore: %s,\n" +
+		"\tkvHotKeys: %v,\n" +
+		"\tkvSubscribers: %v,\n" +
+		"\tkvDigestPeers: %s,\n" +
+		"\taggregator: %s,\n" +
 		"}"
 	return fmt.Sprintf(reprFormat,
 		gossiper.cacheSize,
 		gossiper.degree,
 		gossiper.maxTTL,
+		gossiper.configuredMaxTTL,
+		gossiper.sizeParams,
 		gossiper.roundPeriod,
 		gossiper.mcConfig,
 		gossiper.gossipList,
@@ -630,5 +1310,17 @@ func (gossiper *Gossiper) String() string {
 		gossiper.incomingGossips,
 		gossiper.nextRoundPullPeers,
 		gossiper.pullPeers,
+		gossiper.policies,
+		gossiper.configuredPolicies,
+		gossiper.awaitingValidation,
+		gossiper.tombstones,
+		gossiper.announcedItems,
+		gossiper.identity,
+		gossiper.kvConfig,
+		gossiper.kvStore,
+		gossiper.kvHotKeys,
+		gossiper.kvSubscribers,
+		gossiper.kvDigestPeers,
+		gossiper.aggregator,
 	)
 }
@@ -95,6 +95,9 @@ type GossipItemExtended struct {
 	Item    *GossipItem
 	State   MedianCounterState
 	Counter uint8
+	// From is the peer who delivered the gossip item. It is always
+	// overwritten by the receiving p2p endpoint, so it cannot be forged.
+	From Peer
 }
 
 // GossipIncomingPushMSGPayload is the payload type of an InternalMessage
@@ -134,6 +137,201 @@ type GossipIncomingPullReplyMSGPayload struct {
 // with type GossiperCrashedMSG.
 type GossiperCrashedMSGPayload error
 
+// GossipPeerMisbehavedMSGPayload is the payload type of an InternalMessage
+// with type GossipPeerMisbehavedMSG.
+type GossipPeerMisbehavedMSGPayload PeerMisbehavedMSGPayload
+
+// GossipRetractMSGPayload is the payload type of an InternalMessage
+// with type GossipRetractMSG.
+type GossipRetractMSGPayload struct {
+	// Tombstone is the signed tombstone of the gossip item to be retracted.
+	Tombstone *GossipTombstone
+}
+
+// GossipTombstonePushMSGPayload is the payload type of an InternalMessage
+// with type GossipTombstonePushMSG.
+type GossipTombstonePushMSGPayload struct {
+	// Tombstones are the tombstones to be pushed.
+	Tombstones []*GossipTombstone
+	// To is the peer to push the tombstones.
+	To Peer
+}
+
+// GossipIncomingTombstoneMSGPayload is the payload type of an InternalMessage
+// with type GossipIncomingTombstoneMSG.
+type GossipIncomingTombstoneMSGPayload struct {
+	// From is the remote peer who pushed the tombstones.
+	From Peer
+	// Tombstones are the pushed tombstones.
+	Tombstones []*GossipTombstone
+}
+
+// GossipRetractionMSGPayload is the payload type of an InternalMessage
+// with type GossipRetractionMSG.
+type GossipRetractionMSGPayload struct {
+	// Who is the api client to be notified for the retraction.
+	Who APIClient
+	// Tombstone is the tombstone of the retracted gossip item.
+	Tombstone *GossipTombstone
+}
+
+// GossipKVPutMSGPayload is the payload type of an InternalMessage
+// with type GossipKVPutMSG.
+type GossipKVPutMSGPayload struct {
+	Key   string
+	Value string
+}
+
+// GossipKVGetMSGPayload is the payload type of an InternalMessage
+// with type GossipKVGetMSG.
+type GossipKVGetMSGPayload struct {
+	// Who is the api client who asked for the value.
+	Who APIClient
+	Key string
+}
+
+// GossipKVSubscribeMSGPayload is the payload type of an InternalMessage
+// with type GossipKVSubscribeMSG.
+type GossipKVSubscribeMSGPayload struct {
+	// Who is the api client to be notified about the changes.
+	Who APIClient
+	// Prefix is the prefix of the keys to be notified about. If it
+	// is empty, then the api client is notified about every key.
+	Prefix string
+}
+
+// GossipKVValueMSGPayload is the payload type of an InternalMessage
+// with type GossipKVValueMSG.
+type GossipKVValueMSGPayload struct {
+	// Who is the api client to send the value.
+	Who APIClient
+	Key string
+	// Entry is the entry of the key. If it is nil, then the key doesn't exist.
+	Entry *KVEntry
+	// IsChange is true iff the value is sent because the key has changed.
+	IsChange bool
+}
+
+// GossipKVDigestMSGPayload is the payload type of an InternalMessage
+// with type GossipKVDigestMSG.
+type GossipKVDigestMSGPayload struct {
+	// To is the peer to send the digest.
+	To Peer
+	// Digest is the version of every key in the key-value state.
+	Digest map[string]KVVersion
+}
+
+// GossipKVIncomingDigestMSGPayload is the payload type of an InternalMessage
+// with type GossipKVIncomingDigestMSG.
+type GossipKVIncomingDigestMSGPayload struct {
+	// From is the remote peer who sent the digest.
+	From   Peer
+	Digest map[string]KVVersion
+}
+
+// GossipKVDigestReplyMSGPayload is the payload type of an InternalMessage
+// with type GossipKVDigestReplyMSG.
+type GossipKVDigestReplyMSGPayload struct {
+	// To is the remote peer who sent the digest.
+	To Peer
+	// Entries are the entries which are newer than the ones in the digest.
+	Entries []*KVEntry
+	// Request is the list of keys which are older than the ones in the digest.
+	Request []string
+}
+
+// GossipKVIncomingDigestReplyMSGPayload is the payload type of an InternalMessage
+// with type GossipKVIncomingDigestReplyMSG.
+type GossipKVIncomingDigestReplyMSGPayload struct {
+	// From is the remote peer who replied to the digest.
+	From    Peer
+	Entries []*KVEntry
+	Request []string
+}
+
+// GossipKVUpdateMSGPayload is the payload type of an InternalMessage
+// with type GossipKVUpdateMSG.
+type GossipKVUpdateMSGPayload struct {
+	// To is the peer to send the entries.
+	To      Peer
+	Entries []*KVEntry
+}
+
+// GossipKVIncomingUpdateMSGPayload is the payload type of an InternalMessage
+// with type GossipKVIncomingUpdateMSG.
+type GossipKVIncomingUpdateMSGPayload struct {
+	// From is the remote peer who sent the entries.
+	From    Peer
+	Entries []*KVEntry
+}
+
+// GossipDirectIncomingMSGPayload is the payload type of an InternalMessage
+// with type GossipDirectIncomingMSG.
+type GossipDirectIncomingMSGPayload struct {
+	Message *DirectMessage
+}
+
+// GossipDirectNotificationMSGPayload is the payload type of an InternalMessage
+// with type GossipDirectNotificationMSG.
+type GossipDirectNotificationMSGPayload struct {
+	// Who is the api client to deliver the directed message.
+	Who     APIClient
+	Message *DirectMessage
+}
+
+// GossipAggregatePushMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregatePushMSG.
+type GossipAggregatePushMSGPayload struct {
+	// To is the peer to send the aggregation state.
+	To    Peer
+	State *AggregationState
+}
+
+// GossipAggregateIncomingPushMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregateIncomingPushMSG.
+type GossipAggregateIncomingPushMSGPayload struct {
+	// From is the remote peer who sent the aggregation state.
+	From  Peer
+	State *AggregationState
+}
+
+// GossipAggregateContributeMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregateContributeMSG.
+type GossipAggregateContributeMSGPayload struct {
+	// Who is the api client who contributes the value.
+	Who   APIClient
+	ID    AggregateID
+	Value float64
+}
+
+// GossipAggregateQueryMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregateQueryMSG.
+type GossipAggregateQueryMSGPayload struct {
+	// Who is the api client who asked for the estimates.
+	Who APIClient
+	ID  AggregateID
+}
+
+// GossipAggregateEstimateMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregateEstimateMSG.
+type GossipAggregateEstimateMSGPayload struct {
+	// Who is the api client to send the estimates.
+	Who          APIClient
+	ID           AggregateID
+	SizeEstimate float64
+	// Estimate is the estimate of the aggregate. If it is nil, then no
+	// value was contributed to the aggregate in the last completed epoch.
+	Estimate *AggregateEstimate
+}
+
+// GossipNetworkSizeMSGPayload is the payload type of an InternalMessage
+// with type GossipNetworkSizeMSG.
+type GossipNetworkSizeMSGPayload NetworkSizeParams
+
+// GossipAggregatePushFailedMSGPayload is the payload type of an InternalMessage
+// with type GossipAggregatePushFailedMSG.
+type GossipAggregatePushFailedMSGPayload GossipAggregatePushMSGPayload
+
 // GossiperCloseMSGPayload is the payload type of an InternalMessage
 // with type GossiperCloseMSG.
 type GossiperCloseMSGPayload void
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+)
+
+// Identity is the SHA-256 hash of the canonically encoded public key of a
+// node, either the PKCS #1 encoding of an RSA key or the 32 bytes of an
+// Ed25519 key. It is the same identity as the names of the trusted identity files.
+type Identity [sha256.Size]byte
+
+// IdentityOf returns the identity of the node with the given public key.
+// An error is returned for a key of an unsupported type.
+func IdentityOf(pubKey crypto.PublicKey) (Identity, error) {
+	id, err := securecomm.IdentityOf(pubKey)
+	if err != nil {
+		return Identity{}, err
+	}
+	return id, nil
+}
+
+func (id Identity) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// ParseIdentity parses the hex encoded identity of a node.
+func ParseIdentity(s string) (Identity, error) {
+	var id Identity
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return id, err
+	}
+	if len(b) != len(id) {
+		return id, fmt.Errorf("identity %q is not %d bytes long", s, len(id))
+	}
+	copy(id[:], b)
+	return id, nil
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+	"math"
+)
+
+const (
+	// kvEntryContext separates the signatures of key-value entries
+	// from any other signature made with the host keys.
+	kvEntryContext = "gossip kv entry"
+	// kvMaxCounter is the largest counter of a KVVersion. Entries with
+	// larger counters are rejected and a key with this counter cannot be
+	// written anymore, so that the counters never overflow.
+	kvMaxCounter uint64 = math.MaxUint32
+	// kvMaxCounterStep is the largest amount by which an entry can advance
+	// the counter of a key in the store, so that a single write cannot
+	// exhaust the counters of a key.
+	kvMaxCounterStep uint64 = 1 << 16
+)
+
+// KVVersion is the version of a key-value entry. Versions are ordered by
+// their counters first and then by the identities of the nodes who wrote
+// them, so that concurrent writes are resolved the same way on every node.
+type KVVersion struct {
+	Counter uint64
+	Node    Identity
+}
+
+// Cmp compares 2 KVVersion's. Returns 1 if 'lv' is newer than 'rv',
+// -1 if it is older and 0 if they are the same.
+func (lv *KVVersion) Cmp(rv *KVVersion) int {
+	if lv.Counter > rv.Counter {
+		return 1
+	} else if lv.Counter < rv.Counter {
+		return -1
+	}
+	return bytes.Compare(lv.Node[:], rv.Node[:])
+}
+
+// KVEntry is a single entry of the replicated key-value state, signed by
+// the node who wrote it, so that the entries relayed by other peers cannot
+// be forged.
+type KVEntry struct {
+	Key     string
+	Value   string
+	Version KVVersion
+	// Writer is the canonical encoding of the public key of the node
+	// who wrote the entry, whose identity is Version.Node.
+	Writer []byte
+	// Signature is the signature of the writer over the fields above.
+	Signature []byte
+}
+
+// signedBytes concatenates all the fields of the entry covered by the signature.
+func (entry *KVEntry) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(kvEntryContext)
+	binary.Write(&buf, binary.BigEndian, uint32(len(entry.Key)))
+	buf.WriteString(entry.Key)
+	binary.Write(&buf, binary.BigEndian, uint32(len(entry.Value)))
+	buf.WriteString(entry.Value)
+	binary.Write(&buf, binary.BigEndian, entry.Version.Counter)
+	buf.Write(entry.Version.Node[:])
+	buf.Write(entry.Writer)
+	return buf.Bytes()
+}
+
+// Verify checks that the counter of the entry is within kvMaxCounter,
+// that the entry is signed by its writer, whose identity is the node of
+// its version, and that the writer is one of the trusted identities,
+// which is not banned.
+func (entry *KVEntry) Verify(config *securecomm.Config) error {
+	if entry.Version.Counter == 0 || entry.Version.Counter > kvMaxCounter {
+		return fmt.Errorf("key-value entry has the counter %d", entry.Version.Counter)
+	}
+	writer, err := securecomm.ParsePublicKey(entry.Writer)
+	if err != nil {
+		return fmt.Errorf("key-value entry has %s", err)
+	}
+	writerID, err := securecomm.IdentityOf(writer)
+	if err != nil {
+		return err
+	}
+	if writerID != entry.Version.Node {
+		return fmt.Errorf("key-value entry of %s is written by %s", entry.Version.Node, Identity(writerID))
+	}
+	if err := securecomm.Verify(writer, crypto.SHA3_256, entry.signedBytes(), entry.Signature); err != nil {
+		return err
+	}
+	return securecomm.CheckIdentity(writer, config)
+}
+
+func (entry *KVEntry) String() string {
+	return fmt.Sprintf("{Key: %q, Counter: %d, Node: %s}", entry.Key, entry.Version.Counter, entry.Version.Node)
+}
+
+// KVStore is the replicated key-value state where every key is resolved
+// with the last-writer-wins rule according to its KVVersion.
+type KVStore struct {
+	entries map[string]*KVEntry
+	// maxEntries is the maximum number of keys to store. Entries of new
+	// keys are ignored when the store is full.
+	maxEntries int
+	// maxEntriesPerNode is the maximum number of keys whose latest
+	// entry is written by the same node, so that a single node cannot
+	// fill the store.
+	maxEntriesPerNode int
+	// nodeEntries is the number of keys whose latest entry is written
+	// by each node.
+	nodeEntries map[Identity]int
+}
+
+// NewKVStore is the constructor function for struct type KVStore.
+func NewKVStore(maxEntries, maxEntriesPerNode int) *KVStore {
+	return &KVStore{
+		entries:           map[string]*KVEntry{},
+		maxEntries:        maxEntries,
+		maxEntriesPerNode: maxEntriesPerNode,
+		nodeEntries:       map[Identity]int{},
+	}
+}
+
+// Get returns the entry of the key, if there is one.
+func (store *KVStore) Get(key string) (*KVEntry, bool) {
+	entry, isMember := store.entries[key]
+	return entry, isMember
+}
+
+// Put writes the value of the key signed with the host key of this node
+// with a version newer than any version of the key seen so far.
+func (store *KVStore) Put(key, value string, hostKey crypto.Signer) (*KVEntry, error) {
+	writer, err := securecomm.MarshalPublicKey(hostKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	node, err := securecomm.IdentityOf(hostKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	counter := uint64(1)
+	if myEntry, isMember := store.entries[key]; isMember {
+		if myEntry.Version.Counter >= kvMaxCounter {
+			return nil, fmt.Errorf("key %q has run out of versions", key)
+		}
+		counter = myEntry.Version.Counter + 1
+	}
+	entry := &KVEntry{Key: key, Value: value, Version: KVVersion{Counter: counter, Node: node}, Writer: writer}
+	if !store.fits(entry) {
+		return nil, fmt.Errorf("key-value state is full, cannot put %q", key)
+	}
+	signature, err := securecomm.Sign(hostKey, crypto.SHA3_256, entry.signedBytes())
+	if err != nil {
+		return nil, err
+	}
+	entry.Signature = signature
+	store.set(entry)
+	return entry, nil
+}
+
+// Merge applies the entry if it is newer than the one in the store, but
+// advances its counter by at most kvMaxCounterStep. The entry has to be
+// verified before. Returns true iff the entry was applied.
+func (store *KVStore) Merge(entry *KVEntry) bool {
+	if entry.Version.Counter > kvMaxCounter {
+		return false
+	}
+	if myEntry, isMember := store.entries[entry.Key]; isMember {
+		if entry.Version.Cmp(&myEntry.Version) <= 0 {
+			return false
+		}
+		if entry.Version.Counter-myEntry.Version.Counter > kvMaxCounterStep {
+			return false
+		}
+	}
+	if !store.fits(entry) {
+		return false
+	}
+	store.set(entry)
+	return true
+}
+
+// fits returns true iff the entry can be stored without exceeding
+// maxEntries and maxEntriesPerNode.
+func (store *KVStore) fits(entry *KVEntry) bool {
+	myEntry, isMember := store.entries[entry.Key]
+	if !isMember && len(store.entries) >= store.maxEntries {
+		return false
+	}
+	if isMember && myEntry.Version.Node == entry.Version.Node {
+		return true
+	}
+	return store.nodeEntries[entry.Version.Node] < store.maxEntriesPerNode
+}
+
+// set stores the entry, replacing the one of the same key.
+func (store *KVStore) set(entry *KVEntry) {
+	if myEntry, isMember := store.entries[entry.Key]; isMember {
+		if store.nodeEntries[myEntry.Version.Node]--; store.nodeEntries[myEntry.Version.Node] == 0 {
+			delete(store.nodeEntries, myEntry.Version.Node)
+		}
+	}
+	store.nodeEntries[entry.Version.Node]++
+	store.entries[entry.Key] = entry
+}
+
+// Digest returns the versions of all the keys in the store.
+func (store *KVStore) Digest() map[string]KVVersion {
+	digest := make(map[string]KVVersion, len(store.entries))
+	for key, entry := range store.entries {
+		digest[key] = entry.Version
+	}
+	return digest
+}
+
+// Reconcile compares the store with the digest of a remote store. Returns
+// the entries that are newer in this store and the keys that are newer in
+// the remote store.
+func (store *KVStore) Reconcile(digest map[string]KVVersion) (newer []*KVEntry, request []string) {
+	newer = make([]*KVEntry, 0)
+	request = make([]string, 0)
+	for key, entry := range store.entries {
+		if version, isMember := digest[key]; !isMember || entry.Version.Cmp(&version) > 0 {
+			newer = append(newer, entry)
+		}
+	}
+	for key, version := range digest {
+		if entry, isMember := store.entries[key]; !isMember || entry.Version.Cmp(&version) < 0 {
+			request = append(request, key)
+		}
+	}
+	return newer, request
+}
+
+// Entries returns the entries of the given keys which are in the store.
+func (store *KVStore) Entries(keys []string) []*KVEntry {
+	entries := make([]*KVEntry, 0, len(keys))
+	for _, key := range keys {
+		if entry, isMember := store.entries[key]; isMember {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (store *KVStore) String() string {
+	return fmt.Sprint(store.entries)
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"gossip/src/crypto/securecomm"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestTrustConfig returns a config trusting the identities of the host keys.
+// The returned function removes the folder of the trusted identities.
+func newTestTrustConfig(t *testing.T, hostKeys ...crypto.Signer) (*securecomm.Config, func()) {
+	dir, err := ioutil.TempDir("", "trusted_identities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hostKey := range hostKeys {
+		id := identityOfTest(t, hostKey)
+		if err := ioutil.WriteFile(filepath.Join(dir, id.String()), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &securecomm.Config{TrustedIdentitiesPath: dir}, func() { os.RemoveAll(dir) }
+}
+
+func TestKVStorePutAndMerge(t *testing.T) {
+	hostKey := newTestHostKey(t)
+	store := NewKVStore(10, 10)
+	first, err := store.Put("a", "1", hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Put("a", "2", hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Version.Counter != 1 || second.Version.Counter != 2 {
+		t.Fatalf("counters = %d, %d, want 1, 2", first.Version.Counter, second.Version.Counter)
+	}
+
+	remote := NewKVStore(10, 10)
+	if !remote.Merge(second) {
+		t.Fatalf("Merge of a new key = false, want true")
+	}
+	if remote.Merge(first) {
+		t.Fatalf("Merge of an older entry = true, want false")
+	}
+	if remote.Merge(second) {
+		t.Fatalf("Merge of the same entry = true, want false")
+	}
+	if entry, _ := remote.Get("a"); entry.Value != "2" {
+		t.Fatalf("Get(a) = %q, want %q", entry.Value, "2")
+	}
+
+	// Concurrent writes of the same counter are resolved by the node.
+	concurrent, err := NewKVStore(10, 10).Put("a", "3", newTestHostKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	concurrent.Version.Counter = second.Version.Counter
+	newer := bytes.Compare(concurrent.Version.Node[:], second.Version.Node[:]) > 0
+	if remote.Merge(concurrent) != newer {
+		t.Fatalf("Merge of a concurrent write = %t, want %t", !newer, newer)
+	}
+}
+
+func TestKVStoreReconcile(t *testing.T) {
+	hostKey := newTestHostKey(t)
+	local, remote := NewKVStore(10, 10), NewKVStore(10, 10)
+	local.Put("both", "old", hostKey)
+	newer, _ := local.Put("both", "new", hostKey)
+	remote.Merge(newer)
+	local.Put("local", "x", hostKey)
+	entry, _ := NewKVStore(10, 10).Put("remote", "y", hostKey)
+	remote.Merge(entry)
+
+	entries, request := local.Reconcile(remote.Digest())
+	if len(entries) != 1 || entries[0].Key != "local" {
+		t.Fatalf("Reconcile sends %v, want the entry of %q", entries, "local")
+	}
+	if len(request) != 1 || request[0] != "remote" {
+		t.Fatalf("Reconcile requests %v, want %q", request, "remote")
+	}
+}
+
+func TestKVStoreCounterLimits(t *testing.T) {
+	hostKey := newTestHostKey(t)
+	store := NewKVStore(10, 10)
+	entry, err := store.Put("a", "1", hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jump := *entry
+	jump.Version.Counter = entry.Version.Counter + kvMaxCounterStep + 1
+	if store.Merge(&jump) {
+		t.Fatalf("Merge of an entry advancing the counter by more than kvMaxCounterStep = true, want false")
+	}
+	frozen := *entry
+	frozen.Key = "b"
+	frozen.Version.Counter = math.MaxUint64
+	if store.Merge(&frozen) {
+		t.Fatalf("Merge of an entry with the counter MaxUint64 = true, want false")
+	}
+
+	last := *entry
+	last.Key = "c"
+	last.Version.Counter = kvMaxCounter
+	if !store.Merge(&last) {
+		t.Fatalf("Merge of an entry with the counter kvMaxCounter = false, want true")
+	}
+	if _, err := store.Put("c", "2", hostKey); err == nil {
+		t.Fatalf("Put of a key with the counter kvMaxCounter = nil error, want an error")
+	}
+}
+
+func TestKVStoreEntryLimits(t *testing.T) {
+	hostKey, otherKey := newTestHostKey(t), newTestHostKey(t)
+	store := NewKVStore(3, 2)
+	if _, err := store.Put("a", "1", hostKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put("b", "1", hostKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put("c", "1", hostKey); err == nil {
+		t.Fatalf("Put of a third key of the same node = nil error, want an error")
+	}
+	// The keys of a node can still be overwritten.
+	if _, err := store.Put("a", "2", hostKey); err != nil {
+		t.Fatalf("Put of an existing key = %v", err)
+	}
+
+	entry, _ := NewKVStore(3, 2).Put("c", "1", otherKey)
+	if !store.Merge(entry) {
+		t.Fatalf("Merge of a key of another node = false, want true")
+	}
+	entry, _ = NewKVStore(3, 2).Put("d", "1", otherKey)
+	if store.Merge(entry) {
+		t.Fatalf("Merge into a full store = true, want false")
+	}
+
+	// Another node overwriting a key of the node moves the key to it.
+	overwrite := NewKVStore(3, 2)
+	for _, value := range []string{"x", "y", "z"} {
+		entry, _ = overwrite.Put("a", value, otherKey)
+	}
+	if !store.Merge(entry) {
+		t.Fatalf("Merge of a newer entry of another node = false, want true")
+	}
+	if _, err := store.Put("c2", "1", hostKey); err == nil {
+		t.Fatalf("Put into a full store = nil error, want an error")
+	}
+	if id := identityOfTest(t, hostKey); store.nodeEntries[id] != 1 {
+		t.Fatalf("node has %d entries, want 1", store.nodeEntries[id])
+	}
+}
+
+func TestKVEntryVerify(t *testing.T) {
+	hostKey, untrustedKey := newTestHostKey(t), newTestHostKey(t)
+	config, cleanup := newTestTrustConfig(t, hostKey)
+	defer cleanup()
+
+	entry, err := NewKVStore(10, 10).Put("key", "value", hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Verify(config); err != nil {
+		t.Fatalf("Verify of a valid entry = %v", err)
+	}
+	untrusted, err := NewKVStore(10, 10).Put("key", "value", untrustedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := untrusted.Verify(config); err == nil {
+		t.Fatalf("Verify of an entry of an untrusted node = nil, want an error")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(entry *KVEntry)
+	}{
+		{"altered value", func(entry *KVEntry) { entry.Value = "forged" }},
+		{"altered counter", func(entry *KVEntry) { entry.Version.Counter++ }},
+		{"zero counter", func(entry *KVEntry) { entry.Version.Counter = 0 }},
+		{"counter MaxUint64", func(entry *KVEntry) { entry.Version.Counter = math.MaxUint64 }},
+		{"other node", func(entry *KVEntry) { entry.Version.Node = Identity{1} }},
+		{"other writer", func(entry *KVEntry) { entry.Writer = untrusted.Writer }},
+		{"no signature", func(entry *KVEntry) { entry.Signature = nil }},
+	}
+	for _, test := range tests {
+		modified := *entry
+		test.modify(&modified)
+		if err := modified.Verify(config); err == nil {
+			t.Errorf("Verify of an entry with %s = nil, want an error", test.name)
+		}
+	}
+}
@@ -1,20 +1,23 @@
 package core
 
 import (
+	"context"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"fmt"
 	"gossip/src/crypto/cipher/ecb"
+	"gossip/src/crypto/securecomm"
 	"gossip/src/datastruct/indexedmap"
-	"gossip/src/datastruct/indexedset"
 	"gossip/src/datastruct/set"
 	"io"
 	"log"
 	"math"
 	"math/big"
 	mrand "math/rand"
+	"runtime"
+	"sort"
 	"time"
 
 	mathutils "gossip/src/utils/math"
@@ -26,11 +29,20 @@ var membershipControllerHandlers map[InternalMessageType]func(*MembershipControl
 func init() {
 	membershipControllerHandlers = map[InternalMessageType]func(*MembershipController, AnyMessage) error{}
 	membershipControllerHandlers[PeerDisconnectedMSG] = (*MembershipController).peerDisconnectedHandler
-	membershipControllerHandlers[ProbePeerReplyMSG] = (*MembershipController).probePeerReplyHandler
+	membershipControllerHandlers[SWIMIncomingPingMSG] = (*MembershipController).swimIncomingPingHandler
+	membershipControllerHandlers[SWIMIncomingAckMSG] = (*MembershipController).swimIncomingAckHandler
+	membershipControllerHandlers[SWIMIncomingPingRequestMSG] = (*MembershipController).swimIncomingPingRequestHandler
 	membershipControllerHandlers[MembershipIncomingPushRequestMSG] = (*MembershipController).incomingPushRequestHandler
 	membershipControllerHandlers[MembershipIncomingPullRequestMSG] = (*MembershipController).incomingPullRequestHandler
 	membershipControllerHandlers[MembershipIncomingPullReplyMSG] = (*MembershipController).incomingPullReplyHandler
 	membershipControllerHandlers[MembershipCloseMSG] = (*MembershipController).closeHandler
+	membershipControllerHandlers[PeerMisbehavedMSG] = (*MembershipController).peerMisbehavedHandler
+	membershipControllerHandlers[MembershipPushTokenMSG] = (*MembershipController).pushTokenHandler
+	membershipControllerHandlers[MembershipPushVerifiedMSG] = (*MembershipController).pushVerifiedHandler
+	membershipControllerHandlers[MembershipRPSQueryMSG] = (*MembershipController).rpsQueryHandler
+	membershipControllerHandlers[MembershipNetworkSizeMSG] = (*MembershipController).networkSizeHandler
+	membershipControllerHandlers[MembershipPeerLeftMSG] = (*MembershipController).peerLeftHandler
+	membershipControllerHandlers[PeerIdentifiedMSG] = (*MembershipController).peerIdentifiedHandler
 }
 
 // MinWiseIndependentPermutation is implementation of a min-wise
@@ -47,6 +59,45 @@ type PeerSampler struct {
 	permuter *MinWiseIndependentPermutation
 }
 
+// SamplerValidationInfo holds a pending validation of a sampled peer.
+type SamplerValidationInfo struct {
+	id     Identity
+	sentAt time.Time
+	// misses is the number of previous validation pings the peer has missed.
+	misses int
+}
+
+func (info *SamplerValidationInfo) String() string {
+	return fmt.Sprintf("{id: %s, sentAt: %s, misses: %d}", info.id, info.sentAt, info.misses)
+}
+
+// HealingProbeInfo holds a pending ping of a peer of the peer history.
+type HealingProbeInfo struct {
+	peer   Peer
+	sentAt time.Time
+}
+
+func (info *HealingProbeInfo) String() string {
+	return fmt.Sprintf("{peer: %s, sentAt: %s}", info.peer.Addrs, info.sentAt)
+}
+
+// StaticPeerInfo holds the connection state of a static peer.
+type StaticPeerInfo struct {
+	peer Peer
+	// failures is the number of consecutive connections that were lost shortly.
+	failures uint
+	// connectedAt is the time the peer was last put into the viewList.
+	connectedAt time.Time
+	// retryAt is the time the peer is put into the viewList again after
+	// its connection was lost. It is zero while the peer is in the viewList.
+	retryAt time.Time
+}
+
+func (info *StaticPeerInfo) String() string {
+	return fmt.Sprintf("{peer: %v, failures: %d, connectedAt: %s, retryAt: %s}",
+		info.peer, info.failures, info.connectedAt, info.retryAt)
+}
+
 // MembershipPoWConfig holds the limited push request Proof of Work configurations.
 type MembershipPoWConfig struct {
 	// hardness determines how long each scrypt hashing of MembershipPushRequestMSGPayload takes.
@@ -57,6 +108,14 @@ type MembershipPoWConfig struct {
 	// validityDuration is the amount of time a limited push request is valid
 	// after its creation time.
 	validityDuration time.Duration
+	// workers is the number of goroutines generating and verifying PoW.
+	workers int
+	// queueSize is the maximum number of PoW jobs waiting for a worker.
+	queueSize int
+	// generationReserve is the number of slots in the PoW job queue that
+	// verifications of incoming push requests cannot use, so that our own
+	// push requests can always be generated.
+	generationReserve int
 }
 
 // MembershipControllerViewListType is the type of variable
@@ -73,7 +132,7 @@ type MembershipControllerPullRepliesType Peer
 
 // MembershipControllerSampleListKeyType is the key type of
 // MembershipController::sampleList.
-type MembershipControllerSampleListKeyType Peer
+type MembershipControllerSampleListKeyType Identity
 
 // MembershipControllerSampleListValueType is the value type of
 // MembershipController::sampleList.
@@ -85,14 +144,39 @@ type MembershipControllerSampleListValueTypeType *PeerSampler
 
 // MembershipControllerPullPeersType is the type of variable
 // stored in MembershipController::pullPeers.
-type MembershipControllerPullPeersType Peer
+type MembershipControllerPullPeersType Identity
 
 // MembershipController is going to run async to maintain membership lists.
 type MembershipController struct {
-	bootstrapper string
-	p2pAddr      string
+	// bootstrapper is the bootstrapping peer, if there is one. Its identity
+	// is zero until it is learned from a handshake with the bootstrapper.
+	bootstrapper Peer
+	// identifyingBootstrapper is true iff the identity of the bootstrapper
+	// is being learned.
+	identifyingBootstrapper bool
+	// self is this node with its p2p listen address.
+	self Peer
+	// hostKey is the host key of this node for signing selfRecord.
+	hostKey crypto.Signer
+	// selfRecord is the signed peer record of this node, which is sent
+	// with every push request. It is renewed before it expires.
+	selfRecord *PeerRecord
+	// peerRecordLifetime is the amount of time a peer record is valid
+	// after its creation time.
+	peerRecordLifetime time.Duration
+	// peerRecords is the map of identities to the newest valid peer records
+	// of the peers in any of the lists. Only peers with a valid record are
+	// accepted as pushed or pulled peers, and spread in the pull replies.
+	peerRecords map[Identity]*PeerRecord
+	// departedPeers is the map of identities to the leave announcements of the
+	// peers who left the network recently. Their peer records created before
+	// the announcements are not accepted anymore.
+	departedPeers map[Identity]*PeerLeave
 	// configuration parameters
 	alphaSize, betaSize, gammaSize uint16
+	// alpha and beta are the fractions of viewListCap for the pushed and
+	// the pulled peers. They are kept for resizing the viewList.
+	alpha, beta float64
 	// pushProbability is the probability of making a push request.
 	pushProbability float64
 	// roundPeriod is the time duration between each membership round.
@@ -100,25 +184,86 @@ type MembershipController struct {
 	// powConfig is the PoW config for push requests (both incoming and outgoing).
 	powConfig MembershipPoWConfig
 	// viewList is the current set of peers for gossiping. It is of size O(n^0.25).
-	viewList *indexedset.IndexedSet
+	viewList *PeerSet
 	// viewListCap is the total capacity of viewList for Peer's.
 	viewListCap uint16
+	// staticPeers is the map of identities to the peers pinned by the configuration.
+	// They are always kept in the viewList in addition to the peers chosen by BRAHMS,
+	// so they do not count against viewListCap. If the connection of a static peer
+	// is lost, then it is put into the viewList again with an exponential backoff.
+	staticPeers map[Identity]*StaticPeerInfo
+	// staticBackoffMin and staticBackoffMax are the bounds of the backoff
+	// before putting a static peer into the viewList again.
+	staticBackoffMin, staticBackoffMax time.Duration
 	// sampleList is the current map of randomly sampled peers. It is of size O(n^0.5).
-	// The map is of the form map[Peer]set[*PeerSampler] where the 'peer' inside each
-	// PeerSampler must be the same as the key Peer. As each PeerSampler sample new
-	// peers, their corresponding key peer must also change to the new one.
+	// The map is of the form map[Identity]set[*PeerSampler] where the identity of the
+	// 'peer' inside each PeerSampler must be the same as the key Identity. As each
+	// PeerSampler sample new peers, their corresponding key must also change to the new one.
 	sampleList *indexedmap.IndexedMap
+	// sampleListCap is the total capacity of sampleList for PeerSampler's.
+	sampleListCap uint32
 	// sampleListRemainingCap is the remaining capacity of sampleList for new PeerSampler's.
 	sampleListRemainingCap uint32
+	// samplerValidationInterval is the time duration between each validation of
+	// the peers sampled by the PeerSampler's, as in the BRAHMS paper.
+	samplerValidationInterval time.Duration
+	// samplerValidations is the map of the sequence numbers of the pings sent
+	// for validating the sampled peers to the pending validations.
+	samplerValidations map[uint64]*SamplerValidationInfo
+	// history is the long-lived set of the peers seen in the viewList, which
+	// is used for healing a partition of the network.
+	history *PeerHistory
+	// healInterval is the time duration between each attempt to reconnect to
+	// a peer of the history, which is not in the viewList.
+	healInterval time.Duration
+	// healingProbes is the map of the sequence numbers of the pings sent to
+	// the peers of the history to the pending probes.
+	healingProbes map[uint64]*HealingProbeInfo
+	// healedPeers is the set of peers of the history who answered to a ping.
+	// They are pulled at the next membership round, so that their views are
+	// merged with ours.
+	healedPeers *PeerSet
 	// pushRequests is a set of peers who sent us a valid push request
 	// since the end of previous membership round.
-	pushRequests set.Set
+	pushRequests *PeerSet
 	// pullReplies is a set of peers that were sent to us as a pull reply.
-	pullReplies *indexedset.IndexedSet
+	pullReplies *PeerSet
+	// pullReplySources is the map of the identities of the peers in pullReplies
+	// to the identities of the peers whose pull reply first contained them.
+	pullReplySources map[Identity]Identity
+	// diversity is the policy for the diversity of the peers in a new viewList.
+	diversity DiversityPolicy
+	// diversityStats counts the peers rejected from new viewLists by the diversity policy.
+	diversityStats DiversityStats
 	// pullPeers are peers to whom the Membership controller sent a membership
 	// pull request and is waiting for a pull reply. Any membership pull reply
 	// from a peer outside of this set will be ignored!
 	pullPeers set.Set
+	// reputations keeps the reputation score of misbehaving peers. Banned
+	// peers are neither accepted into any list nor kept in the viewList.
+	reputations *PeerReputationList
+	// banList is the ban list shared with the Central controller. The peers
+	// banned by it are treated the same as the peers banned by reputations.
+	banList *securecomm.BanList
+	// failureDetector monitors the liveness of the peers in the viewList
+	// and the sampleList. Besides the sampler validation, its verdicts are
+	// the only reason for removing a peer from the sampleList.
+	failureDetector *FailureDetector
+	// powPool is the worker pool for generating and verifying the
+	// Proof of Work of push requests.
+	powPool *PoWWorkerPool
+	// pushRoundCounter identifies the membership round the push
+	// requests are currently generated for.
+	pushRoundCounter uint64
+	// pushCancel cancels the generation of the push requests that
+	// are not ready by the start of the next membership round.
+	pushCancel context.CancelFunc
+	// pushTokens is the map of peers to the push requests generated
+	// for them, which are sent in the next membership round.
+	pushTokens map[Identity]*MembershipPushRequestMSGPayload
+	// pendingVerifications is a set of identities of peers whose push requests
+	// are being verified by the PoW workers.
+	pendingVerifications set.Set
 	// MsgInQueue is the incoming message queue for
 	// the Membership controller goroutine.
 	MsgInQueue chan InternalMessage
@@ -158,11 +303,8 @@ func (p *MinWiseIndependentPermutation) Permute(peer *Peer) (pVal []byte, err er
 			}
 		}
 	}()
-	// identity is not the same as the one described in the specifications.pdf .
-	identity := sha256.Sum256([]byte(peer.Addr))
-
 	pVal = make([]byte, 32)
-	p.enc.CryptBlocks(pVal, identity[:])
+	p.enc.CryptBlocks(pVal, peer.ID[:])
 	return
 }
 
@@ -203,13 +345,11 @@ func (peerSampler *PeerSampler) Next(peer *Peer) error {
 
 // NextSet is the method for introducing peer sampler with a set
 // of peers. If at least one new peer is sampled, then returns nil.
-//
-// peerSet must be a set.Set of type 'Peer'.
-func (peerSampler *PeerSampler) NextSet(peerSet set.Set) error {
+func (peerSampler *PeerSampler) NextSet(peerSet *PeerSet) error {
 	err := fmt.Errorf("peerSampler not changed yet")
 	// Feed all of the new peers into the sampler.
-	for elem := range peerSet.Iterate() {
-		newPeer := elem.(Peer)
+	for _, peer := range peerSet.Peers() {
+		newPeer := peer
 		curErr := peerSampler.Next(&newPeer)
 		if curErr == nil {
 			err = nil
@@ -228,8 +368,12 @@ func (peerSampler *PeerSampler) Sample() Peer {
 }
 
 // NewMembershipController is a constructor for the MembershipController class.
+// The capacities of viewList and sampleList are taken from sizeParams, and they
+// are changed later as the estimated network size changes.
 func NewMembershipController(
-	bootstrapper, p2pAddr string, alpha, beta float64, roundDuration time.Duration, maxPeers float64, viewListCap uint16,
+	bootstrapper, self Peer, staticPeers []Peer, hostKey crypto.Signer, banList *securecomm.BanList,
+	history *PeerHistory, diversity DiversityPolicy, alpha, beta float64,
+	roundDuration, samplerValidationInterval time.Duration, sizeParams NetworkSizeParams,
 	inQ, outQ chan InternalMessage,
 ) (*MembershipController, error) {
 	// Since the following parameters are critical for the correct operation of the
@@ -237,41 +381,149 @@ func NewMembershipController(
 	// This way, only "power users", who know what they are doing, can modify it!
 	powHardness := uint64(4)
 	powRepetition := uint64(512)
-	powValidityDuration := roundDuration
+	// Push requests are generated one round before they are sent.
+	powValidityDuration := 2 * roundDuration
+	powWorkers := mathutils.Max(1, runtime.NumCPU()/2)
+	// The PoW job queue is sized for the largest viewList, since it can not grow later.
+	powQueueSize := 2 * int(viewListCapOf(maxPeers))
+	peerRecordLifetime := 100 * roundDuration
+	staticBackoffMin := roundDuration
+	staticBackoffMax := 20 * roundDuration
+	healInterval := 10 * roundDuration
+	reputationConfig := PeerReputationConfig{
+		initialScore: 100,
+		banThreshold: 0,
+		recovery:     1,
+		banDuration:  100 * roundDuration,
+		penalties: map[PeerMisbehaviour]float64{
+			InvalidGossipItem:    20,
+			MalformedMessage:     50,
+			UnsolicitedPullReply: 5,
+			InvalidTombstone:     50,
+			InvalidPeerRecord:    20,
+			InvalidPeerLeave:     50,
+			InvalidKVEntry:       50,
+			InvalidDirectMessage: 50,
+		},
+	}
+	protocolPeriod := roundDuration / 3
+	failureDetectorConfig := FailureDetectorConfig{
+		protocolPeriod: protocolPeriod,
+		pingTimeout:    protocolPeriod / 4,
+		indirectProbes: 3,
+		suspicionMult:  4,
+		retransmitMult: 3,
+		maxPiggyback:   8,
+	}
 
 	membershipController := MembershipController{
-		bootstrapper:    bootstrapper,
-		p2pAddr:         p2pAddr,
-		pushProbability: 0.0,
-		roundPeriod:     roundDuration,
+		bootstrapper:       bootstrapper,
+		self:               self,
+		hostKey:            hostKey,
+		peerRecordLifetime: peerRecordLifetime,
+		peerRecords:        map[Identity]*PeerRecord{},
+		departedPeers:      map[Identity]*PeerLeave{},
+		pushProbability:    0.0,
+		roundPeriod:        roundDuration,
 		powConfig: MembershipPoWConfig{
-			hardness:         powHardness,
-			repetition:       powRepetition,
-			validityDuration: powValidityDuration,
+			hardness:          powHardness,
+			repetition:        powRepetition,
+			validityDuration:  powValidityDuration,
+			workers:           powWorkers,
+			queueSize:         powQueueSize,
+			generationReserve: int(sizeParams.ViewListCap),
 		},
-		viewList:               indexedset.New(),
-		viewListCap:            viewListCap,
-		sampleList:             indexedmap.New(),
-		sampleListRemainingCap: uint32(maxPeers / float64(viewListCap*viewListCap)),
-		pushRequests:           set.New(),
-		pullReplies:            indexedset.New(),
-		pullPeers:              set.New(),
-		MsgInQueue:             inQ,
-		MsgOutQueue:            outQ,
+		viewList:                  NewPeerSet(),
+		staticPeers:               map[Identity]*StaticPeerInfo{},
+		staticBackoffMin:          staticBackoffMin,
+		staticBackoffMax:          staticBackoffMax,
+		sampleList:                indexedmap.New(),
+		sampleListCap:             sizeParams.SampleListCap,
+		sampleListRemainingCap:    sizeParams.SampleListCap,
+		samplerValidationInterval: samplerValidationInterval,
+		samplerValidations:        map[uint64]*SamplerValidationInfo{},
+		history:                   history,
+		healInterval:              healInterval,
+		healingProbes:             map[uint64]*HealingProbeInfo{},
+		healedPeers:               NewPeerSet(),
+		pushRequests:              NewPeerSet(),
+		pullReplies:               NewPeerSet(),
+		pullReplySources:          map[Identity]Identity{},
+		diversity:                 diversity,
+		diversityStats:            DiversityStats{},
+		pullPeers:                 set.New(),
+		reputations:               NewPeerReputationList(reputationConfig),
+		banList:                   banList,
+		failureDetector:           NewFailureDetector(failureDetectorConfig, self),
+		powPool:                   NewPoWWorkerPool(powWorkers, powQueueSize, inQ),
+		pushTokens:                map[Identity]*MembershipPushRequestMSGPayload{},
+		pendingVerifications:      set.New(),
+		MsgInQueue:                inQ,
+		MsgOutQueue:               outQ,
 	}
 
 	// Check the validity of alpha and beta parameters
 	if alpha <= 0 || beta <= 0 || alpha+beta >= 1 {
 		return nil, fmt.Errorf("alpha, beta and gamma parameters are invalid: %f, %f, %f", alpha, beta, 1-(alpha+beta))
 	}
+	if err := diversity.Validate(); err != nil {
+		return nil, err
+	}
+	if samplerValidationInterval <= 0 {
+		return nil, fmt.Errorf("sampler validation interval is invalid: %s", samplerValidationInterval)
+	}
+	if err := membershipController.renewSelfRecord(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	for _, peer := range staticPeers {
+		if peer.ID == self.ID {
+			return nil, fmt.Errorf("static peer %s is this node itself", peer.ID)
+		}
+		membershipController.staticPeers[peer.ID] = &StaticPeerInfo{peer: peer}
+	}
 
-	membershipController.alphaSize = uint16(math.Floor(alpha * float64(viewListCap)))
-	membershipController.betaSize = uint16(math.Floor(beta * float64(viewListCap)))
-	membershipController.gammaSize = viewListCap - membershipController.alphaSize - membershipController.betaSize
+	membershipController.alpha = alpha
+	membershipController.beta = beta
+	membershipController.setViewListCap(sizeParams.ViewListCap)
 
 	return &membershipController, nil
 }
 
+// setViewListCap is the method for setting the capacity of viewList, and the
+// number of pushed, pulled and sampled peers it is made up of accordingly.
+// The viewList shrinks at the next membership round if it is too large.
+func (membershipController *MembershipController) setViewListCap(viewListCap uint16) {
+	membershipController.viewListCap = viewListCap
+	membershipController.alphaSize = uint16(math.Floor(membershipController.alpha * float64(viewListCap)))
+	membershipController.betaSize = uint16(math.Floor(membershipController.beta * float64(viewListCap)))
+	membershipController.gammaSize = viewListCap - membershipController.alphaSize - membershipController.betaSize
+	membershipController.powConfig.generationReserve = int(viewListCap)
+}
+
+// setSampleListCap is the method for setting the capacity of sampleList. If
+// more PeerSampler's are in use than the new capacity, then the excess ones
+// are removed from random entries of sampleList.
+func (membershipController *MembershipController) setSampleListCap(sampleListCap uint32) {
+	used := membershipController.sampleListCap - membershipController.sampleListRemainingCap
+	membershipController.sampleListCap = sampleListCap
+	if sampleListCap >= used {
+		membershipController.sampleListRemainingCap = sampleListCap - used
+		return
+	}
+	membershipController.sampleListRemainingCap = 0
+	for excess := used - sampleListCap; excess > 0 && membershipController.sampleList.Len() > 0; excess-- {
+		key, _ := membershipController.sampleList.KeyAtIndex(mrand.Intn(membershipController.sampleList.Len()))
+		peerSamplerSet := membershipController.sampleList.GetValue(key).(set.Set)
+		for peerSampler := range peerSamplerSet.Iterate() {
+			peerSamplerSet.Remove(peerSampler)
+			break
+		}
+		if peerSamplerSet.Len() == 0 {
+			membershipController.sampleList.Remove(key)
+		}
+	}
+}
+
 // recover method tries to catch a panic in controllerRoutine if it exists, then
 // informs the Central controller about the crash.
 func (membershipController *MembershipController) recover() {
@@ -287,7 +539,8 @@ func (membershipController *MembershipController) recover() {
 			err = fmt.Errorf("Unknown panic in MembershipController")
 		}
 
-		// Clear the input queue.
+		// Stop the PoW workers and clear the input queue.
+		membershipController.powPool.Close()
 		for len(membershipController.MsgInQueue) > 0 {
 			<-membershipController.MsgInQueue
 		}
@@ -298,16 +551,24 @@ func (membershipController *MembershipController) recover() {
 }
 
 // replaceViewList is the method to use when replacing the 'viewList' with a new one.
-// It not only replaces but also informs the Central controller.
+// It not only replaces but also informs the Central controller. The static peers
+// are always kept, unless they are waiting to be put into the viewList again.
 //
 // The 'newViewList' argument is possibly modified.
-func (membershipController *MembershipController) replaceViewList(newViewList *indexedset.IndexedSet) {
-	toBeRemoved := indexedset.New()
+func (membershipController *MembershipController) replaceViewList(newViewList *PeerSet) {
+	now := time.Now()
+	for _, info := range membershipController.staticPeers {
+		if info.retryAt.IsZero() {
+			newViewList.Add(info.peer)
+		} else {
+			newViewList.Remove(info.peer)
+		}
+	}
+	toBeRemoved := NewPeerSet()
 	toBeAdded := newViewList
 	viewList := membershipController.viewList
 
-	for elem := range viewList.Iterate() {
-		peer := elem.(Peer)
+	for _, peer := range viewList.Peers() {
 		if !newViewList.IsMember(peer) {
 			// if the peer is not in the intersection, then we need to remove it!
 			toBeRemoved.Add(peer)
@@ -316,27 +577,28 @@ func (membershipController *MembershipController) replaceViewList(newViewList *i
 			toBeAdded.Remove(peer)
 		}
 	}
-	for elem := range toBeRemoved.Iterate() {
-		peer := elem.(Peer)
+	for _, peer := range toBeRemoved.Peers() {
 		// send PeerRemoveMSG message to the Central controller!
 		log.Println("Membership controller -> Central controller, PeerRemoveMSG,", peer)
 		membershipController.MsgOutQueue <- InternalMessage{Type: PeerRemoveMSG, Payload: peer}
 
 		viewList.Remove(peer)
 	}
-	for elem := range toBeAdded.Iterate() {
-		peer := elem.(Peer)
+	for _, peer := range toBeAdded.Peers() {
 		// send PeerAddMSG message to the Central controller!
 		log.Println("Membership controller -> Central controller, PeerAddMSG,", peer)
 		membershipController.MsgOutQueue <- InternalMessage{Type: PeerAddMSG, Payload: peer}
 
 		viewList.Add(peer)
+		if info, isStatic := membershipController.staticPeers[peer.ID]; isStatic {
+			info.connectedAt = now
+		}
 	}
 }
 
-// removePeer is the method to use when a remote peer goes down
-// and everything related to that peer needs to be removed.
-func (membershipController *MembershipController) removePeer(peer Peer) {
+// removeFromViewList is the method to use when a remote peer has
+// to be removed from the viewList only.
+func (membershipController *MembershipController) removeFromViewList(peer Peer) {
 	if membershipController.viewList.IsMember(peer) {
 		// Remove the peer from viewList.
 		membershipController.viewList.Remove(peer)
@@ -347,44 +609,180 @@ func (membershipController *MembershipController) removePeer(peer Peer) {
 		log.Println("Membership controller -> Central controller, PeerRemoveMSG,", peer)
 		membershipController.MsgOutQueue <- InternalMessage{Type: PeerRemoveMSG, Payload: peer}
 	}
-	// Check if the peer exists in sampleList.
-	if membershipController.sampleList.IsMember(peer) {
-		// Remove the peer from sampleList.
-		value := membershipController.sampleList.GetValue(peer)
-		peerSamplerSet := value.(set.Set)
-		membershipController.sampleListRemainingCap += uint32(peerSamplerSet.Len())
-		membershipController.sampleList.Remove(peer)
+	// A static peer leaves the viewList only until it is reconnected.
+	if info, isStatic := membershipController.staticPeers[peer.ID]; isStatic && info.retryAt.IsZero() {
+		membershipController.scheduleStaticPeer(info, time.Now())
+	}
+}
+
+// scheduleStaticPeer is the method for scheduling a static peer, whose
+// connection is lost, to be put into the viewList again. The backoff doubles
+// with every connection that is lost before lasting for the maximum backoff.
+func (membershipController *MembershipController) scheduleStaticPeer(info *StaticPeerInfo, now time.Time) {
+	if now.Sub(info.connectedAt) >= membershipController.staticBackoffMax {
+		info.failures = 0
+	}
+	// Double the backoff for each failure, but stop doubling once the maximum
+	// is reached, so that the backoff cannot overflow.
+	backoff := membershipController.staticBackoffMin
+	for i := uint(0); i < info.failures && backoff < membershipController.staticBackoffMax; i++ {
+		backoff *= 2
 	}
+	if backoff > membershipController.staticBackoffMax {
+		backoff = membershipController.staticBackoffMax
+	}
+	info.failures++
+	info.retryAt = now.Add(backoff)
+	log.Println("Membership controller: static peer", info.peer.Addrs, "is reconnected in", backoff)
+}
+
+// reconnectStaticPeers is the method for putting the static peers, whose
+// backoff is over, into the viewList again.
+func (membershipController *MembershipController) reconnectStaticPeers(now time.Time) {
+	for _, info := range membershipController.staticPeers {
+		if info.retryAt.IsZero() || now.Before(info.retryAt) {
+			continue
+		}
+		// A banned static peer is kept out of the viewList while the ban lasts.
+		if isPeerBanned(membershipController.banList, info.peer) {
+			membershipController.scheduleStaticPeer(info, now)
+			continue
+		}
+		info.retryAt = time.Time{}
+		info.connectedAt = now
+		if !membershipController.viewList.IsMember(info.peer) {
+			membershipController.viewList.Add(info.peer)
+			log.Println("Membership controller -> Central controller, PeerAddMSG,", info.peer)
+			membershipController.MsgOutQueue <- InternalMessage{Type: PeerAddMSG, Payload: info.peer}
+		}
+	}
+}
+
+// removePeer is the method to use when a remote peer goes down
+// and everything related to that peer needs to be removed.
+func (membershipController *MembershipController) removePeer(peer Peer) {
+	membershipController.removeFromViewList(peer)
+	membershipController.releaseSamplers(peer.ID)
 	// Remove the peer from pushRequests.
 	membershipController.pushRequests.Remove(peer)
 	// Remove the peer from the pullReplies.
 	membershipController.pullReplies.Remove(peer)
+	delete(membershipController.pullReplySources, peer.ID)
 	// Remove the peer from the pullPeers.
-	membershipController.pullPeers.Remove(peer)
+	membershipController.pullPeers.Remove(peer.ID)
+}
+
+// releaseSamplers is the method for removing the peer samplers of the identity
+// from the sampleList and releasing their capacity. Since new peer samplers
+// are created with a fresh MinWiseIndependentPermutation, this reinitialises
+// the peer samplers as described in the BRAHMS paper.
+func (membershipController *MembershipController) releaseSamplers(id Identity) {
+	// Check if the peer exists in sampleList.
+	if membershipController.sampleList.IsMember(id) {
+		// Remove the peer from sampleList.
+		value := membershipController.sampleList.GetValue(id)
+		peerSamplerSet := value.(set.Set)
+		membershipController.sampleListRemainingCap += uint32(peerSamplerSet.Len())
+		membershipController.sampleList.Remove(id)
+	}
+}
+
+// isBanned returns true iff the peer is banned at the given time,
+// either because of its reputation or by the ban list.
+func (membershipController *MembershipController) isBanned(peer Peer, now time.Time) bool {
+	return membershipController.reputations.IsBanned(peer, now) || isPeerBanned(membershipController.banList, peer)
+}
+
+// pruneBannedRound is the method for removing the peers banned by
+// the ban list since the previous round from every list.
+func (membershipController *MembershipController) pruneBannedRound() {
+	peers := NewPeerSet()
+	for _, peer := range membershipController.viewList.Peers() {
+		peers.Add(peer)
+	}
+	for elem := range membershipController.sampleList.Iterate() {
+		peers.Add(membershipController.sampledPeer(elem.(Identity)))
+	}
+	for _, peer := range membershipController.pushRequests.Peers() {
+		peers.Add(peer)
+	}
+	for _, peer := range membershipController.pullReplies.Peers() {
+		peers.Add(peer)
+	}
+	for _, peer := range peers.Peers() {
+		if isPeerBanned(membershipController.banList, peer) {
+			log.Println("Membership controller: removing the banned peer", peer.ID)
+			membershipController.removePeer(peer)
+		}
+	}
+}
+
+// penalizePeer is the method to use when a remote peer violates the protocol.
+// If the reputation of the peer falls too low, then the peer is removed and
+// the Central controller is commanded to ban it.
+func (membershipController *MembershipController) penalizePeer(peer Peer, reason PeerMisbehaviour) {
+	// The static peers are trusted by the operator and never banned.
+	if _, isStatic := membershipController.staticPeers[peer.ID]; isStatic {
+		log.Println("Membership controller: static peer", peer.Addrs, "misbehaved,", reason)
+		return
+	}
+	banned, until := membershipController.reputations.Penalize(peer, reason, time.Now().UTC())
+	if !banned {
+		return
+	}
+	membershipController.removePeer(peer)
+	payload := PeerBanMSGPayload{Peer: peer, Until: until}
+	log.Println("Membership controller -> Central controller, PeerBanMSG,", payload)
+	membershipController.MsgOutQueue <- InternalMessage{Type: PeerBanMSG, Payload: payload}
 }
 
 // pushRound is the method for performing limited push requests
 // during a membership round, as desribed in the BRAHMS paper.
+// The push requests are generated by the PoW workers during the
+// previous round and sent in this round.
 func (membershipController *MembershipController) pushRound() {
+	now := time.Now().UTC()
+	for _, pushReq := range membershipController.pushTokens {
+		// Only push to the peers still in the viewList.
+		if !membershipController.viewList.IsMember(pushReq.To) ||
+			now.Sub(pushReq.When) > membershipController.powConfig.validityDuration {
+			continue
+		}
+		// Send the push request message to the Central controller.
+		log.Println("Membership controller -> Central controller, MembershipPushRequestMSG,", pushReq)
+		membershipController.MsgOutQueue <- InternalMessage{Type: MembershipPushRequestMSG, Payload: *pushReq}
+	}
+	membershipController.pushTokens = map[Identity]*MembershipPushRequestMSGPayload{}
+	// Cancel the push requests which are too late for this round.
+	if membershipController.pushCancel != nil {
+		membershipController.pushCancel()
+	}
+	// Generate the push requests for the next round.
+	membershipController.pushRoundCounter++
+	round := membershipController.pushRoundCounter
+	ctx, cancel := context.WithCancel(membershipController.powPool.Context())
+	membershipController.pushCancel = cancel
+	from := membershipController.self
+	record := membershipController.selfRecord
+	hardness := membershipController.powConfig.hardness
+	repetition := membershipController.powConfig.repetition
 	size := membershipController.viewList.Len()
 	pushIndexes := mrand.Perm(size)[:mathutils.Min(int(membershipController.alphaSize), size)]
 	for _, i := range pushIndexes {
 		if mrand.Float64() <= membershipController.pushProbability {
-			ithElem := membershipController.viewList.ElemAtIndex(i)
-			remotePeer := ithElem.(Peer)
-			pushReq, err := NewMembershipPushRequestMSGPayload(
-				Peer{membershipController.p2pAddr},
-				remotePeer,
-				membershipController.powConfig.hardness,
-				membershipController.powConfig.repetition,
-			)
-			if err != nil {
-				continue
+			remotePeer := membershipController.viewList.PeerAtIndex(i)
+			job := func(context.Context) *InternalMessage {
+				pushReq, err := NewMembershipPushRequestMSGPayload(ctx, from, remotePeer, record, hardness, repetition)
+				if err != nil {
+					return nil
+				}
+				payload := MembershipPushTokenMSGPayload{Round: round, Request: pushReq}
+				log.Println("PoW worker -> Membership controller, MembershipPushTokenMSG,", payload)
+				return &InternalMessage{Type: MembershipPushTokenMSG, Payload: payload}
+			}
+			if !membershipController.powPool.TrySubmit(job, 0) {
+				log.Println("Membership controller: PoW worker pool is saturated, skipping the push request to", remotePeer.Addrs)
 			}
-
-			// Send the push request message to the Central controller.
-			log.Println("Membership controller -> Central controller, MembershipPushRequestMSG,", pushReq)
-			membershipController.MsgOutQueue <- InternalMessage{Type: MembershipPushRequestMSG, Payload: pushReq}
 		}
 	}
 	// Increase the pushProbability for the next time.
@@ -398,14 +796,22 @@ func (membershipController *MembershipController) pullRound() {
 	size := membershipController.viewList.Len()
 	pullIndexes := mrand.Perm(size)[:mathutils.Min(int(membershipController.betaSize), size)]
 	for _, i := range pullIndexes {
-		ithElem := membershipController.viewList.ElemAtIndex(i)
-		peer := ithElem.(Peer)
-		membershipController.pullPeers.Add(peer)
+		peer := membershipController.viewList.PeerAtIndex(i)
+		membershipController.pullPeers.Add(peer.ID)
 
 		// Send the pull request message to the Central controller.
 		log.Println("Membership controller -> Central controller, MembershipPullRequestMSG,", peer)
 		membershipController.MsgOutQueue <- InternalMessage{Type: MembershipPullRequestMSG, Payload: peer}
 	}
+	// Also pull from the peers reconnected by healing, so that their views are merged with ours.
+	for _, peer := range membershipController.healedPeers.Peers() {
+		if membershipController.viewList.IsMember(peer) && !membershipController.pullPeers.IsMember(peer.ID) {
+			membershipController.pullPeers.Add(peer.ID)
+			log.Println("Membership controller -> Central controller, MembershipPullRequestMSG,", peer)
+			membershipController.MsgOutQueue <- InternalMessage{Type: MembershipPullRequestMSG, Payload: peer}
+		}
+	}
+	membershipController.healedPeers = NewPeerSet()
 }
 
 // updateRound is the method for updating the old view list with the
@@ -416,43 +822,70 @@ func (membershipController *MembershipController) updateRound() {
 	// either pushed or pulled peers are non-empty.
 	if membershipController.pushRequests.Len() <= int(membershipController.alphaSize) &&
 		(membershipController.pushRequests.Len() > 0 || membershipController.pullReplies.Len() > 0) {
-		newViewList := indexedset.New()
+		newViewList := NewPeerSet()
+		// Every peer of the new view list has to satisfy the diversity policy.
+		filter := NewDiversityFilter(&membershipController.diversity,
+			int(membershipController.betaSize), membershipController.diversityStats)
 		// Add up to alphaSize pushed peers into the new view list.
-		for elem := range membershipController.pushRequests.Iterate() {
-			peer := elem.(Peer)
-			newViewList.Add(peer)
+		for _, peer := range membershipController.pushRequests.Peers() {
+			if membershipController.allowDiverse(filter, peer, Identity{}) {
+				newViewList.Add(peer)
+			}
 		}
-		// Add up to betaSize pulled peers into the new view list.
+		// Add up to betaSize pulled peers into the new view list, preferring
+		// the peers with higher reputation and breaking the ties randomly.
 		size := membershipController.pullReplies.Len()
-		pullIndexes := mrand.Perm(size)[:mathutils.Min(int(membershipController.betaSize), size)]
-		for _, i := range pullIndexes {
-			ithElem := membershipController.pullReplies.ElemAtIndex(i)
-			peer := ithElem.(Peer)
-			newViewList.Add(peer)
+		pullIndexes := mrand.Perm(size)
+		sort.SliceStable(pullIndexes, func(i, j int) bool {
+			iPeer := membershipController.pullReplies.PeerAtIndex(pullIndexes[i])
+			jPeer := membershipController.pullReplies.PeerAtIndex(pullIndexes[j])
+			return membershipController.reputations.Score(iPeer) > membershipController.reputations.Score(jPeer)
+		})
+		for added, k := 0, 0; added < int(membershipController.betaSize) && k < size; k++ {
+			peer := membershipController.pullReplies.PeerAtIndex(pullIndexes[k])
+			if !newViewList.IsMember(peer) && membershipController.allowDiverse(filter, peer, membershipController.pullReplySources[peer.ID]) {
+				newViewList.Add(peer)
+				added++
+			}
 		}
 		// Add up to gammaSize sampled peers into the new view list.
 		size = membershipController.sampleList.Len()
-		sampleIndexes := mrand.Perm(size)[:mathutils.Min(int(membershipController.gammaSize), size)]
-		for _, i := range sampleIndexes {
-			ithElem, _ := membershipController.sampleList.KeyAtIndex(i)
-			peer := ithElem.(Peer)
-			newViewList.Add(peer)
+		sampleIndexes := mrand.Perm(size)
+		for added, k := 0, 0; added < int(membershipController.gammaSize) && k < size; k++ {
+			ithElem, _ := membershipController.sampleList.KeyAtIndex(sampleIndexes[k])
+			peer := membershipController.sampledPeer(ithElem.(Identity))
+			if !newViewList.IsMember(peer) && membershipController.allowDiverse(filter, peer, Identity{}) {
+				newViewList.Add(peer)
+				added++
+			}
 		}
 		// Replace the old view list with the new one.
 		membershipController.replaceViewList(newViewList)
 	}
 }
 
+// allowDiverse is the method for checking whether the peer satisfies the
+// diversity policy for the new view list being built. Every rejection is
+// logged together with the number of rejections by the same constraint.
+func (membershipController *MembershipController) allowDiverse(filter *DiversityFilter, peer Peer, source Identity) bool {
+	allowed, constraint := filter.AllowPeer(peer, source)
+	if !allowed {
+		log.Println("Membership controller:", constraint, "rejected", peer.Addrs, "from the viewList,",
+			membershipController.diversityStats[constraint], "times in total")
+	}
+	return allowed
+}
+
 // updateSampleRound is the method for updating the old peer samplers
 // inside sampleList by introducing the new pushed and pulled peers.
 func (membershipController *MembershipController) updateSampleRound() {
 	newPeers := membershipController.pushRequests
-	for elem := range membershipController.pullReplies.Iterate() {
-		peer := elem.(Peer)
+	for _, peer := range membershipController.pullReplies.Peers() {
 		newPeers.Add(peer)
 	}
-	membershipController.pushRequests = set.New()
-	membershipController.pullReplies = indexedset.New()
+	membershipController.pushRequests = NewPeerSet()
+	membershipController.pullReplies = NewPeerSet()
+	membershipController.pullReplySources = map[Identity]Identity{}
 
 	// If there is remaining capacity, create new peer samplers and
 	// introduce new peers to the new peer samplers.
@@ -472,9 +905,9 @@ func (membershipController *MembershipController) updateSampleRound() {
 	membershipController.sampleListRemainingCap -= uint32(len(newPeerSamplers))
 
 	// Introduce new peers to the old peer samplers.
-	peersToRemove := make([]Peer, 0)
+	peersToRemove := make([]Identity, 0)
 	for key, valueAndIndex := range membershipController.sampleList.Iterate() {
-		oldPeer := key.(Peer)
+		oldPeer := key.(Identity)
 		value := valueAndIndex.Value
 		// value is a set.Set of *PeerSampler .
 		peerSamplerSet := value.(set.Set)
@@ -505,10 +938,10 @@ func (membershipController *MembershipController) updateSampleRound() {
 	// Add new peer samplers back into the sampleList.
 	for _, peerSampler := range newPeerSamplers {
 		peer := peerSampler.Sample()
-		value := membershipController.sampleList.GetValue(peer)
+		value := membershipController.sampleList.GetValue(peer.ID)
 		if value == nil {
 			peerSamplerSet := set.New().Add(peerSampler)
-			membershipController.sampleList.Put(peer, peerSamplerSet)
+			membershipController.sampleList.Put(peer.ID, peerSamplerSet)
 		} else {
 			peerSamplerSet := value.(set.Set)
 			peerSamplerSet.Add(peerSampler)
@@ -516,13 +949,216 @@ func (membershipController *MembershipController) updateSampleRound() {
 	}
 }
 
-// probePeerRound is executed to probe every peer in the sampleList.
-func (membershipController *MembershipController) probePeerRound() {
+// trackPeerRound is executed to let the failure detector monitor
+// every peer in the viewList and the sampleList.
+func (membershipController *MembershipController) trackPeerRound() {
+	peers := map[Identity]Peer{}
 	for elem := range membershipController.sampleList.Iterate() {
-		peer := elem.(Peer)
-		// Send probe peer request message to the Central controller.
-		log.Println("Membership controller -> Central controller, ProbePeerRequestMSG,", peer)
-		membershipController.MsgOutQueue <- InternalMessage{Type: ProbePeerRequestMSG, Payload: peer}
+		id := elem.(Identity)
+		peers[id] = membershipController.sampledPeer(id)
+	}
+	// Prefer the addresses of the peers in the viewList.
+	for _, peer := range membershipController.viewList.Peers() {
+		peers[peer.ID] = peer
+	}
+	membershipController.failureDetector.Track(peers)
+}
+
+// sampledPeer returns the peer sampled by the peer samplers
+// of the identity in the sampleList.
+func (membershipController *MembershipController) sampledPeer(id Identity) Peer {
+	value := membershipController.sampleList.GetValue(id)
+	if value == nil {
+		return Peer{}
+	}
+	for elem := range value.(set.Set).Iterate() {
+		return elem.(*PeerSampler).Sample()
+	}
+	return Peer{}
+}
+
+// samplerValidationRound is executed once every sampler validation interval
+// to ping the peer of every PeerSampler in the sampleList. The peer samplers
+// of the peers that do not ack in time are reinitialised.
+func (membershipController *MembershipController) samplerValidationRound() {
+	now := time.Now()
+	fd := membershipController.failureDetector
+	for elem := range membershipController.sampleList.Iterate() {
+		id := elem.(Identity)
+		seq := fd.NextSeq()
+		membershipController.samplerValidations[seq] = &SamplerValidationInfo{id: id, sentAt: now}
+		membershipController.sendPing(membershipController.sampledPeer(id), seq)
+	}
+}
+
+// historyRound is the method for remembering the peers in the viewList.
+func (membershipController *MembershipController) historyRound() {
+	now := time.Now().UTC()
+	for _, peer := range membershipController.viewList.Peers() {
+		membershipController.history.Add(peer, now)
+	}
+}
+
+// healingRound is executed once every healInterval for healing a partition
+// of the network. If the network is split, then the views of each side only
+// converge on their own side. So, a random peer of the history, which is not
+// in the viewList, is pinged. If it answers, then it is put into the viewList
+// and pulled at the next membership round, so that the views are merged.
+// The history is persisted as well.
+func (membershipController *MembershipController) healingRound() {
+	if err := membershipController.history.Save(); err != nil {
+		log.Println("Membership controller: cannot save the peer history:", err)
+	}
+	now := time.Now()
+	peer, ok := membershipController.history.Random(func(peer Peer) bool {
+		_, hasLeft := membershipController.departedPeers[peer.ID]
+		return peer.ID != membershipController.self.ID && !membershipController.viewList.IsMember(peer) &&
+			!hasLeft && !membershipController.isBanned(peer, now.UTC())
+	})
+	if !ok {
+		return
+	}
+	seq := membershipController.failureDetector.NextSeq()
+	membershipController.healingProbes[seq] = &HealingProbeInfo{peer: peer, sentAt: now}
+	log.Println("Membership controller: trying to reconnect to", peer.Addrs, "of the peer history")
+	membershipController.sendPing(peer, seq)
+}
+
+// expireHealingProbes is the method for forgetting the healing probes which
+// have not been acked within a protocol period. Their peers are kept in the
+// history, so that they can be probed again at a later healing round.
+func (membershipController *MembershipController) expireHealingProbes(now time.Time) {
+	timeout := membershipController.failureDetector.config.protocolPeriod
+	for seq, info := range membershipController.healingProbes {
+		if now.Sub(info.sentAt) >= timeout {
+			delete(membershipController.healingProbes, seq)
+		}
+	}
+}
+
+// mergeWith is the method for putting the reconnected peer of the history
+// into the viewList, and pulling from it at the next membership round.
+func (membershipController *MembershipController) mergeWith(peer Peer) {
+	if membershipController.viewList.IsMember(peer) {
+		return
+	}
+	log.Println("Membership controller:", peer.Addrs, "of the peer history is reachable, merging the views")
+	membershipController.viewList.Add(peer)
+	membershipController.healedPeers.Add(peer)
+	log.Println("Membership controller -> Central controller, PeerAddMSG,", peer)
+	membershipController.MsgOutQueue <- InternalMessage{Type: PeerAddMSG, Payload: peer}
+}
+
+// expireSamplerValidations is the method for retrying the validation pings
+// which have not been acked within a protocol period. The peer samplers are
+// only reinitialised, after their peer has missed samplerValidationAttempts
+// validation pings in a row.
+func (membershipController *MembershipController) expireSamplerValidations(now time.Time) {
+	timeout := membershipController.failureDetector.config.protocolPeriod
+	for seq, info := range membershipController.samplerValidations {
+		if now.Sub(info.sentAt) < timeout {
+			continue
+		}
+		delete(membershipController.samplerValidations, seq)
+		if !membershipController.sampleList.IsMember(info.id) {
+			continue
+		}
+		if info.misses+1 < samplerValidationAttempts {
+			seq := membershipController.failureDetector.NextSeq()
+			membershipController.samplerValidations[seq] = &SamplerValidationInfo{
+				id: info.id, sentAt: now, misses: info.misses + 1,
+			}
+			membershipController.sendPing(membershipController.sampledPeer(info.id), seq)
+			continue
+		}
+		log.Println("Membership controller: sampled peer", info.id, "is dead, reinitialising its samplers")
+		membershipController.releaseSamplers(info.id)
+	}
+}
+
+// sendPing is the method for sending a failure detector ping to the peer.
+func (membershipController *MembershipController) sendPing(peer Peer, seq uint64) {
+	payload := SWIMPingMSGPayload{To: peer, Seq: seq, Updates: membershipController.failureDetector.Piggyback()}
+	log.Println("Membership controller -> Central controller, SWIMPingMSG,", payload)
+	membershipController.MsgOutQueue <- InternalMessage{Type: SWIMPingMSG, Payload: payload}
+}
+
+// sendAck is the method for acknowledging the ping of the peer.
+func (membershipController *MembershipController) sendAck(peer Peer, seq uint64) {
+	payload := SWIMAckMSGPayload{To: peer, Seq: seq, Updates: membershipController.failureDetector.Piggyback()}
+	log.Println("Membership controller -> Central controller, SWIMAckMSG,", payload)
+	membershipController.MsgOutQueue <- InternalMessage{Type: SWIMAckMSG, Payload: payload}
+}
+
+// applySWIMUpdates is the method for applying the membership updates
+// piggybacked on a failure detector message. Peers confirmed as failed
+// are removed.
+func (membershipController *MembershipController) applySWIMUpdates(updates []SWIMUpdate) {
+	now := time.Now()
+	for _, update := range updates {
+		state, changed := membershipController.failureDetector.Apply(update, now)
+		if changed && state == SWIMFailed {
+			log.Println("Membership controller: failure of", update.Peer.Addrs, "is confirmed by a peer")
+			membershipController.removePeer(update.Peer)
+		}
+	}
+}
+
+// swimRound is executed once every ping timeout to drive the failure
+// detector. The probe of a protocol period starts with a direct ping. After
+// the ping timeout, some peers in the viewList are asked to ping the target
+// on our behalf. If the target is still not acknowledged at the end of the
+// protocol period, then it is suspected. Suspected peers that have not
+// refuted the suspicion in time are declared as failed and removed.
+func (membershipController *MembershipController) swimRound() {
+	now := time.Now()
+	membershipController.expireSamplerValidations(now)
+	membershipController.expireHealingProbes(now)
+	membershipController.reconnectStaticPeers(now)
+	fd := membershipController.failureDetector
+	for _, peer := range fd.Expire(now) {
+		log.Println("Membership controller: failure of", peer.Addrs, "is confirmed")
+		membershipController.removePeer(peer)
+	}
+	probe := fd.Probe()
+	if probe != nil && !probe.acked {
+		elapsed := now.Sub(probe.sentAt)
+		if elapsed < fd.config.protocolPeriod {
+			if !probe.indirectSent && elapsed >= fd.config.pingTimeout {
+				probe.indirectSent = true
+				membershipController.sendPingRequests(probe)
+			}
+			return
+		}
+		if fd.Suspect(probe.target, now) {
+			log.Println("Membership controller:", probe.target.Addrs, "is suspected")
+		}
+	} else if probe != nil && now.Sub(probe.sentAt) < fd.config.protocolPeriod {
+		return
+	}
+	if probe, ok := fd.StartProbe(now); ok {
+		membershipController.sendPing(probe.target, probe.seq)
+	}
+}
+
+// sendPingRequests is the method for asking up to 'indirectProbes' random
+// alive peers in the viewList to ping the target of the probe.
+func (membershipController *MembershipController) sendPingRequests(probe *SWIMProbeInfo) {
+	fd := membershipController.failureDetector
+	size := membershipController.viewList.Len()
+	sent := 0
+	for _, i := range mrand.Perm(size) {
+		if sent >= fd.config.indirectProbes {
+			break
+		}
+		peer := membershipController.viewList.PeerAtIndex(i)
+		if peer.ID == probe.target.ID || fd.IsSuspected(peer) {
+			continue
+		}
+		payload := SWIMPingRequestMSGPayload{To: peer, Seq: probe.seq, Target: probe.target, Updates: fd.Piggyback()}
+		log.Println("Membership controller -> Central controller, SWIMPingRequestMSG,", payload)
+		membershipController.MsgOutQueue <- InternalMessage{Type: SWIMPingRequestMSG, Payload: payload}
+		sent++
 	}
 }
 
@@ -530,28 +1166,145 @@ func (membershipController *MembershipController) probePeerRound() {
 // Normally it is executed only periodically. However, if bootstrapping for
 // the first time, the round is also executed.
 func (membershipController *MembershipController) membershipRound() {
+	if err := membershipController.renewSelfRecord(time.Now().UTC()); err != nil {
+		log.Println("Membership controller: cannot renew the peer record:", err)
+	}
+	membershipController.pruneBannedRound()
+	if membershipController.viewList.Len() <= len(membershipController.staticPeers) {
+		// Retry identifying the bootstrapper until this node joins the network.
+		membershipController.identifyBootstrapper()
+	}
 	membershipController.pushRound()
 	membershipController.pullRound()
 	membershipController.updateRound()
 	membershipController.updateSampleRound()
+	membershipController.historyRound()
 
-	membershipController.probePeerRound()
+	membershipController.trackPeerRound()
+	membershipController.prunePeerRecordRound()
+	membershipController.reputations.Recover(time.Now().UTC())
+}
+
+// renewSelfRecord is the method for signing a new peer record of this node
+// if the current one is going to expire within half of its lifetime.
+func (membershipController *MembershipController) renewSelfRecord(now time.Time) error {
+	lifetime := membershipController.peerRecordLifetime
+	if record := membershipController.selfRecord; record != nil && record.Expires.Sub(now) > lifetime/2 {
+		return nil
+	}
+	// The creation time keeps the sequence numbers increasing across restarts.
+	record, err := NewPeerRecord(membershipController.hostKey, membershipController.self.Addrs,
+		uint64(now.UnixNano()), now.Add(lifetime))
+	if err != nil {
+		return err
+	}
+	membershipController.selfRecord = record
+	return nil
+}
+
+// storePeerRecord is the method for keeping the newest of the verified
+// peer record and the stored one. It returns the peer advertised by it.
+func (membershipController *MembershipController) storePeerRecord(record *PeerRecord) Peer {
+	peer := record.Peer()
+	stored, isMember := membershipController.peerRecords[peer.ID]
+	if isMember && stored.Seq >= record.Seq {
+		return stored.Peer()
+	}
+	membershipController.peerRecords[peer.ID] = record
+	// A record newer than the leave announcement means that the peer is back.
+	delete(membershipController.departedPeers, peer.ID)
+	return peer
+}
+
+// hasLeft returns true iff the peer record was created before
+// its peer announced to leave the network.
+func (membershipController *MembershipController) hasLeft(record *PeerRecord) bool {
+	leave, isMember := membershipController.departedPeers[record.Peer().ID]
+	return isMember && leave.Outdates(record)
+}
+
+// prunePeerRecordRound is the method for forgetting the expired peer
+// records and the records of the peers which are not in any list anymore.
+func (membershipController *MembershipController) prunePeerRecordRound() {
+	now := time.Now().UTC()
+	for id, record := range membershipController.peerRecords {
+		if !now.Before(record.Expires) || !(membershipController.viewList.IsMember(Peer{ID: id}) ||
+			membershipController.sampleList.IsMember(id) ||
+			membershipController.pushRequests.IsMember(Peer{ID: id}) ||
+			membershipController.pullReplies.IsMember(Peer{ID: id})) {
+			delete(membershipController.peerRecords, id)
+		}
+	}
+	// Every record created before a leave announcement expires within a lifetime.
+	for id, leave := range membershipController.departedPeers {
+		if now.Sub(leave.When) > membershipController.peerRecordLifetime {
+			delete(membershipController.departedPeers, id)
+		}
+	}
 }
 
-// bootstrap puts the bootstrapper peer into the viewList and starts
-// a fresh membership round.
+// bootstrap puts the bootstrapper peer and the static peers into
+// the viewList and starts a fresh membership round.
 func (membershipController *MembershipController) bootstrap() {
-	if membershipController.bootstrapper == "" {
-		return
+	membershipController.identifyBootstrapper()
+	newViewList := NewPeerSet()
+	if membershipController.bootstrapper.ID != (Identity{}) {
+		newViewList.Add(membershipController.bootstrapper)
 	}
-	newViewList := indexedset.New().Add(Peer{Addr: membershipController.bootstrapper})
 	membershipController.replaceViewList(newViewList)
+	if membershipController.viewList.Len() == 0 {
+		// Rejoin the network through the peers remembered before a restart.
+		membershipController.healingRound()
+		return
+	}
 	membershipController.pushProbability = 0.0
 	// execute a round of push and pull with the bootstrapper peer
 	membershipController.pushRound()
 	membershipController.pullRound()
 }
 
+// identifyBootstrapper is the method for learning the identity of the
+// bootstrapper, which is needed for keying it, from a handshake with it.
+// The bootstrapper is bootstrapped from once it is identified, so that it
+// does not have to be reachable when this node starts.
+func (membershipController *MembershipController) identifyBootstrapper() {
+	bootstrapper := membershipController.bootstrapper
+	if bootstrapper.ID != (Identity{}) || len(bootstrapper.Addrs) == 0 || membershipController.identifyingBootstrapper {
+		return
+	}
+	membershipController.identifyingBootstrapper = true
+	payload := PeerIdentifyMSGPayload{Addrs: bootstrapper.Addrs}
+	log.Println("Membership controller -> Central controller, PeerIdentifyMSG,", payload)
+	membershipController.MsgOutQueue <- InternalMessage{Type: PeerIdentifyMSG, Payload: payload}
+}
+
+// peerIdentifiedHandler is the method called by controllerRoutine for when
+// it receives an internal message of type PeerIdentifiedMSG.
+func (membershipController *MembershipController) peerIdentifiedHandler(payload AnyMessage) error {
+	msg, ok := payload.(PeerIdentifiedMSGPayload)
+	if !ok {
+		return nil
+	}
+	membershipController.identifyingBootstrapper = false
+	if msg.Peer.ID == (Identity{}) {
+		// Retried at the next membership round.
+		log.Println("Membership controller: cannot identify the bootstrapper", msg.Peer.Addrs)
+		return nil
+	}
+	if msg.Peer.ID == membershipController.self.ID {
+		panic(fmt.Sprintf("bootstrapper %v has the identity of this node, either it is this node"+
+			" or it shares the host key of this node", msg.Peer.Addrs))
+	}
+	membershipController.bootstrapper.ID = msg.Peer.ID
+	// Bootstrap only if this node has not joined the network in the meantime,
+	// e.g. through the peers of the history.
+	if membershipController.viewList.Len() <= len(membershipController.staticPeers) {
+		membershipController.bootstrap()
+	}
+
+	return nil
+}
+
 // peerDisconnectedHandler is the method called by controllerRoutine for when
 // it receives an internal message of type PeerDisconnectedMSG.
 func (membershipController *MembershipController) peerDisconnectedHandler(payload AnyMessage) error {
@@ -559,22 +1312,79 @@ func (membershipController *MembershipController) peerDisconnectedHandler(payloa
 	if !ok {
 		return nil
 	}
-	membershipController.removePeer(peer)
+	// The connection is already gone, but only the failure detector
+	// decides whether the peer itself has failed. A peer who announced
+	// to leave is not suspected, since it is known to be gone.
+	membershipController.removeFromViewList(peer)
+	if _, hasLeft := membershipController.departedPeers[peer.ID]; hasLeft {
+		return nil
+	}
+	if membershipController.failureDetector.Suspect(peer, time.Now()) {
+		log.Println("Membership controller:", peer.Addrs, "is suspected")
+	}
 
 	return nil
 }
 
-// probePeerReplyHandler is the method called by controllerRoutine for when
-// it receives an internal message of type ProbePeerReplyMSG.
-func (membershipController *MembershipController) probePeerReplyHandler(payload AnyMessage) error {
-	reply, ok := payload.(ProbePeerReplyMSGPayload)
+// swimIncomingPingHandler is the method called by controllerRoutine for when
+// it receives an internal message of type SWIMIncomingPingMSG.
+func (membershipController *MembershipController) swimIncomingPingHandler(payload AnyMessage) error {
+	ping, ok := payload.(SWIMIncomingPingMSGPayload)
 	if !ok {
 		return nil
 	}
-	if reply.ProbeResult == false {
-		peer := reply.Probed
-		membershipController.removePeer(peer)
+	membershipController.applySWIMUpdates(ping.Updates)
+	membershipController.sendAck(ping.From, ping.Seq)
+
+	return nil
+}
+
+// swimIncomingAckHandler is the method called by controllerRoutine for when
+// it receives an internal message of type SWIMIncomingAckMSG.
+func (membershipController *MembershipController) swimIncomingAckHandler(payload AnyMessage) error {
+	ack, ok := payload.(SWIMIncomingAckMSGPayload)
+	if !ok {
+		return nil
+	}
+	membershipController.applySWIMUpdates(ack.Updates)
+	fd := membershipController.failureDetector
+	if fd.Ack(ack.Seq) {
+		return nil
+	}
+	// The sampled peer of a validation ping is alive.
+	if _, isMember := membershipController.samplerValidations[ack.Seq]; isMember {
+		delete(membershipController.samplerValidations, ack.Seq)
+		return nil
+	}
+	// The peer of the history is reachable again.
+	if info, isMember := membershipController.healingProbes[ack.Seq]; isMember {
+		delete(membershipController.healingProbes, ack.Seq)
+		membershipController.mergeWith(info.peer)
+		return nil
+	}
+	// Forward the ack of a ping sent on behalf of another peer.
+	if forward, isMember := fd.TakeForward(ack.Seq); isMember {
+		membershipController.sendAck(forward.requester, forward.seq)
+	}
+
+	return nil
+}
+
+// swimIncomingPingRequestHandler is the method called by controllerRoutine for when
+// it receives an internal message of type SWIMIncomingPingRequestMSG.
+func (membershipController *MembershipController) swimIncomingPingRequestHandler(payload AnyMessage) error {
+	pr, ok := payload.(SWIMIncomingPingRequestMSGPayload)
+	if !ok {
+		return nil
 	}
+	membershipController.applySWIMUpdates(pr.Updates)
+	if pr.Target.ValidateAddr() != nil || membershipController.isBanned(pr.Target, time.Now().UTC()) {
+		return nil
+	}
+	fd := membershipController.failureDetector
+	seq := fd.NextSeq()
+	fd.AddForward(seq, pr.From, pr.Seq, time.Now())
+	membershipController.sendPing(pr.Target, seq)
 
 	return nil
 }
@@ -586,16 +1396,79 @@ func (membershipController *MembershipController) incomingPushRequestHandler(pay
 	if !ok {
 		return nil
 	}
-	if time.Now().UTC().Sub(pr.When) <= membershipController.powConfig.validityDuration &&
-		pr.To.Addr == membershipController.p2pAddr {
-		k := PoWThreshold(membershipController.powConfig.repetition, 256)
-		hashVal, err := pr.HashVal(membershipController.powConfig.hardness)
-		if err == nil && hashVal.Cmp(k) <= 0 {
-			// If the pushed peer is valid, then add to pushRequests.
-			if pr.From.ValidateAddr() == nil {
-				membershipController.pushRequests.Add(pr.From)
-			}
+	now := time.Now().UTC()
+	if now.Sub(pr.When) > membershipController.powConfig.validityDuration ||
+		pr.To.ID != membershipController.self.ID {
+		return nil
+	}
+	// If the pushed peer is invalid or banned, then don't bother verifying.
+	if pr.From.ValidateAddr() != nil || membershipController.isBanned(pr.From, now) {
+		return nil
+	}
+	// The pushed peer has to be advertised by its own peer record, which
+	// is not outdated by a leave announcement.
+	if pr.Record == nil || !pr.Record.Advertises(pr.From) ||
+		pr.Record.Verify(now, membershipController.peerRecordLifetime) != nil || membershipController.hasLeft(pr.Record) {
+		return nil
+	}
+	// Verify at most one push request of a peer at a time.
+	if membershipController.pushRequests.IsMember(pr.From) || membershipController.pendingVerifications.IsMember(pr.From.ID) {
+		return nil
+	}
+	hardness := membershipController.powConfig.hardness
+	repetition := membershipController.powConfig.repetition
+	job := func(ctx context.Context) *InternalMessage {
+		// Once the pool is closed, the verification is not started
+		// and its result is dropped, like a cancelled generation.
+		if ctx.Err() != nil {
+			return nil
+		}
+		k := PoWThreshold(repetition, 256)
+		hashVal, err := pr.HashVal(hardness)
+		if ctx.Err() != nil {
+			return nil
 		}
+		payload := MembershipPushVerifiedMSGPayload{Request: pr, Valid: err == nil && hashVal.Cmp(k) <= 0}
+		log.Println("PoW worker -> Membership controller, MembershipPushVerifiedMSG,", payload)
+		return &InternalMessage{Type: MembershipPushVerifiedMSG, Payload: payload}
+	}
+	// Verifications must leave room for generating our own push requests.
+	if !membershipController.powPool.TrySubmit(job, membershipController.powConfig.generationReserve) {
+		log.Println("Membership controller: PoW worker pool is saturated, dropping the push request of", pr.From.Addrs)
+		return nil
+	}
+	membershipController.pendingVerifications.Add(pr.From.ID)
+
+	return nil
+}
+
+// pushTokenHandler is the method called by controllerRoutine for when
+// it receives an internal message of type MembershipPushTokenMSG.
+func (membershipController *MembershipController) pushTokenHandler(payload AnyMessage) error {
+	token, ok := payload.(MembershipPushTokenMSGPayload)
+	if !ok || token.Request == nil {
+		return nil
+	}
+	// Ignore the push requests generated for a previous round.
+	if token.Round == membershipController.pushRoundCounter {
+		membershipController.pushTokens[token.Request.To.ID] = token.Request
+	}
+
+	return nil
+}
+
+// pushVerifiedHandler is the method called by controllerRoutine for when
+// it receives an internal message of type MembershipPushVerifiedMSG.
+func (membershipController *MembershipController) pushVerifiedHandler(payload AnyMessage) error {
+	msg, ok := payload.(MembershipPushVerifiedMSGPayload)
+	if !ok {
+		return nil
+	}
+	from := msg.Request.From
+	membershipController.pendingVerifications.Remove(from.ID)
+	// If the push request is valid and the pushed peer is not banned, then add to pushRequests.
+	if msg.Valid && !membershipController.isBanned(from, time.Now().UTC()) {
+		membershipController.pushRequests.Add(membershipController.storePeerRecord(msg.Request.Record))
 	}
 
 	return nil
@@ -608,10 +1481,14 @@ func (membershipController *MembershipController) incomingPullRequestHandler(pay
 	if !ok {
 		return nil
 	}
-	reply := MembershipPullReplyMSGPayload{To: pr.From, ViewList: make([]Peer, 0)}
-	for elem := range membershipController.viewList.Iterate() {
-		peer := elem.(Peer)
-		reply.ViewList = append(reply.ViewList, peer)
+	now := time.Now().UTC()
+	reply := MembershipPullReplyMSGPayload{To: pr.From, ViewList: make([]*PeerRecord, 0)}
+	for _, peer := range membershipController.viewList.Peers() {
+		// Don't spread the peers which may have failed or whose records are not valid anymore.
+		record, isMember := membershipController.peerRecords[peer.ID]
+		if isMember && now.Before(record.Expires) && !membershipController.failureDetector.IsSuspected(peer) {
+			reply.ViewList = append(reply.ViewList, record)
+		}
 	}
 	// Send the pull reply back to the Central controller.
 	log.Println("Membership controller -> Central controller, MembershipPullReplyMSG,", reply)
@@ -628,16 +1505,117 @@ func (membershipController *MembershipController) incomingPullReplyHandler(paylo
 		return nil
 	}
 	// Check if we actually asked for this pull reply.
-	if membershipController.pullPeers.IsMember(reply.From) {
-		membershipController.pullPeers.Remove(reply.From)
-		// Add all peers into the pullReplies.
-		for _, peer := range reply.ViewList {
-			// If the pushed peer is valid, then add to pullReplies.
-			if peer.ValidateAddr() == nil {
-				membershipController.pullReplies.Add(peer)
+	if !membershipController.pullPeers.IsMember(reply.From.ID) {
+		membershipController.penalizePeer(reply.From, UnsolicitedPullReply)
+		return nil
+	}
+	membershipController.pullPeers.Remove(reply.From.ID)
+	// Add all peers into the pullReplies.
+	now := time.Now().UTC()
+	hasInvalidRecord := false
+	for _, record := range reply.ViewList {
+		// Only the peers advertised by their own valid records are accepted.
+		if record == nil || record.Verify(now, membershipController.peerRecordLifetime) != nil {
+			hasInvalidRecord = true
+			continue
+		}
+		peer := record.Peer()
+		// If the pulled peer is not this node, not banned and has not left, then add to pullReplies.
+		if peer.ID != membershipController.self.ID && !membershipController.isBanned(peer, now) &&
+			!membershipController.hasLeft(record) {
+			membershipController.pullReplies.Add(membershipController.storePeerRecord(record))
+			if _, isMember := membershipController.pullReplySources[peer.ID]; !isMember {
+				membershipController.pullReplySources[peer.ID] = reply.From.ID
 			}
 		}
 	}
+	if hasInvalidRecord {
+		membershipController.penalizePeer(reply.From, InvalidPeerRecord)
+	}
+
+	return nil
+}
+
+// peerMisbehavedHandler is the method called by controllerRoutine for when
+// it receives an internal message of type PeerMisbehavedMSG.
+func (membershipController *MembershipController) peerMisbehavedHandler(payload AnyMessage) error {
+	msg, ok := payload.(PeerMisbehavedMSGPayload)
+	if !ok {
+		return nil
+	}
+	membershipController.penalizePeer(msg.Peer, msg.Reason)
+
+	return nil
+}
+
+// rpsQueryHandler is the method called by controllerRoutine for when
+// it receives an internal message of type MembershipRPSQueryMSG. A random
+// peer of the sampleList is sent to the api client, if there is any peer
+// that is not banned and has a valid peer record, which holds its host key.
+func (membershipController *MembershipController) rpsQueryHandler(payload AnyMessage) error {
+	msg, ok := payload.(MembershipRPSQueryMSGPayload)
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	candidates := make([]*PeerRecord, 0, membershipController.sampleList.Len())
+	for elem := range membershipController.sampleList.Iterate() {
+		peer := membershipController.sampledPeer(elem.(Identity))
+		record, isMember := membershipController.peerRecords[peer.ID]
+		if isMember && now.Before(record.Expires) && !membershipController.isBanned(peer, now) {
+			candidates = append(candidates, record)
+		}
+	}
+	if len(candidates) == 0 {
+		log.Println("Membership controller: no sampled peer for the RPS query of", msg.Who.addr)
+		return nil
+	}
+	record := candidates[mrand.Intn(len(candidates))]
+	reply := MembershipRPSPeerMSGPayload{Who: msg.Who, Peer: record.Peer(), PubKey: record.PubKey}
+	log.Println("Membership controller -> Central controller, MembershipRPSPeerMSG,", reply.Who, reply.Peer)
+	membershipController.MsgOutQueue <- InternalMessage{Type: MembershipRPSPeerMSG, Payload: reply}
+
+	return nil
+}
+
+// networkSizeHandler is the method called by controllerRoutine for when
+// it receives an internal message of type MembershipNetworkSizeMSG. The
+// capacities of viewList and sampleList are changed for the new estimate.
+func (membershipController *MembershipController) networkSizeHandler(payload AnyMessage) error {
+	params, ok := payload.(MembershipNetworkSizeMSGPayload)
+	if !ok {
+		return nil
+	}
+	membershipController.setViewListCap(params.ViewListCap)
+	membershipController.setSampleListCap(params.SampleListCap)
+
+	return nil
+}
+
+// peerLeftHandler is the method called by controllerRoutine for when it
+// receives an internal message of type MembershipPeerLeftMSG. The peer who
+// announced to leave is removed at once instead of waiting for the failure
+// detector, and it is neither pulled nor sampled again with an old record.
+func (membershipController *MembershipController) peerLeftHandler(payload AnyMessage) error {
+	msg, ok := payload.(MembershipPeerLeftMSGPayload)
+	if !ok || msg == nil {
+		return nil
+	}
+	leave := (*PeerLeave)(msg)
+	id := leave.ID()
+	// Ignore the announcement if the peer is already back with a newer record.
+	if record, isMember := membershipController.peerRecords[id]; isMember && !leave.Outdates(record) {
+		return nil
+	}
+	peer, isMember := membershipController.viewList.Get(id)
+	if !isMember {
+		peer = Peer{ID: id}
+	}
+	log.Println("Membership controller:", id, "left the network")
+	membershipController.departedPeers[id] = leave
+	delete(membershipController.peerRecords, id)
+	membershipController.removePeer(peer)
+	membershipController.failureDetector.Forget(peer)
 
 	return nil
 }
@@ -649,7 +1627,11 @@ func (membershipController *MembershipController) closeHandler(payload AnyMessag
 	if !ok {
 		return nil
 	}
-	// Clear the input queue.
+	// Stop the PoW workers and clear the input queue.
+	membershipController.powPool.Close()
+	if err := membershipController.history.Save(); err != nil {
+		log.Println("Membership controller: cannot save the peer history:", err)
+	}
 	for len(membershipController.MsgInQueue) > 0 {
 		<-membershipController.MsgInQueue
 	}
@@ -662,9 +1644,16 @@ func (membershipController *MembershipController) closeHandler(payload AnyMessag
 
 func (membershipController *MembershipController) controllerRoutine() {
 	defer membershipController.recover()
+	membershipController.powPool.Run()
 	membershipController.bootstrap()
 	roundTicker := time.NewTicker(membershipController.roundPeriod)
 	defer roundTicker.Stop()
+	swimTicker := time.NewTicker(membershipController.failureDetector.config.pingTimeout)
+	defer swimTicker.Stop()
+	validationTicker := time.NewTicker(membershipController.samplerValidationInterval)
+	defer validationTicker.Stop()
+	healingTicker := time.NewTicker(membershipController.healInterval)
+	defer healingTicker.Stop()
 
 	for done := false; !done; {
 		// Check for the round ticker first.
@@ -678,6 +1667,12 @@ func (membershipController *MembershipController) controllerRoutine() {
 		select {
 		case <-roundTicker.C:
 			membershipController.membershipRound()
+		case <-swimTicker.C:
+			membershipController.swimRound()
+		case <-validationTicker.C:
+			membershipController.samplerValidationRound()
+		case <-healingTicker.C:
+			membershipController.healingRound()
 		case im := <-membershipController.MsgInQueue:
 			handler := membershipControllerHandlers[im.Type]
 			err := handler(membershipController, im.Payload)
@@ -700,37 +1695,87 @@ func (membershipController *MembershipController) RunControllerGoroutine() {
 
 func (membershipController *MembershipController) String() string {
 	reprFormat := "*MembershipController{\n" +
-		"\tbootstrapper: %q,\n" +
-		"\tp2pAddr: %q,\n" +
+		"\tbootstrapper: %v,\n" +
+		"\tidentifyingBootstrapper: %t,\n" +
+		"\tself: %v,\n" +
+		"\tselfRecord: %v,\n" +
+		"\tpeerRecordLifetime: %s,\n" +
+		"\tpeerRecords: %v,\n" +
+		"\tdepartedPeers: %v,\n" +
 		"\talphaSize: %d,\n" +
 		"\tbetaSize: %d,\n" +
 		"\tgammaSize: %d,\n" +
+		"\talpha: %f,\n" +
+		"\tbeta: %f,\n" +
 		"\tpushProbability: %f,\n" +
 		"\troundPeriod: %s,\n" +
 		"\tpowConfig: %v,\n" +
 		"\tviewList: %v,\n" +
 		"\tviewListCap: %d,\n" +
+		"\tstaticPeers: %v,\n" +
+		"\tstaticBackoffMin: %s,\n" +
+		"\tstaticBackoffMax: %s,\n" +
 		"\tsampleList: %v,\n" +
+		"\tsampleListCap: %d,\n" +
 		"\tsampleListRemainingCap: %d,\n" +
+		"\tsamplerValidationInterval: %s,\n" +
+		"\tsamplerValidations: %v,\n" +
+		"\thistory: %s,\n" +
+		"\thealInterval: %s,\n" +
+		"\thealingProbes: %v,\n" +
+		"\thealedPeers: %s,\n" +
 		"\tpushRequests: %s,\n" +
 		"\tpullReplies: %s,\n" +
+		"\tpullReplySources: %v,\n" +
+		"\tdiversity: %+v,\n" +
+		"\tdiversityStats: %v,\n" +
 		"\tpullPeers: %s,\n" +
+		"\treputations: %s,\n" +
+		"\tfailureDetector: %s,\n" +
+		"\tpowPool: %s,\n" +
+		"\tpushTokens: %v,\n" +
+		"\tpendingVerifications: %s,\n" +
 		"}"
 	return fmt.Sprintf(reprFormat,
 		membershipController.bootstrapper,
-		membershipController.p2pAddr,
+		membershipController.identifyingBootstrapper,
+		membershipController.self,
+		membershipController.selfRecord,
+		membershipController.peerRecordLifetime,
+		membershipController.peerRecords,
+		membershipController.departedPeers,
 		membershipController.alphaSize,
 		membershipController.betaSize,
 		membershipController.gammaSize,
+		membershipController.alpha,
+		membershipController.beta,
 		membershipController.pushProbability,
 		membershipController.roundPeriod,
 		membershipController.powConfig,
 		membershipController.viewList,
 		membershipController.viewListCap,
+		membershipController.staticPeers,
+		membershipController.staticBackoffMin,
+		membershipController.staticBackoffMax,
 		membershipController.sampleList,
+		membershipController.sampleListCap,
 		membershipController.sampleListRemainingCap,
+		membershipController.samplerValidationInterval,
+		membershipController.samplerValidations,
+		membershipController.history,
+		membershipController.healInterval,
+		membershipController.healingProbes,
+		membershipController.healedPeers,
 		membershipController.pushRequests,
 		membershipController.pullReplies,
+		membershipController.pullReplySources,
+		membershipController.diversity,
+		membershipController.diversityStats,
 		membershipController.pullPeers,
+		membershipController.reputations,
+		membershipController.failureDetector,
+		membershipController.powPool,
+		membershipController.pushTokens,
+		membershipController.pendingVerifications,
 	)
 }
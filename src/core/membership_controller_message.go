@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	mrand "math/rand"
@@ -21,15 +22,63 @@ type PeerRemoveMSGPayload Peer
 // with type PeerDisconnectedMSG.
 type PeerDisconnectedMSGPayload Peer
 
-// ProbePeerRequestMSGPayload is the payload type of an InternalMessage
-// with type ProbePeerRequestMSG.
-type ProbePeerRequestMSGPayload Peer
+// SWIMPingMSGPayload is the payload type of an InternalMessage
+// with type SWIMPingMSG.
+type SWIMPingMSGPayload struct {
+	// To is the peer to ping (with p2p listen address).
+	To  Peer
+	Seq uint64
+	// Updates are the membership updates piggybacked on the ping.
+	Updates []SWIMUpdate
+}
+
+// SWIMIncomingPingMSGPayload is the payload type of an InternalMessage
+// with type SWIMIncomingPingMSG.
+type SWIMIncomingPingMSGPayload struct {
+	// From is the remote peer who sent the ping.
+	From    Peer
+	Seq     uint64
+	Updates []SWIMUpdate
+}
+
+// SWIMAckMSGPayload is the payload type of an InternalMessage
+// with type SWIMAckMSG.
+type SWIMAckMSGPayload struct {
+	// To is the remote peer who sent the ping.
+	To Peer
+	// Seq is the sequence number of the acknowledged ping.
+	Seq     uint64
+	Updates []SWIMUpdate
+}
+
+// SWIMIncomingAckMSGPayload is the payload type of an InternalMessage
+// with type SWIMIncomingAckMSG.
+type SWIMIncomingAckMSGPayload struct {
+	// From is the remote peer who sent the ack.
+	From    Peer
+	Seq     uint64
+	Updates []SWIMUpdate
+}
 
-// ProbePeerReplyMSGPayload is the payload type of an InternalMessage
-// with type ProbePeerReplyMSG.
-type ProbePeerReplyMSGPayload struct {
-	Probed      Peer
-	ProbeResult bool
+// SWIMPingRequestMSGPayload is the payload type of an InternalMessage
+// with type SWIMPingRequestMSG.
+type SWIMPingRequestMSGPayload struct {
+	// To is the peer asked to ping the target.
+	To  Peer
+	Seq uint64
+	// Target is the peer to ping (with p2p listen address).
+	Target  Peer
+	Updates []SWIMUpdate
+}
+
+// SWIMIncomingPingRequestMSGPayload is the payload type of an InternalMessage
+// with type SWIMIncomingPingRequestMSG.
+type SWIMIncomingPingRequestMSGPayload struct {
+	// From is the remote peer who asked for the ping.
+	From    Peer
+	Seq     uint64
+	Target  Peer
+	Updates []SWIMUpdate
 }
 
 // MembershipPushRequestMSGPayload is the payload type of an InternalMessage
@@ -43,6 +92,8 @@ type MembershipPushRequestMSGPayload struct {
 	When time.Time
 	// Nonce is the number used for the Proof of Work.
 	Nonce uint64
+	// Record is the signed peer record of the requesting peer.
+	Record *PeerRecord
 }
 
 // MembershipIncomingPushRequestMSGPayload is the payload type of an InternalMessage
@@ -65,8 +116,9 @@ type MembershipIncomingPullRequestMSGPayload struct {
 type MembershipPullReplyMSGPayload struct {
 	// To is the remote peer who sent the pull request.
 	To Peer
-	// ViewList is the list of peers in the viewList of the replying peer.
-	ViewList []Peer
+	// ViewList is the list of the signed peer records of the peers
+	// in the viewList of the replying peer.
+	ViewList []*PeerRecord
 }
 
 // MembershipIncomingPullReplyMSGPayload is the payload type of an InternalMessage
@@ -74,8 +126,9 @@ type MembershipPullReplyMSGPayload struct {
 type MembershipIncomingPullReplyMSGPayload struct {
 	// From is the remote peer who replied to the pull request.
 	From Peer
-	// ViewList is the list of peers in the viewList of the replying peer.
-	ViewList []Peer
+	// ViewList is the list of the signed peer records of the peers
+	// in the viewList of the replying peer.
+	ViewList []*PeerRecord
 }
 
 // MembershipCrashedMSGPayload is the payload type of an InternalMessage
@@ -90,6 +143,81 @@ type MembershipCloseMSGPayload void
 // with type MembershipClosedMSG.
 type MembershipClosedMSGPayload void
 
+// PeerMisbehavedMSGPayload is the payload type of an InternalMessage
+// with type PeerMisbehavedMSG.
+type PeerMisbehavedMSGPayload struct {
+	// Peer is the remote peer who violated the protocol.
+	Peer Peer
+	// Reason is the kind of protocol violation.
+	Reason PeerMisbehaviour
+}
+
+// MembershipPushTokenMSGPayload is the payload type of an InternalMessage
+// with type MembershipPushTokenMSG.
+type MembershipPushTokenMSGPayload struct {
+	// Round is the membership round the push request is generated for.
+	Round   uint64
+	Request *MembershipPushRequestMSGPayload
+}
+
+// MembershipPushVerifiedMSGPayload is the payload type of an InternalMessage
+// with type MembershipPushVerifiedMSG.
+type MembershipPushVerifiedMSGPayload struct {
+	Request MembershipPushRequestMSGPayload
+	// Valid is true iff the Proof of Work of the push request is valid.
+	Valid bool
+}
+
+// PeerBanMSGPayload is the payload type of an InternalMessage
+// with type PeerBanMSG.
+type PeerBanMSGPayload struct {
+	// Peer is the remote peer to be banned.
+	Peer Peer
+	// Until is the time when the ban expires (UTC).
+	Until time.Time
+}
+
+// MembershipRPSQueryMSGPayload is the payload type of an InternalMessage
+// with type MembershipRPSQueryMSG.
+type MembershipRPSQueryMSGPayload struct {
+	// Who is the api client who asked for a random peer.
+	Who APIClient
+}
+
+// MembershipRPSPeerMSGPayload is the payload type of an InternalMessage
+// with type MembershipRPSPeerMSG.
+type MembershipRPSPeerMSGPayload struct {
+	// Who is the api client who asked for a random peer.
+	Who APIClient
+	// Peer is the random peer picked from the sampleList.
+	Peer Peer
+	// PubKey is the PKCS #1 encoded RSA public key of the peer, i.e. its host key.
+	PubKey []byte
+}
+
+// MembershipNetworkSizeMSGPayload is the payload type of an InternalMessage
+// with type MembershipNetworkSizeMSG.
+type MembershipNetworkSizeMSGPayload NetworkSizeParams
+
+// PeerIdentifyMSGPayload is the payload type of an InternalMessage
+// with type PeerIdentifyMSG.
+type PeerIdentifyMSGPayload struct {
+	// Addrs are the addresses of the peer, which are tried in order.
+	Addrs []string
+}
+
+// PeerIdentifiedMSGPayload is the payload type of an InternalMessage
+// with type PeerIdentifiedMSG.
+type PeerIdentifiedMSGPayload struct {
+	// Peer is the identified peer with the addresses of the PeerIdentifyMSG.
+	// Its identity is zero if the peer could not be identified.
+	Peer Peer
+}
+
+// MembershipPeerLeftMSGPayload is the payload type of an InternalMessage
+// with type MembershipPeerLeftMSG. It is the verified leave announcement.
+type MembershipPeerLeftMSGPayload *PeerLeave
+
 // HashVal is the common cryptographic hashing function for all
 // membership push requests.
 func (pr *MembershipPushRequestMSGPayload) HashVal(hardness uint64) (*big.Int, error) {
@@ -113,13 +241,17 @@ func PoWThreshold(repetition, bits uint64) *big.Int {
 }
 
 // NewMembershipPushRequestMSGPayload is the constructor function for struct type MembershipPushRequestMSGPayload.
+// The search for a valid nonce stops as soon as the context is cancelled.
 func NewMembershipPushRequestMSGPayload(
-	from, to Peer, hardness, repetition uint64,
+	ctx context.Context, from, to Peer, record *PeerRecord, hardness, repetition uint64,
 ) (*MembershipPushRequestMSGPayload, error) {
 	k := PoWThreshold(repetition, 256)
 
-	pr := &MembershipPushRequestMSGPayload{From: from, To: to, When: time.Now().UTC(), Nonce: mrand.Uint64()}
+	pr := &MembershipPushRequestMSGPayload{From: from, To: to, When: time.Now().UTC(), Nonce: mrand.Uint64(), Record: record}
 	for i := uint64(0); i < 2*repetition; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		hashVal, err := pr.HashVal(hardness)
 		if err != nil {
 			return nil, err
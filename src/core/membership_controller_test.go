@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScheduleStaticPeerBackoff(t *testing.T) {
+	membershipController := &MembershipController{staticBackoffMin: 6 * time.Second, staticBackoffMax: 120 * time.Second}
+	now := time.Now()
+	info := &StaticPeerInfo{connectedAt: now}
+	want := []time.Duration{6, 12, 24, 48, 96, 120, 120}
+	for i, backoff := range want {
+		membershipController.scheduleStaticPeer(info, now)
+		if got := info.retryAt.Sub(now); got != backoff*time.Second {
+			t.Fatalf("backoff after %d failures = %s, want %s", i, got, backoff*time.Second)
+		}
+	}
+
+	// The backoff must not overflow after many failures.
+	for _, failures := range []uint{31, 32, 63, 64, 1 << 20} {
+		info.failures = failures
+		membershipController.scheduleStaticPeer(info, now)
+		if got := info.retryAt.Sub(now); got != 120*time.Second {
+			t.Errorf("backoff after %d failures = %s, want %s", failures, got, 120*time.Second)
+		}
+	}
+
+	// A peer which stayed connected long enough starts over.
+	info.connectedAt = now.Add(-time.Hour)
+	membershipController.scheduleStaticPeer(info, now)
+	if got := info.retryAt.Sub(now); got != 6*time.Second {
+		t.Fatalf("backoff after a long connection = %s, want %s", got, 6*time.Second)
+	}
+}
+
+// testOverlay routes the messages of in-process Membership controllers to each
+// other, as the Central controllers would. While it is split, the messages
+// between the nodes of different sides are dropped.
+type testOverlay struct {
+	nodes map[Identity]*MembershipController
+	sides map[Identity]int
+	split bool
+}
+
+// newTestOverlayNode returns a Membership controller of the overlay on the given side.
+func (overlay *testOverlay) newTestOverlayNode(t *testing.T, side int, addr string) *MembershipController {
+	hostKey := newTestHostKey(t)
+	self := Peer{ID: identityOfTest(t, hostKey), Addrs: []string{addr}}
+	history, err := NewPeerHistory("", peerHistoryCap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	membershipController, err := NewMembershipController(
+		Peer{}, self, nil, hostKey, nil, history, DiversityPolicy{}, alpha, beta,
+		membershipRoundDuration, defaultSamplerValidationInterval, NewNetworkSizeParams(minNetworkSize, 4),
+		make(chan InternalMessage, inQueueSize), make(chan InternalMessage, inQueueSize),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay.nodes[self.ID] = membershipController
+	overlay.sides[self.ID] = side
+	return membershipController
+}
+
+// connect puts the peer into the viewList of the node, as a finished join would.
+func (overlay *testOverlay) connect(node, peer *MembershipController) {
+	node.storePeerRecord(peer.selfRecord)
+	node.viewList.Add(peer.self)
+}
+
+// deliver routes the messages of all nodes until no message is left.
+func (overlay *testOverlay) deliver(t *testing.T) {
+	for delivered := true; delivered; {
+		delivered = false
+		for _, node := range overlay.nodes {
+			for len(node.MsgOutQueue) > 0 {
+				delivered = true
+				overlay.route(t, node, <-node.MsgOutQueue)
+			}
+		}
+	}
+}
+
+// route hands a message of the node to the Membership controller of its receiver.
+func (overlay *testOverlay) route(t *testing.T, node *MembershipController, im InternalMessage) {
+	var to Peer
+	var incoming InternalMessage
+	switch payload := im.Payload.(type) {
+	case SWIMPingMSGPayload:
+		to = payload.To
+		incoming = InternalMessage{Type: SWIMIncomingPingMSG,
+			Payload: SWIMIncomingPingMSGPayload{From: node.self, Seq: payload.Seq, Updates: payload.Updates}}
+	case SWIMAckMSGPayload:
+		to = payload.To
+		incoming = InternalMessage{Type: SWIMIncomingAckMSG,
+			Payload: SWIMIncomingAckMSGPayload{From: node.self, Seq: payload.Seq, Updates: payload.Updates}}
+	case MembershipPullReplyMSGPayload:
+		to = payload.To
+		incoming = InternalMessage{Type: MembershipIncomingPullReplyMSG,
+			Payload: MembershipIncomingPullReplyMSGPayload{From: node.self, ViewList: payload.ViewList}}
+	case Peer:
+		if im.Type != MembershipPullRequestMSG {
+			return
+		}
+		to = payload
+		incoming = InternalMessage{Type: MembershipIncomingPullRequestMSG,
+			Payload: MembershipIncomingPullRequestMSGPayload{From: node.self}}
+	default:
+		return
+	}
+	receiver, isMember := overlay.nodes[to.ID]
+	if !isMember || (overlay.split && overlay.sides[to.ID] != overlay.sides[node.self.ID]) {
+		return
+	}
+	if err := membershipControllerHandlers[incoming.Type](receiver, incoming.Payload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hasSide returns true iff the viewList of the node has a peer of the side.
+func (overlay *testOverlay) hasSide(node *MembershipController, side int) bool {
+	for _, peer := range node.viewList.Peers() {
+		if overlay.sides[peer.ID] == side {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHealingMergesSplitNetwork(t *testing.T) {
+	overlay := &testOverlay{nodes: map[Identity]*MembershipController{}, sides: map[Identity]int{}, split: true}
+	a1 := overlay.newTestOverlayNode(t, 0, "10.0.0.1:6001")
+	a2 := overlay.newTestOverlayNode(t, 0, "10.0.1.1:6001")
+	b1 := overlay.newTestOverlayNode(t, 1, "10.1.0.1:6001")
+	b2 := overlay.newTestOverlayNode(t, 1, "10.1.1.1:6001")
+	for _, node := range overlay.nodes {
+		defer node.powPool.Close()
+	}
+	overlay.connect(a1, a2)
+	overlay.connect(a2, a1)
+	overlay.connect(b1, b2)
+	overlay.connect(b2, b1)
+	// b1 was seen by a1 before the network was split.
+	a1.history.Add(b1.self, time.Now().UTC().Add(-time.Hour))
+
+	// While the network is split, the views only converge on their own side
+	// and the healing probe of a1 is lost.
+	for round := 0; round < 3; round++ {
+		for _, node := range overlay.nodes {
+			node.membershipRound()
+		}
+		overlay.deliver(t)
+	}
+	a1.healingRound()
+	overlay.deliver(t)
+	if len(a1.healingProbes) != 1 {
+		t.Fatalf("a1 has %d healing probes, want 1", len(a1.healingProbes))
+	}
+	a1.expireHealingProbes(time.Now().Add(a1.failureDetector.config.protocolPeriod))
+	if len(a1.healingProbes) != 0 {
+		t.Fatalf("a1 has %d healing probes after they expired, want 0", len(a1.healingProbes))
+	}
+	if overlay.hasSide(a1, 1) || overlay.hasSide(a2, 1) || overlay.hasSide(b1, 0) || overlay.hasSide(b2, 0) {
+		t.Fatalf("the views crossed the split")
+	}
+	if a1.history.Len() != 2 {
+		t.Fatalf("the history of a1 has %d peers after the probe expired, want 2", a1.history.Len())
+	}
+
+	// Once the split is over, the healing probe reaches b1 and the views merge.
+	overlay.split = false
+	a1.healingRound()
+	overlay.deliver(t)
+	if !a1.viewList.IsMember(b1.self) {
+		t.Fatalf("b1 is not in the viewList of a1 after healing")
+	}
+	for round := 0; round < 2; round++ {
+		a1.membershipRound()
+		overlay.deliver(t)
+	}
+	if !a1.viewList.IsMember(b2.self) {
+		t.Fatalf("b2 of the view of b1 is not in the viewList of a1 after healing, got %s", a1.viewList)
+	}
+}
+
+func TestPushVerificationStopsWithThePool(t *testing.T) {
+	overlay := &testOverlay{nodes: map[Identity]*MembershipController{}, sides: map[Identity]int{}}
+	a := overlay.newTestOverlayNode(t, 0, "10.0.0.1:6001")
+	b := overlay.newTestOverlayNode(t, 0, "10.0.1.1:6001")
+	defer a.powPool.Close()
+	defer b.powPool.Close()
+
+	// The workers of the pool are not running, so the job stays queued.
+	pr := MembershipPushRequestMSGPayload{From: b.self, To: a.self, When: time.Now().UTC(), Record: b.selfRecord}
+	if err := a.incomingPushRequestHandler(pr); err != nil {
+		t.Fatal(err)
+	}
+	var job powJob
+	select {
+	case job = <-a.powPool.jobs:
+	default:
+		t.Fatalf("the push request was not submitted for verification")
+	}
+	if im := job(context.Background()); im == nil || im.Type != MembershipPushVerifiedMSG {
+		t.Fatalf("verification = %v, want a MembershipPushVerifiedMSG", im)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if im := job(ctx); im != nil {
+		t.Fatalf("verification after the pool was closed = %v, want nil", im)
+	}
+}
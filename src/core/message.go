@@ -13,14 +13,15 @@ const (
 	PeerRemoveMSG
 	// PeerDisconnectedMSG is a notification from the Central controller to
 	// the Membership controller that the peer is abruptly disconnected so that
-	// it can also remove it from its viewList.
+	// it can remove it from its viewList and let the failure detector suspect it.
 	PeerDisconnectedMSG
-	// ProbePeerRequestMSG is a command from the Membership controller to
-	// the Central controller for probing a peer.
-	ProbePeerRequestMSG
-	// ProbePeerReplyMSG is a reply from the Central controller to
-	// the Membership controller for probing a peer.
-	ProbePeerReplyMSG
+	// SWIMPingMSG is a command from the Membership controller to the Central
+	// controller for sending a failure detector ping to a peer. If the peer is
+	// not connected, then a short-lived authenticated connection is opened.
+	SWIMPingMSG
+	// SWIMIncomingPingMSG is a notification from the Central controller to
+	// the Membership controller for an incoming ping from the peer specified.
+	SWIMIncomingPingMSG
 	// MembershipPushRequestMSG is a command from the Membership controller to
 	// the Central controller for sending a push request to a peer.
 	MembershipPushRequestMSG
@@ -48,6 +49,54 @@ const (
 	// MembershipClosedMSG is a notification from the Membership controller to the
 	// Central controller for closing gracefully as requested.
 	MembershipClosedMSG
+	// PeerMisbehavedMSG is a notification from the Central controller to the
+	// Membership controller that the peer violated the protocol, so that its
+	// reputation can be reduced.
+	PeerMisbehavedMSG
+	// PeerBanMSG is a command from the Membership controller to the Central
+	// controller for disconnecting a peer and refusing its connections until
+	// the ban expires.
+	PeerBanMSG
+	// SWIMAckMSG is a reply from the Membership controller to the Central
+	// controller for acknowledging a ping of the peer specified.
+	SWIMAckMSG
+	// SWIMIncomingAckMSG is a notification from the Central controller to
+	// the Membership controller for an incoming ack from the peer specified.
+	SWIMIncomingAckMSG
+	// SWIMPingRequestMSG is a command from the Membership controller to the
+	// Central controller for asking a peer to ping another one on its behalf.
+	SWIMPingRequestMSG
+	// SWIMIncomingPingRequestMSG is a notification from the Central controller
+	// to the Membership controller for an incoming ping request from the peer specified.
+	SWIMIncomingPingRequestMSG
+	// MembershipPushTokenMSG is a notification from a PoW worker to the
+	// Membership controller that a push request for the next membership
+	// round is generated.
+	MembershipPushTokenMSG
+	// MembershipPushVerifiedMSG is a notification from a PoW worker to the
+	// Membership controller with the result of verifying the Proof of Work
+	// of an incoming push request.
+	MembershipPushVerifiedMSG
+	// MembershipRPSQueryMSG is a command from the Central controller to the
+	// Membership controller to pick a random peer from the sampleList for
+	// the api client specified.
+	MembershipRPSQueryMSG
+	// MembershipRPSPeerMSG is a reply from the Membership controller to the
+	// Central controller for a MembershipRPSQueryMSG.
+	MembershipRPSPeerMSG
+	// MembershipNetworkSizeMSG is a command from the Central controller to the
+	// Membership controller to resize its lists for the estimated network size.
+	MembershipNetworkSizeMSG
+	// MembershipPeerLeftMSG is a command from the Central controller to the
+	// Membership controller to remove a peer who announced to leave the network.
+	MembershipPeerLeftMSG
+	// PeerIdentifyMSG is a command from the Membership controller to the
+	// Central controller for learning the identity of the peer listening on
+	// the addresses specified from the handshake of a short-lived connection.
+	PeerIdentifyMSG
+	// PeerIdentifiedMSG is a reply from the Central controller to the
+	// Membership controller for a PeerIdentifyMSG.
+	PeerIdentifiedMSG
 )
 
 const (
@@ -106,20 +155,110 @@ const (
 	// GossiperClosedMSG is a notification from the Gossiper to the
 	// Central controller for closing gracefully as requested.
 	GossiperClosedMSG
+	// GossipPeerMisbehavedMSG is a notification from the Gossiper to the
+	// Central controller that the peer violated the gossip protocol.
+	GossipPeerMisbehavedMSG
+	// GossipRetractMSG is a command from the Central controller to the
+	// Gossiper to retract a gossip item by spreading the signed tombstone.
+	GossipRetractMSG
+	// GossipTombstonePushMSG is a command from the Gossiper to the Central
+	// controller to send the tombstones to the peer specified.
+	GossipTombstonePushMSG
+	// GossipIncomingTombstoneMSG is a notification from the Central controller
+	// to the Gossiper for the arrival of verified tombstones from a peer.
+	GossipIncomingTombstoneMSG
+	// GossipRetractionMSG is a command from the Gossiper to the Central
+	// controller to notify the corresponding API client that a gossip
+	// item of a data type it is interested in was retracted.
+	GossipRetractionMSG
+	// GossipKVPutMSG is a command from the Central controller to the
+	// Gossiper to write a value into the replicated key-value state.
+	GossipKVPutMSG
+	// GossipKVGetMSG is a command from the Central controller to the
+	// Gossiper to read a value from the replicated key-value state.
+	GossipKVGetMSG
+	// GossipKVSubscribeMSG is a command from the Central controller to the
+	// Gossiper to register the corresponding API client for notifications
+	// about the changes of the keys with the given prefix.
+	GossipKVSubscribeMSG
+	// GossipKVValueMSG is a reply from the Gossiper to the Central controller
+	// either for a GossipKVGetMSG or for notifying a subscribed API client
+	// about the change of a key.
+	GossipKVValueMSG
+	// GossipKVDigestMSG is a command from the Gossiper to the Central
+	// controller to send the digest of the key-value state to the peer specified.
+	GossipKVDigestMSG
+	// GossipKVIncomingDigestMSG is a notification from the Central controller
+	// to the Gossiper for the arrival of a key-value state digest from a peer.
+	GossipKVIncomingDigestMSG
+	// GossipKVDigestReplyMSG is a reply from the Gossiper to the Central
+	// controller for the incoming GossipKVIncomingDigestMSG from the peer specified.
+	GossipKVDigestReplyMSG
+	// GossipKVIncomingDigestReplyMSG is a notification from the Central controller
+	// to the Gossiper after receiving GossipKVDigestReplyMSGPayload from the peer.
+	GossipKVIncomingDigestReplyMSG
+	// GossipKVUpdateMSG is a command from the Gossiper to the Central
+	// controller to send key-value entries to the peer specified.
+	GossipKVUpdateMSG
+	// GossipKVIncomingUpdateMSG is a notification from the Central controller
+	// to the Gossiper for the arrival of key-value entries from a peer.
+	GossipKVIncomingUpdateMSG
+	// GossipDirectIncomingMSG is a notification from the Central controller
+	// to the Gossiper for the arrival of a directed message addressed to this node.
+	GossipDirectIncomingMSG
+	// GossipDirectNotificationMSG is a command from the Gossiper to the
+	// Central controller to deliver a directed message to the corresponding
+	// API client interested in its data type.
+	GossipDirectNotificationMSG
+	// GossipAggregatePushMSG is a command from the Gossiper to the Central
+	// controller to send half of the aggregation state to the peer specified.
+	GossipAggregatePushMSG
+	// GossipAggregateIncomingPushMSG is a notification from the Central
+	// controller to the Gossiper for the arrival of an aggregation state from a peer.
+	GossipAggregateIncomingPushMSG
+	// GossipAggregateContributeMSG is a command from the Central controller to
+	// the Gossiper to set the value an API client contributes to an aggregate.
+	GossipAggregateContributeMSG
+	// GossipAggregateQueryMSG is a command from the Central controller to the
+	// Gossiper to read the estimates of the network size and of an aggregate.
+	GossipAggregateQueryMSG
+	// GossipAggregateEstimateMSG is a reply from the Gossiper to the Central
+	// controller for a GossipAggregateQueryMSG.
+	GossipAggregateEstimateMSG
+	// GossipNetworkSizeMSG is a notification from the Gossiper to the Central
+	// controller that the estimated network size changed enough for deriving
+	// new parameters from it.
+	GossipNetworkSizeMSG
+	// GossipAggregatePushFailedMSG is a notification from the Central
+	// controller to the Gossiper that an aggregation state of a
+	// GossipAggregatePushMSG could not be sent to the peer specified.
+	GossipAggregatePushFailedMSG
 )
 
 const (
 	// OutgoingP2PCreatedMSG is a reply from a goroutine that created
 	// an outgoing p2p endpoint to the Central controller.
 	OutgoingP2PCreatedMSG InternalMessageType = iota + 3000
-	// CentralProbePeerReplyMSG is a reply from a peer prober to
-	// the Central controller for probing a peer.
+	// CentralProbePeerReplyMSG is a reply from a peer prober to the Central
+	// controller for pinging a peer over a short-lived connection.
 	CentralProbePeerReplyMSG
 	// CentralCrashMSG is a command from a closing timer to the
 	// Central controller to panic and crash.
 	CentralCrashMSG
 	// CentralCloseMSG is a command from the User to the Central controller to close.
 	CentralCloseMSG
+	// DirectMSG is a command from the Central controller to a p2p endpoint
+	// to send a directed message to the remote peer.
+	DirectMSG
+	// DirectIncomingMSG is a notification from the Central controller to
+	// itself for the arrival of a directed message from a peer.
+	DirectIncomingMSG
+	// PeerLeaveMSG is a command from the Central controller to a p2p endpoint
+	// to send a leave announcement to the remote peer.
+	PeerLeaveMSG
+	// PeerIncomingLeaveMSG is a notification from the Central controller to
+	// itself for the arrival of a leave announcement from a peer.
+	PeerIncomingLeaveMSG
 )
 
 const (
@@ -146,6 +285,48 @@ const (
 	// APIValidationMSG is a notification from an APIEndpoint to
 	// the Central controller for an incoming GOSSIP VALIDATION api call.
 	APIValidationMSG
+	// APIRetractMSG is a command from an APIEndpoint to the Central
+	// controller to retract the gossip item provided in the payload,
+	// which has to be announced by an API client of this node.
+	APIRetractMSG
+	// APIRetractionMSG is a command from the Central controller to an
+	// APIEndpoint to notify the corresponding API client that a gossip
+	// item of a data type it is interested in was retracted.
+	APIRetractionMSG
+	// APIKVValueMSG is a command from the Central controller to an
+	// APIEndpoint to send the value of a key to the corresponding API client.
+	APIKVValueMSG
+	// APIDirectSendMSG is a command from an APIEndpoint to the Central
+	// controller to send a directed message to the node specified.
+	APIDirectSendMSG
+	// APIDirectReceiveMSG is a command from the Central controller to an
+	// APIEndpoint to deliver a directed message to the corresponding API client.
+	APIDirectReceiveMSG
+	// APIDirectAckMSG is a command from the Central controller to an
+	// APIEndpoint to notify the corresponding API client that its
+	// directed message was delivered.
+	APIDirectAckMSG
+	// APIAggregateEstimateMSG is a command from the Central controller to an
+	// APIEndpoint to send the estimates of the network size and of an aggregate
+	// to the corresponding API client.
+	APIAggregateEstimateMSG
+	// APIAdminBanMSG is a command from an APIEndpoint to the Central
+	// controller to ban an identity, an IP address or a CIDR network.
+	APIAdminBanMSG
+	// APIAdminUnbanMSG is a command from an APIEndpoint to the Central
+	// controller to lift the ban of an identity, an IP address or a CIDR network.
+	APIAdminUnbanMSG
+	// APIPeerSubscribeMSG is a command from an APIEndpoint to the Central
+	// controller to (un)register the corresponding API client for the
+	// peer lifecycle events.
+	APIPeerSubscribeMSG
+	// APIPeerEventMSG is a command from the Central controller to an
+	// APIEndpoint to notify the corresponding API client about a peer
+	// lifecycle event.
+	APIPeerEventMSG
+	// APIRPSPeerMSG is a command from the Central controller to an
+	// APIEndpoint to send a random sampled peer to the corresponding API client.
+	APIRPSPeerMSG
 )
 
 const (
@@ -171,7 +352,8 @@ const (
 	// the Central controller for closing gracefully as requested.
 	P2PListenerClosedMSG
 	// IncomingP2PCreatedMSG is a notification from the p2p listener to the
-	// Central controller that it created an incoming p2p endpoint.
+	// Central controller that it created an incoming p2p endpoint, whose
+	// remote peer is identified by the completed handshake.
 	IncomingP2PCreatedMSG
 )
 
@@ -188,6 +370,9 @@ const (
 	// IncomingP2PMSG is a notification from an p2p endpoint to the
 	// Central controller that it received a message from a peer.
 	IncomingP2PMSG
+	// P2PEndpointMalformedMSG is a notification from a p2p endpoint to the
+	// Central controller that it received a malformed message from a peer.
+	P2PEndpointMalformedMSG
 )
 
 // AnyMessage is the type of any internal message between goroutines
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// NetworkSizeParams holds the parameters of the protocols that depend on the
+// number of peers in the network. They are derived from the network size
+// estimated by the aggregation, instead of the maximum number of peers, so
+// that neither small networks waste resources nor large ones are
+// under-provisioned.
+type NetworkSizeParams struct {
+	// Size is the network size the parameters are derived from.
+	Size float64
+	// ViewListCap is the capacity of the viewList, which is O(n^0.25).
+	ViewListCap uint16
+	// SampleListCap is the number of PeerSampler's, which is O(n^0.5).
+	SampleListCap uint32
+	// MaxTTL is the maximum number of hops to propagate a gossip item, which is O(log(n)).
+	MaxTTL uint8
+	// CounterMax is both bMax and cMax of the "median-counter algorithm", which is O(loglog(n)).
+	CounterMax uint8
+}
+
+// viewListCapOf returns the capacity of the viewList for the network size.
+func viewListCapOf(size float64) uint16 {
+	return uint16(math.Max(minViewListCap, math.Floor(math.Pow(size, 0.25))))
+}
+
+// NewNetworkSizeParams is the constructor function for NetworkSizeParams.
+// The size is clamped between minNetworkSize and maxPeers, and degree is
+// the number of peers the Gossiper gossips with per round.
+func NewNetworkSizeParams(size float64, degree uint8) NetworkSizeParams {
+	size = math.Max(minNetworkSize, math.Min(maxPeers, size))
+	viewListCap := viewListCapOf(size)
+	denominator := math.Log2(math.Max(2, float64(degree)))
+	logN := math.Log2(size) / denominator
+	return NetworkSizeParams{
+		Size:          size,
+		ViewListCap:   viewListCap,
+		SampleListCap: uint32(math.Max(float64(viewListCap), math.Floor(size/float64(viewListCap)/float64(viewListCap)))),
+		MaxTTL:        uint8(math.Max(1, math.Ceil(logN))),
+		CounterMax:    uint8(math.Max(1, math.Ceil(math.Log2(logN)/denominator))),
+	}
+}
+
+// NeedsUpdate returns true iff the estimated network size differs from
+// the size the parameters are derived from by more than the factor
+// networkSizeHysteresis, so that the parameters do not flap with the
+// noise of the estimate. An estimate of 0 means that there is none yet.
+func (params NetworkSizeParams) NeedsUpdate(estimate float64) bool {
+	if estimate <= 0 {
+		return false
+	}
+	estimate = math.Max(minNetworkSize, math.Min(maxPeers, estimate))
+	return estimate > params.Size*networkSizeHysteresis || estimate < params.Size/networkSizeHysteresis
+}
+
+func (params NetworkSizeParams) String() string {
+	return fmt.Sprintf("{size: %.0f, viewListCap: %d, sampleListCap: %d, maxTTL: %d, counterMax: %d}",
+		params.Size, params.ViewListCap, params.SampleListCap, params.MaxTTL, params.CounterMax)
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"gossip/src/crypto/securecomm"
 	"gossip/src/datastruct/set"
+	"gossip/src/utils"
 	"io"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,18 +20,107 @@ func init() {
 	gob.Register(MembershipPullReplyMSGPayload{})
 	gob.Register(GossipPushMSGPayload{})
 	gob.Register(GossipPullReplyMSGPayload{})
+	gob.Register(GossipTombstonePushMSGPayload{})
+	gob.Register(GossipKVDigestMSGPayload{})
+	gob.Register(GossipKVDigestReplyMSGPayload{})
+	gob.Register(GossipKVUpdateMSGPayload{})
+	gob.Register(DirectMessage{})
+	gob.Register(GossipAggregatePushMSGPayload{})
+	gob.Register(SWIMPingMSGPayload{})
+	gob.Register(SWIMAckMSGPayload{})
+	gob.Register(SWIMPingRequestMSGPayload{})
+	gob.Register(PeerLeave{})
 }
 
-// Peer is just a placeholder for the TCP\IP address
-// of a peer.
+// Peer is a remote node of the P2P network. It is identified by ID, which
+// is proven by the handshake of every connection with the peer. Addrs are
+// the advertised P2P listen addresses of the peer in the order of preference,
+// e.g. both IPv4 and IPv6 addresses. They are only an attribute of the peer
+// and are empty if not known, e.g. for the peers of incoming connections.
+// Peers are always keyed by their IDs, never by their addresses.
 type Peer struct {
-	Addr string
+	ID    Identity
+	Addrs []string
 }
 
-// ValidateAddr tries to validate the address of a peer.
+// ValidateAddr tries to validate the addresses of a peer. A peer
+// without an identity or an address is never valid.
 func (peer *Peer) ValidateAddr() error {
-	_, err := net.ResolveTCPAddr("tcp", peer.Addr)
-	return err
+	if peer.ID == (Identity{}) {
+		return fmt.Errorf("peer %q has no identity", peer.Addrs)
+	}
+	if len(peer.Addrs) == 0 || len(peer.Addrs) > maxPeerAddrs {
+		return fmt.Errorf("peer %s has %d addresses", peer.ID, len(peer.Addrs))
+	}
+	for _, addr := range peer.Addrs {
+		if _, err := net.ResolveTCPAddr("tcp", addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPeerBanned returns true iff either the identity or an address of the peer is banned.
+func isPeerBanned(banList *securecomm.BanList, peer Peer) bool {
+	if banList.IsIdentityBanned(peer.ID) {
+		return true
+	}
+	for _, addr := range peer.Addrs {
+		if tcpAddr, err := utils.ParseIPPort(addr); err == nil && banList.IsIPBanned(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStaticPeers parses the static peer entries of the form
+// "<hex identity>@<address>". The entries of the same identity are
+// merged into a single peer whose addresses are tried in order.
+func parseStaticPeers(entries []string) ([]Peer, error) {
+	peers := NewPeerSet()
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, "@")
+		if i < 0 {
+			return nil, fmt.Errorf("static peer %q is not of the form <identity>@<address>", entry)
+		}
+		id, err := ParseIdentity(entry[:i])
+		if err != nil {
+			return nil, fmt.Errorf("static peer %q has an invalid identity: %v", entry, err)
+		}
+		peer, _ := peers.Get(id)
+		peers.Add(Peer{ID: id, Addrs: append(peer.Addrs, entry[i+1:])})
+	}
+	for _, peer := range peers.Peers() {
+		if err := peer.ValidateAddr(); err != nil {
+			return nil, err
+		}
+	}
+	return peers.Peers(), nil
+}
+
+// dialPeer connects to the peer by trying its addresses in order until
+// the handshake with one of them succeeds and proves the identity of
+// the peer. The handshake of the returned connection is completed.
+func dialPeer(peer Peer, config *securecomm.Config) (*securecomm.SecureConn, error) {
+	err := fmt.Errorf("peer %s has no address", peer.ID)
+	for _, addr := range peer.Addrs {
+		var secureConn *securecomm.SecureConn
+		secureConn, err = securecomm.DialWithDialer(&net.Dialer{Timeout: connectionTimeout}, "tcp", addr, config)
+		if err != nil {
+			continue
+		}
+		var id Identity
+		if id, err = IdentityOf(secureConn.RemotePublicKey()); err != nil {
+			secureConn.Close()
+			continue
+		}
+		if peer.ID == (Identity{}) || id == peer.ID {
+			return secureConn, nil
+		}
+		secureConn.Close()
+		err = fmt.Errorf("remote peer at %s is not %s", addr, peer.ID)
+	}
+	return nil, err
 }
 
 // PeerReaderState is a const type for describing the execution state of an
@@ -65,7 +156,9 @@ type PeerState struct {
 // is also a usageCounter for counting the number of times this peer
 // connection is used by the Gossiper goroutine who will use these
 // connections to actually do the gossiping. This struct is meant to be
-// used as a value in a map[Peer]*PeerInfoCentral by the Central controller.
+// used as a value in a map[Identity]*PeerInfoCentral by the Central controller.
+// The info of a connection started by the remote peer may be the value in both
+// the incoming view list and the view list, as the connection serves both directions.
 // Finally there are state variables for storing the state of a peer.
 type PeerInfoCentral struct {
 	endpoint     *P2PEndpoint
@@ -105,7 +198,8 @@ type P2PEndpoint struct {
 // requests and it will open a P2PEndpoint for each connection. Then by
 // using 'MsgOutQueue', it will inform the Central controller.
 type P2PListener struct {
-	ln *securecomm.SecureListener
+	// lns are the listening sockets, one for each listen address.
+	lns []*securecomm.SecureListener
 	// MsgOutQueue is the outgoing message queue from
 	// this P2PListener goroutine to the Central controller.
 	MsgOutQueue chan InternalMessage
@@ -115,29 +209,54 @@ type P2PListener struct {
 }
 
 // NewP2PListener is the constructor function of P2PListener struct.
+// It listens on every address in p2pAddrs.
 func NewP2PListener(
-	p2pAddr string, outQ chan InternalMessage, config *securecomm.Config,
+	p2pAddrs []string, outQ chan InternalMessage, config *securecomm.Config,
 ) (*P2PListener, error) {
-	lAddr, err := net.ResolveTCPAddr("tcp", p2pAddr)
-	if err != nil {
-		return nil, err
-	}
-	ln, err := securecomm.Listen("tcp", lAddr, config)
-	if err != nil {
+	lns := make([]*securecomm.SecureListener, 0, len(p2pAddrs))
+	for _, p2pAddr := range p2pAddrs {
+		lAddr, err := net.ResolveTCPAddr("tcp", p2pAddr)
+		if err == nil {
+			var ln net.Listener
+			if ln, err = securecomm.Listen("tcp", lAddr, config); err == nil {
+				lns = append(lns, ln.(*securecomm.SecureListener))
+				continue
+			}
+		}
+		// Do not leak the sockets that are already listening.
+		for _, ln := range lns {
+			ln.Close()
+		}
 		return nil, err
 	}
 
 	return &P2PListener{
-		ln:          ln.(*securecomm.SecureListener),
+		lns:         lns,
 		MsgOutQueue: outQ,
 		sigCh:       make(chan interface{}),
 	}, nil
 }
 
+// listenerRoutine accepts the connections on every listening socket
+// and informs the Central controller once all of them are closed.
 func (p2pListener *P2PListener) listenerRoutine() {
+	var wg sync.WaitGroup
+	for _, ln := range p2pListener.lns {
+		wg.Add(1)
+		go func(ln *securecomm.SecureListener) {
+			defer wg.Done()
+			p2pListener.acceptRoutine(ln)
+		}(ln)
+	}
+	wg.Wait()
+	log.Println("P2P Listener -> Central controller, P2PListenerClosedMSG")
+	p2pListener.MsgOutQueue <- InternalMessage{Type: P2PListenerClosedMSG, Payload: void{}}
+}
+
+func (p2pListener *P2PListener) acceptRoutine(ln *securecomm.SecureListener) {
 	defer p2pListener.recover()
 	for done := false; !done; {
-		conn, err := p2pListener.ln.Accept()
+		conn, err := ln.Accept()
 		if err != nil {
 			select {
 			case <-p2pListener.sigCh:
@@ -149,24 +268,38 @@ func (p2pListener *P2PListener) listenerRoutine() {
 			}
 		}
 
-		// Send the P2PEndpoint to the controller
-		peer := Peer{
-			Addr: conn.RemoteAddr().String(),
-		}
-		endp := &P2PEndpoint{
-			peer:        peer,
-			conn:        conn.(*securecomm.SecureConn),
-			MsgInQueue:  make(chan InternalMessage, outQueueSize),
-			MsgOutQueue: p2pListener.MsgOutQueue,
-			sigCh:       make(chan interface{}),
-			isOutgoing:  false,
-			closeOnce:   sync.Once{},
-		}
-		log.Println("P2P Listener -> Central controller, IncomingP2PCreatedMSG,", endp)
-		p2pListener.MsgOutQueue <- InternalMessage{Type: IncomingP2PCreatedMSG, Payload: endp}
+		// Identify the remote peer without blocking the listener.
+		go p2pListener.identifyIncoming(conn.(*securecomm.SecureConn))
 	}
-	log.Println("P2P Listener -> Central controller, P2PListenerClosedMSG")
-	p2pListener.MsgOutQueue <- InternalMessage{Type: P2PListenerClosedMSG, Payload: void{}}
+}
+
+// identifyIncoming completes the handshake of an incoming connection, so that
+// the identity of the remote peer is known, then sends the P2PEndpoint to the
+// Central controller. The advertised address of the remote peer is unknown.
+func (p2pListener *P2PListener) identifyIncoming(conn *securecomm.SecureConn) {
+	conn.SetDeadline(time.Now().Add(securecomm.HandshakeExpirationTime))
+	if err := conn.Handshake(); err != nil {
+		log.Println("Handshake with incoming P2P connection", conn.RemoteAddr(), "failed:", err)
+		conn.Close()
+		return
+	}
+	id, err := IdentityOf(conn.RemotePublicKey())
+	if err != nil {
+		log.Println("Incoming P2P connection", conn.RemoteAddr(), "is rejected:", err)
+		conn.Close()
+		return
+	}
+	endp := &P2PEndpoint{
+		peer:        Peer{ID: id},
+		conn:        conn,
+		MsgInQueue:  make(chan InternalMessage, outQueueSize),
+		MsgOutQueue: p2pListener.MsgOutQueue,
+		sigCh:       make(chan interface{}),
+		isOutgoing:  false,
+		closeOnce:   sync.Once{},
+	}
+	log.Println("P2P Listener -> Central controller, IncomingP2PCreatedMSG,", endp)
+	p2pListener.MsgOutQueue <- InternalMessage{Type: IncomingP2PCreatedMSG, Payload: endp}
 }
 
 // RunListenerGoroutine runs the goroutine that will listen
@@ -180,18 +313,22 @@ func (p2pListener *P2PListener) RunListenerGoroutine() {
 func (p2pListener *P2PListener) Close() error {
 	// Closing the 'sigCh' channel signals the listener to close itself.
 	close(p2pListener.sigCh)
-	p2pListener.ln.Close()
+	for _, ln := range p2pListener.lns {
+		ln.Close()
+	}
 	return nil
 }
 
-// NewP2PEndpoint is the constructor function of P2PEndpoint struct.
+// NewP2PEndpoint is the constructor function of P2PEndpoint struct. The
+// addresses of the peer are tried in order until one of them proves the
+// identity of the peer.
 func NewP2PEndpoint(
-	p2pAddr string, config *securecomm.Config, inQ, outQ chan InternalMessage, isOutgoing bool,
+	peer Peer, config *securecomm.Config, inQ, outQ chan InternalMessage, isOutgoing bool,
 ) (*P2PEndpoint, error) {
-	conn, err := securecomm.DialWithDialer(&net.Dialer{Timeout: connectionTimeout}, "tcp", p2pAddr, config)
+	conn, err := dialPeer(peer, config)
 
 	return &P2PEndpoint{
-		peer: Peer{Addr: p2pAddr}, conn: conn,
+		peer: peer, conn: conn,
 		MsgInQueue: inQ, MsgOutQueue: outQ,
 		sigCh: make(chan interface{}), isOutgoing: isOutgoing,
 		closeOnce: sync.Once{},
@@ -201,6 +338,21 @@ func NewP2PEndpoint(
 func (p2pEndpoint *P2PEndpoint) readerRoutine() {
 	defer p2pEndpoint.recover(true)
 
+	// Complete the handshake first, so that the identity of the remote peer is
+	// checked. The handshake of an incoming endpoint is already completed.
+	p2pEndpoint.conn.SetDeadline(time.Now().Add(securecomm.HandshakeExpirationTime))
+	if err := p2pEndpoint.conn.Handshake(); err != nil {
+		panic(fmt.Sprint("P2PEndpoint: Error in readerRoutine():", err))
+	}
+	// The writer goroutine shares the connection, so only the read
+	// deadline is set from now on and the write deadline is cleared.
+	p2pEndpoint.conn.SetDeadline(time.Time{})
+	if id, err := IdentityOf(p2pEndpoint.conn.RemotePublicKey()); err != nil {
+		panic(fmt.Sprint("P2PEndpoint: Error in readerRoutine():", err))
+	} else if id != p2pEndpoint.peer.ID {
+		panic(fmt.Sprint("P2PEndpoint: Error in readerRoutine(): remote peer is not ", p2pEndpoint.peer.ID))
+	}
+
 	reader := io.Reader(p2pEndpoint.conn)
 	gobDecoder := gob.NewDecoder(reader)
 
@@ -212,7 +364,7 @@ func (p2pEndpoint *P2PEndpoint) readerRoutine() {
 		default:
 			break
 		}
-		p2pEndpoint.conn.SetDeadline(time.Now().Add(closureCheckTimeout))
+		p2pEndpoint.conn.SetReadDeadline(time.Now().Add(closureCheckTimeout))
 
 		var message InternalMessage
 		err := gobDecoder.Decode(&message)
@@ -226,37 +378,167 @@ func (p2pEndpoint *P2PEndpoint) readerRoutine() {
 		var im *InternalMessage = nil
 		switch message.Type {
 		case MembershipPushRequestMSG:
-			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: MembershipIncomingPushRequestMSG, Payload: message.Payload}}
+			// Only the peer itself may push its own address.
+			if m, ok := message.Payload.(MembershipPushRequestMSGPayload); ok && m.From.ID == p2pEndpoint.peer.ID {
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: MembershipIncomingPushRequestMSG, Payload: message.Payload}}
+			}
 		case MembershipPullRequestMSG:
 			payload := MembershipIncomingPullRequestMSGPayload{From: p2pEndpoint.peer}
 			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: MembershipIncomingPullRequestMSG, Payload: payload}}
 		case MembershipPullReplyMSG:
-			m := message.Payload.(MembershipPullReplyMSGPayload)
-			payload := MembershipIncomingPullReplyMSGPayload{From: p2pEndpoint.peer, ViewList: m.ViewList}
-			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: MembershipIncomingPullReplyMSG, Payload: payload}}
+			if m, ok := message.Payload.(MembershipPullReplyMSGPayload); ok {
+				payload := MembershipIncomingPullReplyMSGPayload{From: p2pEndpoint.peer, ViewList: m.ViewList}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: MembershipIncomingPullReplyMSG, Payload: payload}}
+			}
 		case GossipPushMSG:
-			m := message.Payload.(GossipPushMSGPayload)
-			payload := GossipItemExtended{Item: m.Item, State: m.State, Counter: m.Counter}
-			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingPushMSG, Payload: payload}}
+			if m, ok := message.Payload.(GossipPushMSGPayload); ok && m.Item != nil {
+				payload := GossipItemExtended{Item: m.Item, State: m.State, Counter: m.Counter, From: p2pEndpoint.peer}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingPushMSG, Payload: payload}}
+			}
 		case GossipPullRequestMSG:
 			payload := GossipIncomingPullRequestMSGPayload{From: p2pEndpoint.peer}
 			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingPullRequestMSG, Payload: payload}}
 		case GossipPullReplyMSG:
-			m := message.Payload.(GossipPullReplyMSGPayload)
-			payload := GossipIncomingPullReplyMSGPayload{From: p2pEndpoint.peer, ItemList: m.ItemList}
-			im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingPullReplyMSG, Payload: payload}}
+			if m, ok := message.Payload.(GossipPullReplyMSGPayload); ok && p2pEndpoint.sanitizeItemList(m.ItemList) {
+				payload := GossipIncomingPullReplyMSGPayload{From: p2pEndpoint.peer, ItemList: m.ItemList}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingPullReplyMSG, Payload: payload}}
+			}
+		case GossipTombstonePushMSG:
+			if m, ok := message.Payload.(GossipTombstonePushMSGPayload); ok && sanitizeTombstones(m.Tombstones) {
+				payload := GossipIncomingTombstoneMSGPayload{From: p2pEndpoint.peer, Tombstones: m.Tombstones}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipIncomingTombstoneMSG, Payload: payload}}
+			}
+		case GossipKVDigestMSG:
+			if m, ok := message.Payload.(GossipKVDigestMSGPayload); ok {
+				payload := GossipKVIncomingDigestMSGPayload{From: p2pEndpoint.peer, Digest: m.Digest}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipKVIncomingDigestMSG, Payload: payload}}
+			}
+		case GossipKVDigestReplyMSG:
+			if m, ok := message.Payload.(GossipKVDigestReplyMSGPayload); ok && sanitizeKVEntries(m.Entries) {
+				payload := GossipKVIncomingDigestReplyMSGPayload{From: p2pEndpoint.peer, Entries: m.Entries, Request: m.Request}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipKVIncomingDigestReplyMSG, Payload: payload}}
+			}
+		case GossipKVUpdateMSG:
+			if m, ok := message.Payload.(GossipKVUpdateMSGPayload); ok && sanitizeKVEntries(m.Entries) {
+				payload := GossipKVIncomingUpdateMSGPayload{From: p2pEndpoint.peer, Entries: m.Entries}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipKVIncomingUpdateMSG, Payload: payload}}
+			}
+		case SWIMPingMSG:
+			if m, ok := message.Payload.(SWIMPingMSGPayload); ok {
+				payload := SWIMIncomingPingMSGPayload{From: p2pEndpoint.peer, Seq: m.Seq, Updates: m.Updates}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: SWIMIncomingPingMSG, Payload: payload}}
+			}
+		case SWIMAckMSG:
+			if m, ok := message.Payload.(SWIMAckMSGPayload); ok {
+				payload := SWIMIncomingAckMSGPayload{From: p2pEndpoint.peer, Seq: m.Seq, Updates: m.Updates}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: SWIMIncomingAckMSG, Payload: payload}}
+			}
+		case SWIMPingRequestMSG:
+			if m, ok := message.Payload.(SWIMPingRequestMSGPayload); ok {
+				payload := SWIMIncomingPingRequestMSGPayload{From: p2pEndpoint.peer, Seq: m.Seq, Target: m.Target, Updates: m.Updates}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: SWIMIncomingPingRequestMSG, Payload: payload}}
+			}
+		case GossipAggregatePushMSG:
+			if m, ok := message.Payload.(GossipAggregatePushMSGPayload); ok && m.State != nil {
+				payload := GossipAggregateIncomingPushMSGPayload{From: p2pEndpoint.peer, State: m.State}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: GossipAggregateIncomingPushMSG, Payload: payload}}
+			}
+		case DirectMSG:
+			if m, ok := message.Payload.(DirectMessage); ok {
+				payload := DirectIncomingMSGPayload{From: p2pEndpoint.peer, Message: &m}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: DirectIncomingMSG, Payload: payload}}
+			}
+		case PeerLeaveMSG:
+			if m, ok := message.Payload.(PeerLeave); ok {
+				payload := PeerIncomingLeaveMSGPayload{From: p2pEndpoint.peer, Leave: &m}
+				im = &InternalMessage{Type: IncomingP2PMSG, Payload: InternalMessage{Type: PeerIncomingLeaveMSG, Payload: payload}}
+			}
 		default:
 			log.Println("P2PEndpoint: Error in readerRoutine(): invalid internal message type used")
 			break
 		}
-		if im != nil {
+		if im == nil {
+			// The remote peer sent something that cannot be interpreted.
+			log.Println("P2P Endpoint -> Central controller, P2PEndpointMalformedMSG,", p2pEndpoint.peer)
+			p2pEndpoint.MsgOutQueue <- InternalMessage{Type: P2PEndpointMalformedMSG, Payload: P2PEndpointMalformedMSGPayload(p2pEndpoint.peer)}
+		} else {
 			log.Println("P2P Endpoint -> Central controller, IncomingP2PMSG,", *im)
 			p2pEndpoint.MsgOutQueue <- *im
 		}
 	}
-	payload := P2PEndpointClosedMSGPayload{endp: p2pEndpoint, isReader: true}
-	log.Println("P2P Endpoint -> Central controller, P2PEndpointClosedMSG,", payload)
-	p2pEndpoint.MsgOutQueue <- InternalMessage{Type: P2PEndpointClosedMSG, Payload: payload}
+	payload2 := P2PEndpointClosedMSGPayload{endp: p2pEndpoint, isReader: true}
+	log.Println("P2P Endpoint -> Central controller, P2PEndpointClosedMSG,", payload2)
+	p2pEndpoint.MsgOutQueue <- InternalMessage{Type: P2PEndpointClosedMSG, Payload: payload2}
+}
+
+// sanitizeItemList checks that every gossip item in the list is present and
+// overwrites the sender of every item with the remote peer of this endpoint.
+// Returns false if the list is malformed.
+func (p2pEndpoint *P2PEndpoint) sanitizeItemList(itemList []*GossipItemExtended) bool {
+	for _, itemExt := range itemList {
+		if itemExt == nil || itemExt.Item == nil {
+			return false
+		}
+		itemExt.From = p2pEndpoint.peer
+	}
+	return true
+}
+
+// sanitizeTombstones checks that every tombstone in the list is present.
+// Returns false if the list is malformed.
+func sanitizeTombstones(tombstones []*GossipTombstone) bool {
+	for _, tombstone := range tombstones {
+		if tombstone == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeKVEntries checks that every key-value entry in the list is present.
+// Returns false if the list is malformed.
+func sanitizeKVEntries(entries []*KVEntry) bool {
+	for _, entry := range entries {
+		if entry == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// probePeer pings the peer over a short-lived authenticated connection
+// and waits for its ack. The connection is closed afterwards.
+func probePeer(peer Peer, ping SWIMPingMSGPayload, config *securecomm.Config) (*SWIMAckMSGPayload, error) {
+	secureConn, err := dialPeer(peer, config)
+	if err != nil {
+		return nil, err
+	}
+	defer secureConn.Close()
+	secureConn.SetDeadline(time.Now().Add(connectionTimeout))
+	if err := gob.NewEncoder(secureConn).Encode(&InternalMessage{Type: SWIMPingMSG, Payload: ping}); err != nil {
+		return nil, err
+	}
+	var message InternalMessage
+	if err := gob.NewDecoder(secureConn).Decode(&message); err != nil {
+		return nil, err
+	}
+	ack, ok := message.Payload.(SWIMAckMSGPayload)
+	if message.Type != SWIMAckMSG || !ok || ack.Seq != ping.Seq {
+		return nil, fmt.Errorf("unexpected reply to ping: %v", message.Type)
+	}
+	return &ack, nil
+}
+
+// identifyPeer returns the identity of the peer listening on the addresses,
+// which is proven by the handshake of a short-lived connection. The addresses
+// are tried in order until one of them works.
+func identifyPeer(addrs []string, config *securecomm.Config) (Identity, error) {
+	secureConn, err := dialPeer(Peer{Addrs: addrs}, config)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer secureConn.Close()
+	return IdentityOf(secureConn.RemotePublicKey())
 }
 
 // RunReaderGoroutine runs the goroutine that will read from
@@ -273,7 +555,11 @@ func (p2pEndpoint *P2PEndpoint) writerRoutine() {
 	gobEncoder := gob.NewEncoder(writer)
 	allowedMSGs := set.New().Add(MembershipPushRequestMSG).
 		Add(MembershipPullRequestMSG).Add(MembershipPullReplyMSG).
-		Add(GossipPushMSG).Add(GossipPullRequestMSG).Add(GossipPullReplyMSG)
+		Add(GossipPushMSG).Add(GossipPullRequestMSG).Add(GossipPullReplyMSG).
+		Add(GossipTombstonePushMSG).Add(GossipKVDigestMSG).
+		Add(GossipKVDigestReplyMSG).Add(GossipKVUpdateMSG).Add(DirectMSG).
+		Add(GossipAggregatePushMSG).Add(SWIMPingMSG).Add(SWIMAckMSG).
+		Add(SWIMPingRequestMSG).Add(PeerLeaveMSG)
 
 	for done := false; !done; {
 		select {
@@ -312,7 +598,7 @@ func (p2pEndpoint *P2PEndpoint) RunWriterGoroutine() {
 func (p2pEndpoint *P2PEndpoint) Close() error {
 	p2pEndpoint.closeOnce.Do(func() {
 		// Send an InternalMessage to the writer for closing it!
-		log.Println("Central controller -> P2P Endpoint, P2PEndpointCloseMSG,", p2pEndpoint.peer.Addr)
+		log.Println("Central controller -> P2P Endpoint, P2PEndpointCloseMSG,", p2pEndpoint.peer.Addrs)
 		p2pEndpoint.MsgInQueue <- InternalMessage{Type: P2PEndpointCloseMSG, Payload: void{}}
 		// Closing the 'sigCh' channel signals the reader to close itself.
 		close(p2pEndpoint.sigCh)
@@ -364,7 +650,7 @@ func (p2pEndpoint *P2PEndpoint) recover(isReader bool) {
 
 		// send P2PListenerCrashedMSG to the Central controller!
 		payload := P2PEndpointCrashedMSGPayload{endp: p2pEndpoint, err: err, isReader: isReader}
-		log.Println("P2P Endpoint -> Central controller, P2PEndpointCrashedMSG,", p2pEndpoint.peer.Addr, err, isReader)
+		log.Println("P2P Endpoint -> Central controller, P2PEndpointCrashedMSG,", p2pEndpoint.peer.Addrs, err, isReader)
 		p2pEndpoint.MsgOutQueue <- InternalMessage{Type: P2PEndpointCrashedMSG, Payload: payload}
 	}
 }
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PeerHistoryEntry is a peer remembered by a PeerHistory with the last
+// time it was seen in the viewList.
+type PeerHistoryEntry struct {
+	peer     Peer
+	lastSeen time.Time
+}
+
+func (entry *PeerHistoryEntry) String() string {
+	return fmt.Sprintf("{peer: %s, lastSeen: %s}", entry.peer.Addrs, entry.lastSeen)
+}
+
+// PeerHistory is the long-lived set of the peers seen in the viewList. When
+// the network is split, the views of each side only converge on their own
+// side, so the peers remembered from before the split are the only way to
+// find the other side again. If it is full, then the peer seen least recently
+// is forgotten. If it has a path, then it is persisted into that file by
+// Save, one peer per line in the form
+// "<hex identity> <last seen in RFC 3339> <address>[,<address>...]", so that
+// it is remembered across restarts. It is not safe for concurrent use.
+type PeerHistory struct {
+	// path is the file the history is persisted in. It is empty if not persisted.
+	path string
+	// capacity is the maximum number of peers remembered.
+	capacity int
+	entries  map[Identity]*PeerHistoryEntry
+}
+
+// NewPeerHistory is the constructor function for PeerHistory. If path is not
+// empty, then the peers persisted there are loaded and the history is
+// persisted there.
+func NewPeerHistory(path string, capacity int) (*PeerHistory, error) {
+	history := &PeerHistory{path: path, capacity: capacity, entries: map[Identity]*PeerHistoryEntry{}}
+	if path == "" {
+		return history, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed peer history line %d in %q", lineNo, path)
+		}
+		id, err := ParseIdentity(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed peer identity on line %d in %q: %v", lineNo, path, err)
+		}
+		lastSeen, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed last seen time on line %d in %q: %v", lineNo, path, err)
+		}
+		peer := Peer{ID: id, Addrs: strings.Split(fields[2], ",")}
+		if err := peer.ValidateAddr(); err != nil {
+			return nil, fmt.Errorf("malformed peer addresses on line %d in %q: %v", lineNo, path, err)
+		}
+		history.Add(peer, lastSeen)
+	}
+	return history, scanner.Err()
+}
+
+// Add remembers the peer as seen at the given time, with its current addresses.
+func (history *PeerHistory) Add(peer Peer, now time.Time) {
+	if entry, isMember := history.entries[peer.ID]; isMember {
+		entry.peer = peer
+		if now.After(entry.lastSeen) {
+			entry.lastSeen = now
+		}
+		return
+	}
+	if len(history.entries) >= history.capacity {
+		var oldest *PeerHistoryEntry
+		for _, entry := range history.entries {
+			if oldest == nil || entry.lastSeen.Before(oldest.lastSeen) {
+				oldest = entry
+			}
+		}
+		if oldest == nil || !oldest.lastSeen.Before(now) {
+			return
+		}
+		delete(history.entries, oldest.peer.ID)
+	}
+	history.entries[peer.ID] = &PeerHistoryEntry{peer: peer, lastSeen: now}
+}
+
+// Remove forgets the peer, e.g. since it is banned.
+func (history *PeerHistory) Remove(id Identity) {
+	delete(history.entries, id)
+}
+
+// Random returns a random peer for which the filter returns true.
+// Returns false if there is no such peer.
+func (history *PeerHistory) Random(filter func(Peer) bool) (Peer, bool) {
+	candidates := make([]Peer, 0, len(history.entries))
+	for _, entry := range history.entries {
+		if filter(entry.peer) {
+			candidates = append(candidates, entry.peer)
+		}
+	}
+	if len(candidates) == 0 {
+		return Peer{}, false
+	}
+	return candidates[mrand.Intn(len(candidates))], true
+}
+
+// Len returns the number of peers remembered.
+func (history *PeerHistory) Len() int {
+	return len(history.entries)
+}
+
+// Save persists the history, if it has a path. The file is replaced atomically.
+func (history *PeerHistory) Save() error {
+	if history.path == "" {
+		return nil
+	}
+	lines := make([]string, 0, len(history.entries))
+	for _, entry := range history.entries {
+		lines = append(lines, fmt.Sprintf("%s %s %s\n",
+			entry.peer.ID, entry.lastSeen.UTC().Format(time.RFC3339), strings.Join(entry.peer.Addrs, ",")))
+	}
+	sort.Strings(lines)
+	tmp, err := ioutil.TempFile(filepath.Dir(history.path), filepath.Base(history.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(strings.Join(lines, "")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), history.path)
+}
+
+func (history *PeerHistory) String() string {
+	entries := make([]string, 0, len(history.entries))
+	for _, entry := range history.entries {
+		entries = append(entries, entry.String())
+	}
+	sort.Strings(entries)
+	return fmt.Sprintf("{path: %q, capacity: %d, entries: [%s]}", history.path, history.capacity, strings.Join(entries, ", "))
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+	"time"
+)
+
+// peerLeaveContext separates the signatures of leave announcements
+// from any other signature made with the host keys.
+const peerLeaveContext = "gossip peer leave"
+
+// PeerLeave is a self-signed announcement of a peer that it leaves the
+// network, e.g. since it is shutting down. Every peer record of the peer
+// created before the announcement is outdated by it, so that the peer is
+// neither pulled nor sampled again until it comes back with a new record.
+type PeerLeave struct {
+	// PubKey is the canonically encoded public key of the peer, either
+	// RSA or Ed25519, whose hash is the identity of the peer.
+	PubKey []byte
+	// When is the time the peer left the network (UTC).
+	When time.Time
+	// TTL is the remaining number of hops the announcement may travel. It
+	// is not signed, since every peer forwarding the announcement decrements it.
+	TTL uint8
+	// Sig is the signature of the announcement made with the host key of the peer.
+	Sig []byte
+}
+
+// NewPeerLeave is the constructor function for struct type PeerLeave.
+// The announcement is signed with the host key.
+func NewPeerLeave(hostKey crypto.Signer, when time.Time, ttl uint8) (*PeerLeave, error) {
+	pubKey, err := securecomm.MarshalPublicKey(hostKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	leave := &PeerLeave{
+		PubKey: pubKey,
+		When:   when.UTC(),
+		TTL:    ttl,
+	}
+	sig, err := securecomm.Sign(hostKey, crypto.SHA256, leave.signedBytes())
+	if err != nil {
+		return nil, err
+	}
+	leave.Sig = sig
+	return leave, nil
+}
+
+// signedBytes returns the serialization of every field of the announcement except TTL and Sig.
+func (leave *PeerLeave) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(peerLeaveContext)
+	binary.Write(&buf, binary.BigEndian, uint32(len(leave.PubKey)))
+	buf.Write(leave.PubKey)
+	binary.Write(&buf, binary.BigEndian, leave.When.UnixNano())
+	return buf.Bytes()
+}
+
+// ID returns the identity of the peer who left.
+func (leave *PeerLeave) ID() Identity {
+	return sha256.Sum256(leave.PubKey)
+}
+
+// Verify checks that the announcement is made at most maxAge before or after
+// now, allowing for the clock skew between the peers, and that it is signed
+// by the peer who left itself.
+func (leave *PeerLeave) Verify(now time.Time, maxAge time.Duration) error {
+	if now.Sub(leave.When) > maxAge || leave.When.Sub(now) > maxAge {
+		return fmt.Errorf("leave announcement is made at %s", leave.When)
+	}
+	pubKey, err := securecomm.ParsePublicKey(leave.PubKey)
+	if err != nil {
+		return fmt.Errorf("leave announcement has %s", err)
+	}
+	return securecomm.Verify(pubKey, crypto.SHA256, leave.signedBytes(), leave.Sig)
+}
+
+// Outdates returns true iff the peer record of the same peer was created
+// before the announcement. The sequence numbers of the records are their
+// creation times.
+func (leave *PeerLeave) Outdates(record *PeerRecord) bool {
+	return record.Seq <= uint64(leave.When.UnixNano())
+}
+
+func (leave *PeerLeave) String() string {
+	return fmt.Sprintf("{peer: %s, when: %s, ttl: %d}", leave.ID(), leave.When, leave.TTL)
+}
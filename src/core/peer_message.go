@@ -30,3 +30,7 @@ type P2PEndpointClosedMSGPayload struct {
 // P2PEndpointCloseMSGPayload is the payload type of an InternalMessage
 // with type P2PEndpointCloseMSG.
 type P2PEndpointCloseMSGPayload void
+
+// P2PEndpointMalformedMSGPayload is the payload type of an InternalMessage
+// with type P2PEndpointMalformedMSG.
+type P2PEndpointMalformedMSGPayload Peer
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+	"gossip/src/utils"
+	"time"
+)
+
+// peerRecordContext separates the signatures of peer records
+// from any other signature made with the host keys.
+const peerRecordContext = "gossip peer record"
+
+// PeerRecord is a self-signed advertisement of a peer. It binds the
+// addresses of the peer to its identity, so that the peers spread in
+// the membership messages cannot be forged by anyone else. Among the
+// records of the same peer, the one with the highest Seq is the newest.
+type PeerRecord struct {
+	// PubKey is the canonically encoded public key of the peer, either
+	// RSA or Ed25519, whose hash is the identity of the peer.
+	PubKey []byte
+	// Addrs are the advertised P2P listen addresses of the peer.
+	Addrs []string
+	// Seq is the sequence number of the record.
+	Seq uint64
+	// Expires is the time after which the record is not valid anymore (UTC).
+	Expires time.Time
+	// Sig is the signature of the record made with the host key of the peer.
+	Sig []byte
+}
+
+// NewPeerRecord is the constructor function for struct type PeerRecord.
+// The record is signed with the host key.
+func NewPeerRecord(hostKey crypto.Signer, addrs []string, seq uint64, expires time.Time) (*PeerRecord, error) {
+	pubKey, err := securecomm.MarshalPublicKey(hostKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	record := &PeerRecord{
+		PubKey:  pubKey,
+		Addrs:   append([]string{}, addrs...),
+		Seq:     seq,
+		Expires: expires.UTC(),
+	}
+	sig, err := securecomm.Sign(hostKey, crypto.SHA256, record.signedBytes())
+	if err != nil {
+		return nil, err
+	}
+	record.Sig = sig
+	return record, nil
+}
+
+// signedBytes returns the serialization of every field of the record except Sig.
+func (record *PeerRecord) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(peerRecordContext)
+	binary.Write(&buf, binary.BigEndian, uint32(len(record.PubKey)))
+	buf.Write(record.PubKey)
+	binary.Write(&buf, binary.BigEndian, uint32(len(record.Addrs)))
+	for _, addr := range record.Addrs {
+		binary.Write(&buf, binary.BigEndian, uint32(len(addr)))
+		buf.WriteString(addr)
+	}
+	binary.Write(&buf, binary.BigEndian, record.Seq)
+	binary.Write(&buf, binary.BigEndian, record.Expires.UnixNano())
+	return buf.Bytes()
+}
+
+// Peer returns the peer advertised by the record.
+func (record *PeerRecord) Peer() Peer {
+	return Peer{ID: sha256.Sum256(record.PubKey), Addrs: record.Addrs}
+}
+
+// Verify checks that the record is neither expired nor valid for longer than
+// maxLifetime, that its addresses are (ip, port) pairs and that it is signed
+// by the advertised peer itself.
+func (record *PeerRecord) Verify(now time.Time, maxLifetime time.Duration) error {
+	if !now.Before(record.Expires) {
+		return fmt.Errorf("peer record has expired at %s", record.Expires)
+	}
+	if record.Expires.Sub(now) > maxLifetime {
+		return fmt.Errorf("peer record expires too late at %s", record.Expires)
+	}
+	if len(record.Addrs) == 0 || len(record.Addrs) > maxPeerAddrs {
+		return fmt.Errorf("peer record has %d addresses", len(record.Addrs))
+	}
+	for _, addr := range record.Addrs {
+		if _, err := utils.ParseIPPort(addr); err != nil {
+			return err
+		}
+	}
+	pubKey, err := securecomm.ParsePublicKey(record.PubKey)
+	if err != nil {
+		return fmt.Errorf("peer record has %s", err)
+	}
+	return securecomm.Verify(pubKey, crypto.SHA256, record.signedBytes(), record.Sig)
+}
+
+// Advertises returns true iff the record advertises exactly the peer.
+func (record *PeerRecord) Advertises(peer Peer) bool {
+	advertised := record.Peer()
+	if advertised.ID != peer.ID || len(advertised.Addrs) != len(peer.Addrs) {
+		return false
+	}
+	for i, addr := range advertised.Addrs {
+		if addr != peer.Addrs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (record *PeerRecord) String() string {
+	return fmt.Sprintf("{peer: %v, seq: %d, expires: %s}", record.Peer(), record.Seq, record.Expires)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+// newTestHostKey returns a new Ed25519 host key.
+func newTestHostKey(t *testing.T) crypto.Signer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hostKey
+}
+
+// identityOfTest returns the identity of the host key.
+func identityOfTest(t *testing.T, hostKey crypto.Signer) Identity {
+	id, err := IdentityOf(hostKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestPeerRecordVerify(t *testing.T) {
+	hostKey := newTestHostKey(t)
+	now := time.Now()
+	addrs := []string{"127.0.0.1:6001", "[::1]:6001"}
+	record, err := NewPeerRecord(hostKey, addrs, 1, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := record.Verify(now, 2*time.Hour); err != nil {
+		t.Fatalf("Verify of a valid record = %v", err)
+	}
+	if id := record.Peer().ID; id != identityOfTest(t, hostKey) {
+		t.Fatalf("Peer().ID = %s, want the identity of the host key", id)
+	}
+	if !record.Advertises(Peer{ID: record.Peer().ID, Addrs: addrs}) {
+		t.Fatalf("Advertises of the signed peer = false, want true")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(record *PeerRecord)
+		now    time.Time
+	}{
+		{"expired", func(*PeerRecord) {}, now.Add(2 * time.Hour)},
+		{"too long lifetime", func(*PeerRecord) {}, now.Add(-2 * time.Hour)},
+		{"altered address", func(record *PeerRecord) { record.Addrs = []string{"127.0.0.2:6001"} }, now},
+		{"no address", func(record *PeerRecord) { record.Addrs = nil }, now},
+		{"malformed address", func(record *PeerRecord) { record.Addrs = []string{"localhost:6001"} }, now},
+		{"altered sequence number", func(record *PeerRecord) { record.Seq++ }, now},
+		{"altered expiry", func(record *PeerRecord) { record.Expires = record.Expires.Add(-time.Minute) }, now},
+		{"other key", func(record *PeerRecord) {
+			other, _ := NewPeerRecord(newTestHostKey(t), addrs, 1, record.Expires)
+			record.PubKey = other.PubKey
+		}, now},
+		{"malformed key", func(record *PeerRecord) { record.PubKey = record.PubKey[1:] }, now},
+		{"truncated signature", func(record *PeerRecord) { record.Sig = record.Sig[1:] }, now},
+	}
+	for _, test := range tests {
+		modified := *record
+		modified.Addrs = append([]string{}, record.Addrs...)
+		test.modify(&modified)
+		if err := modified.Verify(test.now, 2*time.Hour); err == nil {
+			t.Errorf("Verify of a record with %s = nil, want an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"gossip/src/datastruct/indexedmap"
+)
+
+// PeerSet is a set of peers that is indexable with O(1) complexity. Peers
+// are keyed by their identities, so a peer appears at most once no matter
+// how many addresses it is known by. Adding a peer which is already in the
+// set only updates its address.
+// ALWAYS USE THE CONSTRUCTOR FOR A NEW PEER SET!
+type PeerSet struct {
+	m *indexedmap.IndexedMap
+}
+
+// NewPeerSet is the constructor function for type PeerSet.
+func NewPeerSet() *PeerSet {
+	return &PeerSet{m: indexedmap.New()}
+}
+
+// Add is the method for adding the peer into the set.
+func (peerSet *PeerSet) Add(peer Peer) *PeerSet {
+	peerSet.m.Put(peer.ID, peer)
+	return peerSet
+}
+
+// Remove is the method for removing the peer from the set.
+func (peerSet *PeerSet) Remove(peer Peer) *PeerSet {
+	peerSet.m.Remove(peer.ID)
+	return peerSet
+}
+
+// IsMember is the method for checking if a peer with the
+// same identity is in the set.
+func (peerSet *PeerSet) IsMember(peer Peer) bool {
+	return peerSet.m.IsMember(peer.ID)
+}
+
+// Get returns the peer in the set with the given identity.
+func (peerSet *PeerSet) Get(id Identity) (Peer, bool) {
+	value := peerSet.m.GetValue(id)
+	if value == nil {
+		return Peer{}, false
+	}
+	return value.(Peer), true
+}
+
+// PeerAtIndex is the method for getting the peer at the index.
+func (peerSet *PeerSet) PeerAtIndex(index int) Peer {
+	key, _ := peerSet.m.KeyAtIndex(index)
+	return peerSet.m.GetValue(key).(Peer)
+}
+
+// Len is the method to get the number of peers in the set.
+func (peerSet *PeerSet) Len() int {
+	return peerSet.m.Len()
+}
+
+// Peers returns the peers in the set in the order of their indexes.
+func (peerSet *PeerSet) Peers() []Peer {
+	peers := make([]Peer, 0, peerSet.m.Len())
+	for i := 0; i < peerSet.m.Len(); i++ {
+		peers = append(peers, peerSet.PeerAtIndex(i))
+	}
+	return peers
+}
+
+func (peerSet *PeerSet) String() string {
+	return fmt.Sprint(peerSet.Peers())
+}
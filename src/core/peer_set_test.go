@@ -0,0 +1,63 @@
+package core
+
+import "testing"
+
+func TestPeerSetKeyedByIdentity(t *testing.T) {
+	peerSet := NewPeerSet()
+	a := Peer{ID: Identity{1}, Addrs: []string{"127.0.0.1:6001"}}
+	b := Peer{ID: Identity{2}, Addrs: []string{"127.0.0.1:6002"}}
+	peerSet.Add(a).Add(b)
+	if peerSet.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", peerSet.Len())
+	}
+
+	// Adding a peer with the same identity only updates its addresses.
+	moved := Peer{ID: a.ID, Addrs: []string{"127.0.0.1:7001"}}
+	peerSet.Add(moved)
+	if peerSet.Len() != 2 {
+		t.Fatalf("Len() = %d after re-adding a peer, want 2", peerSet.Len())
+	}
+	got, ok := peerSet.Get(a.ID)
+	if !ok || got.Addrs[0] != "127.0.0.1:7001" {
+		t.Fatalf("Get(%s) = %v, %t, want the updated address", a.ID, got, ok)
+	}
+	if !peerSet.IsMember(Peer{ID: a.ID}) {
+		t.Fatalf("IsMember of a peer without addresses = false, want true")
+	}
+
+	peerSet.Remove(Peer{ID: a.ID})
+	if peerSet.IsMember(a) {
+		t.Fatalf("IsMember(%v) = true after Remove", a)
+	}
+	if _, ok := peerSet.Get(a.ID); ok {
+		t.Fatalf("Get(%s) found a removed peer", a.ID)
+	}
+	if peers := peerSet.Peers(); len(peers) != 1 || peers[0].ID != b.ID {
+		t.Fatalf("Peers() = %v, want [%v]", peers, b)
+	}
+}
+
+func TestPeerSetPeersInIndexOrder(t *testing.T) {
+	peerSet := NewPeerSet()
+	for i := 0; i < 10; i++ {
+		peerSet.Add(Peer{ID: Identity{byte(i)}})
+	}
+	peerSet.Remove(Peer{ID: Identity{3}})
+	peers := peerSet.Peers()
+	if len(peers) != 9 {
+		t.Fatalf("len(Peers()) = %d, want 9", len(peers))
+	}
+	seen := map[Identity]bool{}
+	for i, peer := range peers {
+		if peer.ID != peerSet.PeerAtIndex(i).ID {
+			t.Fatalf("Peers()[%d] = %v, PeerAtIndex(%d) = %v", i, peer, i, peerSet.PeerAtIndex(i))
+		}
+		if seen[peer.ID] {
+			t.Fatalf("Peers() has %v twice", peer)
+		}
+		seen[peer.ID] = true
+	}
+	if seen[Identity{3}] {
+		t.Fatalf("Peers() has the removed peer")
+	}
+}
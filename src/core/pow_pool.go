@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// powJob is a Proof of Work job executed by a PoWWorkerPool. It returns the
+// internal message to be delivered as the result, or nil if there is none.
+type powJob func(ctx context.Context) *InternalMessage
+
+// PoWWorkerPool is a bounded pool of goroutines for generating and verifying
+// the Proof of Work of membership push requests, so that the scrypt hashes
+// don't block the Membership controller goroutine. The results are delivered
+// as internal messages to the 'results' queue.
+type PoWWorkerPool struct {
+	// workers is the number of worker goroutines.
+	workers int
+	// jobs is the bounded queue of jobs waiting for a worker.
+	jobs chan powJob
+	// results is the queue to deliver the results of the jobs.
+	results chan InternalMessage
+	// ctx is cancelled when the pool is closed, which cancels every job.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// A synchronozation variable to execute the Close method only once.
+	closeOnce sync.Once
+}
+
+// NewPoWWorkerPool is the constructor function for struct type PoWWorkerPool.
+func NewPoWWorkerPool(workers, queueSize int, results chan InternalMessage) *PoWWorkerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PoWWorkerPool{
+		workers: workers,
+		jobs:    make(chan powJob, queueSize),
+		results: results,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (pool *PoWWorkerPool) workerRoutine() {
+	for {
+		select {
+		case <-pool.ctx.Done():
+			return
+		case job := <-pool.jobs:
+			im := job(pool.ctx)
+			if im == nil {
+				continue
+			}
+			select {
+			case pool.results <- *im:
+			case <-pool.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Run runs the worker goroutines of the pool.
+func (pool *PoWWorkerPool) Run() {
+	for i := 0; i < pool.workers; i++ {
+		go pool.workerRoutine()
+	}
+}
+
+// Context returns the context that is cancelled when the pool is closed.
+// Contexts of cancellable jobs must be derived from it.
+func (pool *PoWWorkerPool) Context() context.Context {
+	return pool.ctx
+}
+
+// TrySubmit is the method for queuing the job without blocking. The job is
+// accepted only if at least 'reserve' free slots remain in the queue after
+// it, so that less important jobs can leave room for the more important
+// ones. Returns false if the job is rejected.
+func (pool *PoWWorkerPool) TrySubmit(job powJob, reserve int) bool {
+	if pool.ctx.Err() != nil || len(pool.jobs)+reserve >= cap(pool.jobs) {
+		return false
+	}
+	select {
+	case pool.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close method cancels every job and stops the worker goroutines without blocking.
+func (pool *PoWWorkerPool) Close() error {
+	pool.closeOnce.Do(pool.cancel)
+	return nil
+}
+
+func (pool *PoWWorkerPool) String() string {
+	return fmt.Sprintf("{workers: %d, queued: %d/%d}", pool.workers, len(pool.jobs), cap(pool.jobs))
+}
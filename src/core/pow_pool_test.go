@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoWWorkerPoolDeliversResults(t *testing.T) {
+	results := make(chan InternalMessage, 10)
+	pool := NewPoWWorkerPool(2, 10, results)
+	pool.Run()
+	defer pool.Close()
+
+	for i := 0; i < 5; i++ {
+		i := i
+		job := func(ctx context.Context) *InternalMessage {
+			if i%2 == 1 {
+				// Jobs without a result deliver nothing.
+				return nil
+			}
+			return &InternalMessage{Type: MembershipPushTokenMSG, Payload: i}
+		}
+		if !pool.TrySubmit(job, 0) {
+			t.Fatalf("TrySubmit of job %d = false, want true", i)
+		}
+	}
+	got := map[int]bool{}
+	for len(got) < 3 {
+		select {
+		case im := <-results:
+			got[im.Payload.(int)] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got the results %v, want the ones of the jobs 0, 2 and 4", got)
+		}
+	}
+	if !got[0] || !got[2] || !got[4] {
+		t.Fatalf("got the results %v, want the ones of the jobs 0, 2 and 4", got)
+	}
+	select {
+	case im := <-results:
+		t.Fatalf("got an unexpected result %v", im)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPoWWorkerPoolTrySubmitReserve(t *testing.T) {
+	// Without running workers the queue only fills up.
+	pool := NewPoWWorkerPool(1, 4, make(chan InternalMessage))
+	defer pool.Close()
+	job := func(ctx context.Context) *InternalMessage { return nil }
+
+	// Leave 2 free slots for the more important jobs.
+	for i := 0; i < 2; i++ {
+		if !pool.TrySubmit(job, 2) {
+			t.Fatalf("TrySubmit %d with a reserve of 2 = false, want true", i)
+		}
+	}
+	if pool.TrySubmit(job, 2) {
+		t.Fatalf("TrySubmit into the reserve = true, want false")
+	}
+	for i := 0; i < 2; i++ {
+		if !pool.TrySubmit(job, 0) {
+			t.Fatalf("TrySubmit %d without a reserve = false, want true", i)
+		}
+	}
+	if pool.TrySubmit(job, 0) {
+		t.Fatalf("TrySubmit into a full queue = true, want false")
+	}
+}
+
+func TestPoWWorkerPoolCloseCancelsJobs(t *testing.T) {
+	results := make(chan InternalMessage)
+	pool := NewPoWWorkerPool(1, 1, results)
+	pool.Run()
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	job := func(ctx context.Context) *InternalMessage {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	}
+	if !pool.TrySubmit(job, 0) {
+		t.Fatalf("TrySubmit = false, want true")
+	}
+	<-started
+	pool.Close()
+	pool.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not cancel the running job")
+	}
+	if pool.Context().Err() == nil {
+		t.Fatalf("Context is not cancelled after Close")
+	}
+	if pool.TrySubmit(func(ctx context.Context) *InternalMessage { return nil }, 0) {
+		t.Fatalf("TrySubmit after Close = true, want false")
+	}
+}
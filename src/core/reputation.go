@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// PeerMisbehaviour is the type for describing how a remote peer
+// violated the protocol.
+type PeerMisbehaviour uint8
+
+const (
+	// InvalidGossipItem means that the peer delivered a gossip item
+	// that an API client marked as invalid.
+	InvalidGossipItem PeerMisbehaviour = iota
+	// MalformedMessage means that the peer sent a message which
+	// could not be interpreted.
+	MalformedMessage
+	// UnsolicitedPullReply means that the peer sent a pull reply
+	// without being asked for it.
+	UnsolicitedPullReply
+	// InvalidTombstone means that the peer sent a tombstone which is
+	// either not properly signed or not issued by a trusted identity.
+	InvalidTombstone
+	// InvalidPeerRecord means that the peer spread a peer record which
+	// is either expired or not properly signed.
+	InvalidPeerRecord
+	// InvalidPeerLeave means that the peer forwarded a leave announcement
+	// which is either too old or not properly signed.
+	InvalidPeerLeave
+	// InvalidKVEntry means that the peer sent a key-value entry which is
+	// either not properly signed or not written by a trusted identity.
+	InvalidKVEntry
+	// InvalidDirectMessage means that the peer forwarded a directed
+	// message which is either not properly signed or not sent by a
+	// trusted identity.
+	InvalidDirectMessage
+)
+
+func (m PeerMisbehaviour) String() string {
+	switch m {
+	case InvalidGossipItem:
+		return "InvalidGossipItem"
+	case MalformedMessage:
+		return "MalformedMessage"
+	case UnsolicitedPullReply:
+		return "UnsolicitedPullReply"
+	case InvalidTombstone:
+		return "InvalidTombstone"
+	case InvalidPeerRecord:
+		return "InvalidPeerRecord"
+	case InvalidPeerLeave:
+		return "InvalidPeerLeave"
+	case InvalidKVEntry:
+		return "InvalidKVEntry"
+	case InvalidDirectMessage:
+		return "InvalidDirectMessage"
+	}
+	return fmt.Sprintf("PeerMisbehaviour(%d)", uint8(m))
+}
+
+// PeerReputationConfig holds the parameters for scoring the behaviour of peers.
+type PeerReputationConfig struct {
+	// initialScore is the score of a peer that has never misbehaved.
+	// It is also the maximum score a peer can have.
+	initialScore float64
+	// banThreshold is the score below which a peer is banned.
+	banThreshold float64
+	// recovery is the amount of score a peer regains after each membership round.
+	recovery float64
+	// banDuration is the amount of time a peer stays banned.
+	banDuration time.Duration
+	// penalties are the amounts of score lost for each kind of misbehaviour.
+	penalties map[PeerMisbehaviour]float64
+}
+
+// PeerReputation holds the current score of a peer and the time
+// until which the peer is banned, if it is banned at all.
+type PeerReputation struct {
+	score       float64
+	bannedUntil time.Time
+}
+
+// PeerReputationList keeps the reputation of every peer that has misbehaved
+// at least once. Peers which are not in the list have the initial score.
+type PeerReputationList struct {
+	config PeerReputationConfig
+	// m is keyed by the identities of the peers.
+	m map[Identity]*PeerReputation
+}
+
+// NewPeerReputationList is the constructor function for struct type PeerReputationList.
+func NewPeerReputationList(config PeerReputationConfig) *PeerReputationList {
+	return &PeerReputationList{config: config, m: map[Identity]*PeerReputation{}}
+}
+
+// Score returns the current reputation score of the peer.
+func (list *PeerReputationList) Score(peer Peer) float64 {
+	if rep, isMember := list.m[peer.ID]; isMember {
+		return rep.score
+	}
+	return list.config.initialScore
+}
+
+// IsBanned returns true iff the peer is banned at the given time.
+func (list *PeerReputationList) IsBanned(peer Peer, now time.Time) bool {
+	if rep, isMember := list.m[peer.ID]; isMember {
+		return now.Before(rep.bannedUntil)
+	}
+	return false
+}
+
+// Penalize reduces the score of the peer according to the misbehaviour. If the
+// peer falls below the ban threshold, then it is banned and true is returned
+// together with the time the ban expires.
+func (list *PeerReputationList) Penalize(
+	peer Peer, reason PeerMisbehaviour, now time.Time,
+) (banned bool, until time.Time) {
+	rep, isMember := list.m[peer.ID]
+	if !isMember {
+		rep = &PeerReputation{score: list.config.initialScore}
+		list.m[peer.ID] = rep
+	}
+	// There is no point in penalizing a peer which is already banned.
+	if now.Before(rep.bannedUntil) {
+		return false, rep.bannedUntil
+	}
+	rep.score -= list.config.penalties[reason]
+	if rep.score < list.config.banThreshold {
+		rep.bannedUntil = now.Add(list.config.banDuration)
+		// Give the peer a fresh start after the ban expires.
+		rep.score = list.config.initialScore
+		return true, rep.bannedUntil
+	}
+	return false, rep.bannedUntil
+}
+
+// Recover is the method to be called once per membership round. It restores
+// some of the score of every peer and forgets about peers that have fully
+// recovered and are not banned anymore.
+func (list *PeerReputationList) Recover(now time.Time) {
+	for id, rep := range list.m {
+		rep.score += list.config.recovery
+		if rep.score >= list.config.initialScore {
+			rep.score = list.config.initialScore
+			if !now.Before(rep.bannedUntil) {
+				delete(list.m, id)
+			}
+		}
+	}
+}
+
+func (list *PeerReputationList) String() string {
+	return fmt.Sprint(list.m)
+}
+
+func (rep *PeerReputation) String() string {
+	return fmt.Sprintf("{score: %f, bannedUntil: %s}", rep.score, rep.bannedUntil)
+}
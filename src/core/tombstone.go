@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"gossip/src/crypto/securecomm"
+	"time"
+)
+
+// GossipItemID is the SHA-256 hash of the big endian data type of a gossip
+// item followed by its data. It identifies a gossip item without carrying
+// its data around.
+type GossipItemID [sha256.Size]byte
+
+// ID returns the identifier of the gossip item.
+func (item *GossipItem) ID() GossipItemID {
+	dataTypeBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(dataTypeBytes, uint16(item.DataType))
+	return sha256.Sum256(append(dataTypeBytes, []byte(item.Data)...))
+}
+
+func (id GossipItemID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// GossipTombstone is the signed statement that a gossip item is retracted
+// and must not be gossiped any further. A tombstone is valid for
+// tombstoneRetention after it was issued.
+//
+// Only the peer whose API clients announced an item issues a tombstone for
+// it, and that peer rejects any tombstone of the item issued by another peer.
+// Gossip items don't carry their announcer, so the other peers can only check
+// that the issuer is trusted: a trusted peer breaking this policy can retract
+// the items announced by others everywhere except at their announcer.
+type GossipTombstone struct {
+	// ItemID is the identifier of the retracted gossip item.
+	ItemID GossipItemID
+	// DataType is the data type of the retracted gossip item, so that
+	// only the API clients interested in it are notified.
+	DataType GossipItemDataType
+	// Issuer is the RSA public key of the peer who issued the tombstone,
+	// which is zero if the issuer has an Ed25519 identity.
+	Issuer rsa.PublicKey
+	// Time is the time the tombstone was issued.
+	Time time.Time
+	// Signature is the signature of the issuer over the fields above.
+	Signature []byte
+	// Ed25519Issuer is the Ed25519 public key of the peer who issued the
+	// tombstone, which is empty if the issuer has an RSA identity.
+	Ed25519Issuer ed25519.PublicKey
+}
+
+// NewGossipTombstone is the constructor function for a GossipTombstone
+// retracting the given item, signed with the given host key.
+func NewGossipTombstone(item *GossipItem, hostKey crypto.Signer) (*GossipTombstone, error) {
+	tombstone := &GossipTombstone{
+		ItemID:   item.ID(),
+		DataType: item.DataType,
+		Time:     time.Now().UTC(),
+	}
+	switch pubKey := hostKey.Public().(type) {
+	case *rsa.PublicKey:
+		tombstone.Issuer = *pubKey
+	case ed25519.PublicKey:
+		tombstone.Ed25519Issuer = pubKey
+	default:
+		return nil, fmt.Errorf("unsupported host key type %T", pubKey)
+	}
+	signature, err := securecomm.Sign(hostKey, crypto.SHA3_256, tombstone.signedBytes())
+	if err != nil {
+		return nil, err
+	}
+	tombstone.Signature = signature
+	return tombstone, nil
+}
+
+// issuerKey returns the public key of the issuer, either RSA or Ed25519.
+func (tombstone *GossipTombstone) issuerKey() crypto.PublicKey {
+	if len(tombstone.Ed25519Issuer) != 0 {
+		return tombstone.Ed25519Issuer
+	}
+	return &tombstone.Issuer
+}
+
+// signedBytes concatenates all the fields of the tombstone covered by the signature.
+func (tombstone *GossipTombstone) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(tombstone.ItemID[:])
+	binary.Write(&buf, binary.BigEndian, uint16(tombstone.DataType))
+	issuer, _ := securecomm.MarshalPublicKey(tombstone.issuerKey())
+	buf.Write(issuer)
+	binary.Write(&buf, binary.BigEndian, tombstone.Time.UnixNano())
+	return buf.Bytes()
+}
+
+// IssuerID returns the identity of the peer who issued the tombstone.
+func (tombstone *GossipTombstone) IssuerID() (Identity, error) {
+	return securecomm.IdentityOf(tombstone.issuerKey())
+}
+
+// Verify checks that the tombstone is issued at most maxAge before and at
+// most maxSkew after now, allowing for the clock skew between the peers,
+// that it is signed by its issuer and that the issuer is one of the
+// trusted identities, which is not banned.
+func (tombstone *GossipTombstone) Verify(config *securecomm.Config, now time.Time, maxAge, maxSkew time.Duration) error {
+	if now.Sub(tombstone.Time) > maxAge || tombstone.Time.Sub(now) > maxSkew {
+		return fmt.Errorf("tombstone is issued at %s", tombstone.Time)
+	}
+	if (tombstone.Issuer.N == nil) == (len(tombstone.Ed25519Issuer) == 0) {
+		return fmt.Errorf("tombstone has not exactly one issuer")
+	}
+	if err := securecomm.Verify(tombstone.issuerKey(), crypto.SHA3_256, tombstone.signedBytes(), tombstone.Signature); err != nil {
+		return err
+	}
+	return securecomm.CheckIdentity(tombstone.issuerKey(), config)
+}
+
+func (tombstone *GossipTombstone) String() string {
+	return fmt.Sprintf("{ItemID: %s, DataType: %d, Time: %s}", tombstone.ItemID, tombstone.DataType, tombstone.Time)
+}
+
+// GossipTombstoneInfoGossiper holds a tombstone known to the Gossiper, the
+// number of gossip rounds it will still be spread for and the time until
+// which the retracted item is suppressed. This struct is meant to be used as
+// a value in a map[GossipItemID]*GossipTombstoneInfoGossiper by the Gossiper.
+type GossipTombstoneInfoGossiper struct {
+	tombstone *GossipTombstone
+	ttl       uint8
+	expires   time.Time
+}
+
+func (info *GossipTombstoneInfoGossiper) String() string {
+	return fmt.Sprintf("{tombstone: %s, ttl: %d, expires: %s}", info.tombstone, info.ttl, info.expires)
+}
@@ -0,0 +1,234 @@
+package securecomm
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// banEntry is an entry of a BanList. It bans either an identity or a
+// network of IP addresses. A single IP address is a network of one address.
+type banEntry struct {
+	// ipNet is the banned network. It is nil if an identity is banned.
+	ipNet *net.IPNet
+	// expires is the time the ban expires. It is zero if the ban never expires.
+	expires time.Time
+	// configured is true iff the entry is given by the configuration,
+	// which is why it is not persisted.
+	configured bool
+}
+
+// isExpired returns true iff the ban has expired at the given time.
+func (entry *banEntry) isExpired(now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+// BanList is the list of banned identities, IP addresses and CIDR networks
+// with their expiry times. Connections from and to the banned hosts are
+// refused. It is safe for concurrent use. If it has a path, then every
+// change is persisted into that file, one entry per line in the form
+// "<entry> <expiry in RFC 3339 or 'never'>".
+type BanList struct {
+	mu sync.RWMutex
+	// path is the file the list is persisted in. It is empty if not persisted.
+	path string
+	// entries is the map of canonical entries to the parsed entries.
+	entries map[string]*banEntry
+}
+
+// parseBanEntry parses an entry of a ban list, which is either a hex encoded
+// identity, an IP address or a CIDR network. Returns the canonical form of it.
+func parseBanEntry(s string) (string, *banEntry, error) {
+	s = strings.TrimSpace(s)
+	if b, err := hex.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return hex.EncodeToString(b), &banEntry{}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		ipNet := &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		return ip.String(), &banEntry{ipNet: ipNet}, nil
+	}
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet.String(), &banEntry{ipNet: ipNet}, nil
+	}
+	return "", nil, fmt.Errorf("securecomm: ban entry %q is neither an identity, an IP address nor a CIDR network", s)
+}
+
+// NewBanList is the constructor function for BanList. If path is not empty,
+// then the entries persisted there are loaded and the list is persisted there.
+// The configured entries are banned permanently but never persisted.
+func NewBanList(path string, configured []string) (*BanList, error) {
+	banList := &BanList{path: path, entries: map[string]*banEntry{}}
+	for _, s := range configured {
+		key, entry, err := parseBanEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		entry.configured = true
+		banList.entries[key] = entry
+	}
+	if path == "" {
+		return banList, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return banList, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	now := time.Now()
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("securecomm: malformed ban list line %d in %q", lineNo, path)
+		}
+		key, entry, err := parseBanEntry(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		if fields[1] != "never" {
+			if entry.expires, err = time.Parse(time.RFC3339, fields[1]); err != nil {
+				return nil, fmt.Errorf("securecomm: malformed ban expiry on line %d in %q: %v", lineNo, path, err)
+			}
+		}
+		if _, isMember := banList.entries[key]; !isMember && !entry.isExpired(now) {
+			banList.entries[key] = entry
+		}
+	}
+	return banList, scanner.Err()
+}
+
+// Add bans the identity, IP address or CIDR network until the expiry time,
+// which is zero if the ban never expires. An existing ban of the same entry
+// is replaced.
+func (banList *BanList) Add(s string, expires time.Time) error {
+	key, entry, err := parseBanEntry(s)
+	if err != nil {
+		return err
+	}
+	entry.expires = expires
+	banList.mu.Lock()
+	defer banList.mu.Unlock()
+	banList.entries[key] = entry
+	return banList.save()
+}
+
+// Remove lifts the ban of the identity, IP address or CIDR network.
+// Returns false if the entry was not banned.
+func (banList *BanList) Remove(s string) (bool, error) {
+	key, _, err := parseBanEntry(s)
+	if err != nil {
+		return false, err
+	}
+	banList.mu.Lock()
+	defer banList.mu.Unlock()
+	if _, isMember := banList.entries[key]; !isMember {
+		return false, nil
+	}
+	delete(banList.entries, key)
+	return true, banList.save()
+}
+
+// save persists the unexpired entries, if the list has a path. The file is
+// replaced atomically. The caller has to hold the write lock.
+func (banList *BanList) save() error {
+	if banList.path == "" {
+		return nil
+	}
+	now := time.Now()
+	var lines []string
+	for key, entry := range banList.entries {
+		if entry.isExpired(now) {
+			delete(banList.entries, key)
+			continue
+		}
+		if entry.configured {
+			continue
+		}
+		expires := "never"
+		if !entry.expires.IsZero() {
+			expires = entry.expires.UTC().Format(time.RFC3339)
+		}
+		lines = append(lines, key+" "+expires+"\n")
+	}
+	sort.Strings(lines)
+	tmp, err := ioutil.TempFile(filepath.Dir(banList.path), filepath.Base(banList.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(strings.Join(lines, "")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), banList.path)
+}
+
+// IsIdentityBanned returns true iff the identity, which is the SHA-256 hash
+// of the PKCS #1 encoded RSA public key, is currently banned.
+func (banList *BanList) IsIdentityBanned(identity [sha256.Size]byte) bool {
+	if banList == nil {
+		return false
+	}
+	now := time.Now()
+	banList.mu.RLock()
+	defer banList.mu.RUnlock()
+	entry, isMember := banList.entries[hex.EncodeToString(identity[:])]
+	return isMember && !entry.isExpired(now)
+}
+
+// IsIPBanned returns true iff the IP address is currently banned,
+// either by itself or by a CIDR network containing it.
+func (banList *BanList) IsIPBanned(ip net.IP) bool {
+	if banList == nil || ip == nil {
+		return false
+	}
+	now := time.Now()
+	banList.mu.RLock()
+	defer banList.mu.RUnlock()
+	for _, entry := range banList.entries {
+		if entry.ipNet != nil && entry.ipNet.Contains(ip) && !entry.isExpired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (banList *BanList) String() string {
+	if banList == nil {
+		return "<nil>"
+	}
+	banList.mu.RLock()
+	defer banList.mu.RUnlock()
+	entries := make([]string, 0, len(banList.entries))
+	for key, entry := range banList.entries {
+		if entry.expires.IsZero() {
+			entries = append(entries, key)
+		} else {
+			entries = append(entries, fmt.Sprintf("%s until %s", key, entry.expires.UTC().Format(time.RFC3339)))
+		}
+	}
+	sort.Strings(entries)
+	return fmt.Sprintf("{path: %q, entries: %q}", banList.path, entries)
+}
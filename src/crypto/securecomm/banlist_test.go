@@ -0,0 +1,132 @@
+package securecomm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanListEntries(t *testing.T) {
+	id := sha256.Sum256([]byte("banned"))
+	banList, err := NewBanList("", []string{hex.EncodeToString(id[:]), "192.0.2.1", "198.51.100.0/24", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !banList.IsIdentityBanned(id) {
+		t.Errorf("IsIdentityBanned of a banned identity = false, want true")
+	}
+	if banList.IsIdentityBanned(sha256.Sum256([]byte("other"))) {
+		t.Errorf("IsIdentityBanned of another identity = true, want false")
+	}
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"198.51.100.77", true},
+		{"198.51.101.1", false},
+		{"::ffff:192.0.2.1", true},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, test := range tests {
+		if banned := banList.IsIPBanned(net.ParseIP(test.ip)); banned != test.banned {
+			t.Errorf("IsIPBanned(%s) = %t, want %t", test.ip, banned, test.banned)
+		}
+	}
+	if _, err := NewBanList("", []string{"not an entry"}); err == nil {
+		t.Errorf("NewBanList with a malformed entry = nil error, want an error")
+	}
+
+	var nilBanList *BanList
+	if nilBanList.IsIdentityBanned(id) || nilBanList.IsIPBanned(net.ParseIP("192.0.2.1")) {
+		t.Errorf("a nil BanList bans, want none banned")
+	}
+}
+
+func TestBanListExpiry(t *testing.T) {
+	banList, err := NewBanList("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := banList.Add("192.0.2.1", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := banList.Add("192.0.2.2", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if banList.IsIPBanned(net.ParseIP("192.0.2.1")) {
+		t.Errorf("IsIPBanned of an expired ban = true, want false")
+	}
+	if !banList.IsIPBanned(net.ParseIP("192.0.2.2")) {
+		t.Errorf("IsIPBanned of an unexpired ban = false, want true")
+	}
+	if removed, err := banList.Remove("192.0.2.2"); !removed || err != nil {
+		t.Errorf("Remove of a ban = %t, %v, want true, nil", removed, err)
+	}
+	if removed, err := banList.Remove("192.0.2.2"); removed || err != nil {
+		t.Errorf("Remove of a lifted ban = %t, %v, want false, nil", removed, err)
+	}
+	if banList.IsIPBanned(net.ParseIP("192.0.2.2")) {
+		t.Errorf("IsIPBanned of a lifted ban = true, want false")
+	}
+}
+
+func TestBanListPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "banlist")
+
+	banList, err := NewBanList(path, []string{"203.0.113.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := banList.Add("192.0.2.1", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := banList.Add("192.0.2.2", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := banList.Add("192.0.2.3", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := banList.Remove("192.0.2.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The configured entries are not persisted.
+	reloaded, err := NewBanList(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.2", true},
+		{"192.0.2.3", false},
+		{"203.0.113.1", false},
+	}
+	for _, test := range tests {
+		if banned := reloaded.IsIPBanned(net.ParseIP(test.ip)); banned != test.banned {
+			t.Errorf("IsIPBanned(%s) after reloading = %t, want %t", test.ip, banned, test.banned)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("192.0.2.1 tomorrow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBanList(path, nil); err == nil {
+		t.Errorf("NewBanList of a malformed expiry = nil error, want an error")
+	}
+}
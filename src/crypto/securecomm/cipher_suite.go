@@ -0,0 +1,229 @@
+package securecomm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// CipherSuite is the key exchange of a handshake, negotiated by the peers.
+// Every peer signs its handshake with its own host key, either RSA-PSS or
+// Ed25519, so that the peers with RSA identities can still be connected to
+// during a migration to Ed25519 identities.
+type CipherSuite uint8
+
+// Cipher suites, the zero value being the one of the original protocol, so
+// that the handshakes of the peers not negotiating a suite are understood.
+const (
+	// CipherSuiteDHRSA is the 2048-bit finite-field DH key exchange
+	// intended for RSA identities.
+	CipherSuiteDHRSA CipherSuite = iota
+	// CipherSuiteX25519Ed25519 is the X25519 key exchange
+	// intended for Ed25519 identities.
+	CipherSuiteX25519Ed25519
+)
+
+// DefaultCipherSuites are the cipher suites offered and accepted
+// in the order of preference, unless configured otherwise. The peers
+// of the original protocol are only connected to if CipherSuiteDHRSA
+// is configured explicitly.
+var DefaultCipherSuites = []CipherSuite{CipherSuiteX25519Ed25519}
+
+var cipherSuiteNames = map[CipherSuite]string{
+	CipherSuiteDHRSA:         "dh-rsa",
+	CipherSuiteX25519Ed25519: "x25519-ed25519",
+}
+
+func (suite CipherSuite) String() string {
+	if name, ok := cipherSuiteNames[suite]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown cipher suite %d", uint8(suite))
+}
+
+// keyShareSize returns the size of the public key of the key exchange.
+func (suite CipherSuite) keyShareSize() int {
+	switch suite {
+	case CipherSuiteDHRSA:
+		return 256
+	case CipherSuiteX25519Ed25519:
+		return curve25519.PointSize
+	}
+	return 0
+}
+
+// ParseCipherSuites parses a comma separated list of cipher suite
+// names, e.g. "x25519-ed25519, dh-rsa", in the order of preference.
+func ParseCipherSuites(s string) ([]CipherSuite, error) {
+	var suites []CipherSuite
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		suite, found := CipherSuite(0), false
+		for candidate, candidateName := range cipherSuiteNames {
+			if candidateName == name {
+				suite, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		for _, parsed := range suites {
+			if parsed == suite {
+				return nil, fmt.Errorf("duplicate cipher suite %q", name)
+			}
+		}
+		suites = append(suites, suite)
+	}
+	if len(suites) == 0 {
+		return nil, fmt.Errorf("no cipher suite in %q", s)
+	}
+	return suites, nil
+}
+
+// keyExchange is the ephemeral key pair of this host for a cipher suite.
+type keyExchange struct {
+	suite CipherSuite
+	// km is the finite-field DH key pair of CipherSuiteDHRSA.
+	km *KeyManagement
+	// x25519Priv is the X25519 private key of CipherSuiteX25519Ed25519.
+	x25519Priv []byte
+	// public is the public key sent in the handshake.
+	public []byte
+}
+
+// newKeyExchange is the constructor function for keyExchange,
+// which generates a fresh key pair of the cipher suite.
+func newKeyExchange(suite CipherSuite) (*keyExchange, error) {
+	kx := &keyExchange{suite: suite}
+	switch suite {
+	case CipherSuiteDHRSA:
+		kx.km = emptyKM()
+		kx.km.generateOwnDHKeys()
+		kx.public = kx.km.dhPub
+	case CipherSuiteX25519Ed25519:
+		kx.x25519Priv = make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(kx.x25519Priv); err != nil {
+			return nil, err
+		}
+		public, err := curve25519.X25519(kx.x25519Priv, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+		kx.public = public
+	default:
+		return nil, fmt.Errorf("securecomm: %s", suite)
+	}
+	return kx, nil
+}
+
+// sharedSecret combines the public key of the remote peer and the
+// private key of this host to compute the shared secret.
+func (kx *keyExchange) sharedSecret(remotePublic []byte) ([]byte, error) {
+	if kx.suite == CipherSuiteX25519Ed25519 {
+		// X25519 rejects the low order points, whose shared secret is all zeros.
+		return curve25519.X25519(kx.x25519Priv, remotePublic)
+	}
+	return kx.km.computeFinalKey(remotePublic)
+}
+
+// MarshalPublicKey returns the canonical encoding of a host public key,
+// whose SHA-256 hash is the identity of the host: the PKCS #1 encoding
+// of an RSA key or the 32 bytes of an Ed25519 key.
+func MarshalPublicKey(pubKey crypto.PublicKey) ([]byte, error) {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		return x509.MarshalPKCS1PublicKey(pubKey), nil
+	case ed25519.PublicKey:
+		return append([]byte{}, pubKey...), nil
+	}
+	return nil, fmt.Errorf("securecomm: unsupported host key type %T", pubKey)
+}
+
+// ParsePublicKey parses the canonical encoding of a host public key, which
+// is either a 4096-bit RSA key, like the host keys accepted by the
+// handshakes, or an Ed25519 key.
+func ParsePublicKey(encoded []byte) (crypto.PublicKey, error) {
+	if len(encoded) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(append([]byte{}, encoded...)), nil
+	}
+	pubKey, err := x509.ParsePKCS1PublicKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("an invalid key: %s", err)
+	}
+	// The identity is the hash of the canonical encoding of the key.
+	if !bytes.Equal(x509.MarshalPKCS1PublicKey(pubKey), encoded) {
+		return nil, fmt.Errorf("a non-canonical key encoding")
+	}
+	if pubKey.Size() != 512 {
+		return nil, fmt.Errorf("a %d-bit key", 8*pubKey.Size())
+	}
+	return pubKey, nil
+}
+
+// IdentityOf returns the identity of the host with the given public key,
+// the SHA-256 hash of its canonical encoding.
+func IdentityOf(pubKey crypto.PublicKey) ([sha256.Size]byte, error) {
+	encoded, err := MarshalPublicKey(pubKey)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(encoded), nil
+}
+
+// Sign signs the message with the host key. An RSA key makes an RSA-PSS
+// signature of the hash of the message, while an Ed25519 key signs the
+// message itself, so that the hash is only used for RSA keys.
+func Sign(hostKey crypto.Signer, hash crypto.Hash, message []byte) ([]byte, error) {
+	switch hostKey.Public().(type) {
+	case *rsa.PublicKey:
+		h := hash.New()
+		h.Write(message)
+		return hostKey.Sign(rand.Reader, h.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: hash})
+	case ed25519.PublicKey:
+		return hostKey.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	return nil, fmt.Errorf("securecomm: unsupported host key type %T", hostKey.Public())
+}
+
+// Verify checks the signature of the message made by Sign with the
+// host key of the given public key and the same hash.
+func Verify(pubKey crypto.PublicKey, hash crypto.Hash, message, sig []byte) error {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		h := hash.New()
+		h.Write(message)
+		return rsa.VerifyPSS(pubKey, hash, h.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	case ed25519.PublicKey:
+		if len(pubKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("securecomm: invalid Ed25519 key size %d", len(pubKey))
+		}
+		if !ed25519.Verify(pubKey, message, sig) {
+			return fmt.Errorf("securecomm: invalid Ed25519 signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("securecomm: unsupported host key type %T", pubKey)
+}
+
+// signatureSize returns the size of the signatures made with the host key.
+func signatureSize(pubKey crypto.PublicKey) int {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		return pubKey.Size()
+	case ed25519.PublicKey:
+		return ed25519.SignatureSize
+	}
+	return 0
+}
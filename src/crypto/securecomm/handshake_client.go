@@ -1,15 +1,10 @@
 package securecomm
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"fmt"
 	"gossip/src/utils"
 	"sync/atomic"
 	"time"
-
-	"golang.org/x/crypto/sha3"
 )
 
 func (c *SecureConn) clientHandshake() (err error) {
@@ -17,11 +12,16 @@ func (c *SecureConn) clientHandshake() (err error) {
 		return fmt.Errorf("Config is nil")
 	}
 
+	// Offer every cipher suite of the config, each with a fresh key pair
 	hs := &clientHandshakeState{
-		c:  c,
-		km: emptyKM(),
+		c:            c,
+		keyExchanges: map[CipherSuite]*keyExchange{},
+	}
+	for _, suite := range c.config.cipherSuites() {
+		if hs.keyExchanges[suite], err = newKeyExchange(suite); err != nil {
+			return err
+		}
 	}
-	hs.km.generateOwnDHKeys()
 
 	if err := hs.handshake(); err != nil {
 		return err
@@ -31,8 +31,11 @@ func (c *SecureConn) clientHandshake() (err error) {
 }
 
 type clientHandshakeState struct {
-	c            *SecureConn
-	km           *KeyManagement
+	c *SecureConn
+	// keyExchanges are the key pairs of the cipher suites offered.
+	keyExchanges map[CipherSuite]*keyExchange
+	// suite is the cipher suite chosen by the server.
+	suite        CipherSuite
 	mClient      *Handshake
 	mServer      *Handshake
 	masterSecret []byte
@@ -45,7 +48,10 @@ func (hs *clientHandshakeState) handshake() error {
 	if err := hs.establishKey(); err != nil {
 		return err
 	}
-	hs.c.masterKey = hs.masterSecret
+	if err := hs.c.establishSessionKeys(hs.masterSecret, hs.mClient, hs.mServer); err != nil {
+		return err
+	}
+	hs.c.remotePubKey = hs.mServer.hostKey()
 	atomic.StoreInt32(&hs.c.handShakeCompleted, 1)
 	return nil
 }
@@ -55,26 +61,29 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 	privKey := c.config.HostKey
 
 	handshake := Handshake{
-		DHPub:    hs.km.dhPub,
-		RSAPub:   c.config.HostKey.PublicKey,
 		Time:     time.Now().UTC(),
 		Addr:     c.RemoteAddr(),
-		IsClient: true}
+		IsClient: true,
+		Suites:   c.config.cipherSuites()}
+	for _, suite := range handshake.Suites {
+		handshake.setKeyShare(suite, hs.keyExchanges[suite].public)
+	}
+	err := handshake.setHostKey(privKey.Public())
+	if err != nil {
+		return err
+	}
 
-	err := ProofOfWork(c.config.k, &handshake)
+	err = ProofOfWork(c.config.k, &handshake)
 	if err != nil {
 		return err
 	}
 
-	// Sign message
-	var opts rsa.PSSOptions
-	opts.SaltLength = rsa.PSSSaltLengthAuto // for simple example
-	shaM := sha3.Sum256(handshake.concatIdentifiersInclNonce())
-	s, err := rsa.SignPSS(rand.Reader, privKey, crypto.SHA3_256, shaM[:], &opts)
+	// Sign message, for a server of the original protocol as well if it may be one
+	_, offersDHRSA := hs.keyExchanges[CipherSuiteDHRSA]
+	err = handshake.sign(privKey, offersDHRSA && handshake.RSAPub.N != nil)
 	if err != nil {
 		return err
 	}
-	handshake.RSASig = s
 
 	// Write handshake to server
 	err = c.write(
@@ -95,25 +104,30 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 		return messageError{}
 	}
 	hs.mServer = &handshakeServer.Handshake
+	// The server has to choose one of the cipher suites offered
+	hs.suite = hs.mServer.suites()[0]
+	if _, ok := hs.keyExchanges[hs.suite]; !ok {
+		return fmt.Errorf("securecomm: Server chose the cipher suite %s, which was not offered", hs.suite)
+	}
 	err = checkProofOfWorkValidity(hs.c.config.k, hs.mServer)
 	if err != nil {
 		return err
 	}
-	err = CheckIdentity(&hs.mServer.RSAPub, hs.c.config.TrustedIdentitiesPath)
+	err = CheckIdentity(hs.mServer.hostKey(), hs.c.config)
 	if err != nil {
 		return err
 	}
-	if !utils.TCPAddrCmp(c.conn.LocalAddr().String(), hs.mServer.Addr.String()) {
+	// The server signs the address it was dialed at, confirming it as its own.
+	if !utils.TCPAddrCmp(c.conn.RemoteAddr().String(), hs.mServer.Addr.String()) {
 		return fmt.Errorf("securecomm: Handshake IP Address and Connection IP Address don't match")
 	}
-	shaM = sha3.Sum256(hs.mServer.concatIdentifiersInclNonce())
-	err = rsa.VerifyPSS(&hs.mServer.RSAPub, crypto.SHA3_256, shaM[:], hs.mServer.RSASig, &opts)
+	err = hs.mServer.verify()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 func (hs *clientHandshakeState) establishKey() (err error) {
-	hs.masterSecret, err = hs.km.computeFinalKey(hs.mServer.DHPub)
+	hs.masterSecret, err = hs.keyExchanges[hs.suite].sharedSecret(hs.mServer.keyShare(hs.suite))
 	return err
 }
@@ -1,15 +1,10 @@
 package securecomm
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"fmt"
 	"gossip/src/utils"
 	"sync/atomic"
 	"time"
-
-	"golang.org/x/crypto/sha3"
 )
 
 func (c *SecureConn) serverHandshake() (err error) {
@@ -18,10 +13,8 @@ func (c *SecureConn) serverHandshake() (err error) {
 	}
 
 	hs := &serverHandshakeState{
-		c:  c,
-		km: emptyKM(),
+		c: c,
 	}
-	hs.km.generateOwnDHKeys()
 
 	if err := hs.handshake(); err != nil {
 		return err
@@ -31,8 +24,10 @@ func (c *SecureConn) serverHandshake() (err error) {
 }
 
 type serverHandshakeState struct {
-	c            *SecureConn
-	km           *KeyManagement
+	c *SecureConn
+	// kx is the key pair of the cipher suite chosen, which is
+	// only generated after the client offered the cipher suites.
+	kx           *keyExchange
 	mClient      *Handshake
 	mServer      *Handshake
 	masterSecret []byte
@@ -45,7 +40,10 @@ func (hs *serverHandshakeState) handshake() error {
 	if err := hs.establishKey(); err != nil {
 		return err
 	}
-	hs.c.masterKey = hs.masterSecret
+	if err := hs.c.establishSessionKeys(hs.masterSecret, hs.mClient, hs.mServer); err != nil {
+		return err
+	}
+	hs.c.remotePubKey = hs.mClient.hostKey()
 	atomic.StoreInt32(&hs.c.handShakeCompleted, 1)
 	return nil
 }
@@ -67,39 +65,50 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 	if err != nil {
 		return err
 	}
-	err = CheckIdentity(&hs.mClient.RSAPub, hs.c.config.TrustedIdentitiesPath)
+	err = CheckIdentity(hs.mClient.hostKey(), hs.c.config)
 	if err != nil {
 		return err
 	}
-	if !utils.TCPAddrCmp(c.conn.LocalAddr().String(), hs.mClient.Addr.String()) {
-		return fmt.Errorf("securecomm: Handshake IP Address and Connection IP Address don't match")
+	// The client signs the address it dialed, which has to be either the
+	// local address of the connection or an advertised address of this host.
+	if !hs.isOwnAddr(hs.mClient.Addr.String()) {
+		return fmt.Errorf("securecomm: Handshake IP Address is not an address of this host")
+	}
+	err = hs.mClient.verify()
+	if err != nil {
+		return err
 	}
-	var opts rsa.PSSOptions
-	opts.SaltLength = rsa.PSSSaltLengthAuto // for simple example
-	shaM := sha3.Sum256(hs.mClient.concatIdentifiersInclNonce())
-	err = rsa.VerifyPSS(&hs.mClient.RSAPub, crypto.SHA3_256, shaM[:], hs.mClient.RSASig, &opts)
+	suite, err := hs.chooseSuite()
 	if err != nil {
 		return err
 	}
+	if hs.kx, err = newKeyExchange(suite); err != nil {
+		return err
+	}
 
 	// Write handshake to Client
 	handshake := Handshake{
-		DHPub:    hs.km.dhPub,
-		RSAPub:   hs.c.config.HostKey.PublicKey,
 		Time:     time.Now().UTC(),
-		Addr:     c.RemoteAddr(),
+		Addr:     hs.mClient.Addr,
 		IsClient: false}
+	// A client of the original protocol does not know the cipher suites
+	if len(hs.mClient.Suites) != 0 {
+		handshake.Suites = []CipherSuite{suite}
+	}
+	handshake.setKeyShare(suite, hs.kx.public)
+	err = handshake.setHostKey(privKey.Public())
+	if err != nil {
+		return err
+	}
 
 	err = ProofOfWork(c.config.k, &handshake)
 	if err != nil {
 		return err
 	}
-	shaM = sha3.Sum256(handshake.concatIdentifiersInclNonce())
-	s, err := rsa.SignPSS(rand.Reader, privKey, crypto.SHA3_256, shaM[:], &opts)
+	err = handshake.sign(privKey, false)
 	if err != nil {
 		return err
 	}
-	handshake.RSASig = s
 	c.write(
 		&Message{
 			Data:      make([]byte, 0),
@@ -108,7 +117,35 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 
 	return nil
 }
+
+// chooseSuite returns the first cipher suite of the config,
+// which is offered by the client.
+func (hs *serverHandshakeState) chooseSuite() (CipherSuite, error) {
+	for _, suite := range hs.c.config.cipherSuites() {
+		for _, offered := range hs.mClient.suites() {
+			if suite == offered {
+				return suite, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("securecomm: No cipher suite offered by the client is accepted")
+}
+
+// isOwnAddr returns true iff the address is either the local
+// address of the connection or an advertised address.
+func (hs *serverHandshakeState) isOwnAddr(addr string) bool {
+	if utils.TCPAddrCmp(hs.c.conn.LocalAddr().String(), addr) {
+		return true
+	}
+	for _, advertisedAddr := range hs.c.config.AdvertisedAddrs {
+		if utils.TCPAddrCmp(advertisedAddr, addr) {
+			return true
+		}
+	}
+	return false
+}
+
 func (hs *serverHandshakeState) establishKey() (err error) {
-	hs.masterSecret, err = hs.km.computeFinalKey(hs.mClient.DHPub)
+	hs.masterSecret, err = hs.kx.sharedSecret(hs.mClient.keyShare(hs.kx.suite))
 	return err
 }
@@ -0,0 +1,356 @@
+package securecomm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAHostKeyOnce sync.Once
+	testRSAHostKey     *rsa.PrivateKey
+)
+
+// newTestRSAHostKey returns a 4096-bit RSA host key, which is
+// generated only once since it takes a while.
+func newTestRSAHostKey(t *testing.T) crypto.Signer {
+	testRSAHostKeyOnce.Do(func() {
+		testRSAHostKey, _ = rsa.GenerateKey(rand.Reader, 4096)
+	})
+	if testRSAHostKey == nil {
+		t.Fatal("cannot generate an RSA host key")
+	}
+	return testRSAHostKey
+}
+
+// newTestEd25519HostKey returns a new Ed25519 host key.
+func newTestEd25519HostKey(t *testing.T) crypto.Signer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hostKey
+}
+
+// newTestConfig returns a config of the host key and the cipher suites, which
+// trusts the identities of the trusted host keys. The returned function
+// removes the folder of the trusted identities.
+func newTestConfig(t *testing.T, hostKey crypto.Signer, suites []CipherSuite, trusted ...crypto.Signer) (*Config, func()) {
+	dir, err := ioutil.TempDir("", "trusted_identities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trustedKey := range trusted {
+		id, err := IdentityOf(trustedKey.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(id[:])), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := &Config{TrustedIdentitiesPath: dir, HostKey: hostKey, CipherSuites: suites, cacheSize: 1}
+	return config, func() { os.RemoveAll(dir) }
+}
+
+// newTestHandshake returns a client handshake offering the cipher suites,
+// with its proof of work and signed with the host key the way a client does.
+// Without any cipher suite, it is a handshake of the original protocol.
+func newTestHandshake(t *testing.T, hostKey crypto.Signer, addr net.Addr, suites ...CipherSuite) *Handshake {
+	h := &Handshake{Time: time.Now().UTC(), Addr: addr, IsClient: true, Suites: suites}
+	offersDHRSA := false
+	for _, suite := range h.suites() {
+		// The suites unknown to this version have no key share.
+		if suite.keyShareSize() == 0 {
+			continue
+		}
+		kx, err := newKeyExchange(suite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.setKeyShare(suite, kx.public)
+		offersDHRSA = offersDHRSA || suite == CipherSuiteDHRSA
+	}
+	if err := h.setHostKey(hostKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+	// A proof of work is not found with a small probability, so retry it.
+	var err error
+	for i := 0; i < 3; i++ {
+		if err = ProofOfWork(0, h); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.sign(hostKey, offersDHRSA && h.RSAPub.N != nil); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// isPoWFailure returns true iff the error is a proof of work not being found,
+// which happens with a small probability and is not a failed handshake.
+func isPoWFailure(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "No suitable nonces")
+}
+
+// handshakeOverLoopback runs the handshake of a client and a server of the
+// configs over the loopback interface. Returns the connections, which are
+// closed by the caller, and the errors of both sides.
+func handshakeOverLoopback(t *testing.T, clientConfig, serverConfig *Config) (client, server *SecureConn, clientErr, serverErr error) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The handshake is retried if either side did not find a proof of work.
+	for i := 0; i < 3; i++ {
+		clientConn, err := net.DialTCP("tcp", nil, ln.Addr().(*net.TCPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		serverConn, err := ln.AcceptTCP()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, server = Client(clientConn, clientConfig), SecureServer(serverConn, serverConfig)
+		deadline := time.Now().Add(HandshakeExpirationTime)
+		client.SetDeadline(deadline)
+		server.SetDeadline(deadline)
+		serverErrCh := make(chan error, 1)
+		go func() {
+			err := server.Handshake()
+			if err != nil {
+				// The client does not wait for the handshake of the server then.
+				server.Close()
+			}
+			serverErrCh <- err
+		}()
+		clientErr = client.Handshake()
+		if clientErr != nil {
+			client.Close()
+		}
+		serverErr = <-serverErrCh
+		if !isPoWFailure(clientErr) && !isPoWFailure(serverErr) {
+			break
+		}
+		client.Close()
+		server.Close()
+	}
+	return client, server, clientErr, serverErr
+}
+
+func TestHandshakeCipherSuites(t *testing.T) {
+	rsaKey, ed25519Key := newTestRSAHostKey(t), newTestEd25519HostKey(t)
+	x25519, dhRSA := CipherSuiteX25519Ed25519, CipherSuiteDHRSA
+	tests := []struct {
+		name                       string
+		clientKey, serverKey       crypto.Signer
+		clientSuites, serverSuites []CipherSuite
+		ok                         bool
+	}{
+		{"ed25519 peers", ed25519Key, ed25519Key, []CipherSuite{x25519}, []CipherSuite{x25519}, true},
+		{"rsa client and ed25519 server", rsaKey, ed25519Key, []CipherSuite{x25519, dhRSA}, []CipherSuite{x25519}, true},
+		{"ed25519 client and rsa server", ed25519Key, rsaKey, []CipherSuite{x25519}, []CipherSuite{dhRSA, x25519}, true},
+		{"rsa peers with dh-rsa", rsaKey, rsaKey, []CipherSuite{dhRSA}, []CipherSuite{x25519, dhRSA}, true},
+		{"no common cipher suite", ed25519Key, rsaKey, []CipherSuite{x25519}, []CipherSuite{dhRSA}, false},
+	}
+	for _, test := range tests {
+		clientConfig, cleanupClient := newTestConfig(t, test.clientKey, test.clientSuites, test.serverKey)
+		defer cleanupClient()
+		serverConfig, cleanupServer := newTestConfig(t, test.serverKey, test.serverSuites, test.clientKey)
+		defer cleanupServer()
+
+		client, server, clientErr, serverErr := handshakeOverLoopback(t, clientConfig, serverConfig)
+		if !test.ok {
+			if clientErr == nil || serverErr == nil {
+				t.Errorf("%s: handshake = %v, %v, want errors on both sides", test.name, clientErr, serverErr)
+			}
+			continue
+		}
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("%s: handshake = %v, %v", test.name, clientErr, serverErr)
+		}
+		clientID, _ := IdentityOf(test.clientKey.Public())
+		serverID, _ := IdentityOf(test.serverKey.Public())
+		if id, _ := IdentityOf(server.RemotePublicKey()); id != clientID {
+			t.Errorf("%s: the server sees another identity of the client", test.name)
+		}
+		if id, _ := IdentityOf(client.RemotePublicKey()); id != serverID {
+			t.Errorf("%s: the client sees another identity of the server", test.name)
+		}
+		// Both sides derived the same session keys.
+		go client.Write([]byte("ping"))
+		b := make([]byte, 4096)
+		if n, err := server.Read(b); err != nil || string(b[:n]) != "ping" {
+			t.Errorf("%s: Read = %q, %v, want %q", test.name, b[:n], err, "ping")
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestChooseSuite(t *testing.T) {
+	x25519, dhRSA := CipherSuiteX25519Ed25519, CipherSuiteDHRSA
+	tests := []struct {
+		name           string
+		server, client []CipherSuite
+		chosen         CipherSuite
+		ok             bool
+	}{
+		{"preference of the server", []CipherSuite{dhRSA, x25519}, []CipherSuite{x25519, dhRSA}, dhRSA, true},
+		{"only common suite", []CipherSuite{dhRSA, x25519}, []CipherSuite{x25519}, x25519, true},
+		{"unknown suite offered", []CipherSuite{x25519}, []CipherSuite{CipherSuite(9), x25519}, x25519, true},
+		{"client of the original protocol", []CipherSuite{x25519, dhRSA}, nil, dhRSA, true},
+		{"client of the original protocol refused", []CipherSuite{x25519}, nil, 0, false},
+		{"no common suite", []CipherSuite{x25519}, []CipherSuite{dhRSA}, 0, false},
+	}
+	for _, test := range tests {
+		hs := &serverHandshakeState{
+			c:       &SecureConn{config: &Config{CipherSuites: test.server}},
+			mClient: &Handshake{Suites: test.client},
+		}
+		chosen, err := hs.chooseSuite()
+		if (err == nil) != test.ok || (test.ok && chosen != test.chosen) {
+			t.Errorf("%s: chooseSuite = %s, %v, want %s, ok %t", test.name, chosen, err, test.chosen, test.ok)
+		}
+	}
+}
+
+func TestHandshakeIsValid(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6001}
+	rsaKey, ed25519Key := newTestRSAHostKey(t), newTestEd25519HostKey(t)
+	valid := map[string]*Handshake{
+		"ed25519":            newTestHandshake(t, ed25519Key, addr, CipherSuiteX25519Ed25519),
+		"rsa with both":      newTestHandshake(t, rsaKey, addr, CipherSuiteX25519Ed25519, CipherSuiteDHRSA),
+		"original protocol":  newTestHandshake(t, rsaKey, addr),
+		"unknown suite too":  newTestHandshake(t, ed25519Key, addr, CipherSuite(9), CipherSuiteX25519Ed25519),
+		"ed25519 with dhrsa": newTestHandshake(t, ed25519Key, addr, CipherSuiteDHRSA),
+	}
+	for name, h := range valid {
+		if !h.isValid() {
+			t.Errorf("isValid of the %s handshake = false, want true", name)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		h      *Handshake
+		modify func(h *Handshake)
+	}{
+		{"short X25519 key share", valid["ed25519"], func(h *Handshake) { h.X25519Pub = h.X25519Pub[1:] }},
+		{"missing X25519 key share", valid["ed25519"], func(h *Handshake) { h.X25519Pub = nil }},
+		{"short DH key share", valid["rsa with both"], func(h *Handshake) { h.DHPub = h.DHPub[1:] }},
+		{"only unknown suites", valid["ed25519"], func(h *Handshake) { h.Suites = []CipherSuite{CipherSuite(9)} }},
+		{"short Ed25519 key", valid["ed25519"], func(h *Handshake) { h.Ed25519Pub = h.Ed25519Pub[1:] }},
+		{"two host keys", valid["ed25519"], func(h *Handshake) { h.RSAPub = *rsaKey.Public().(*rsa.PublicKey) }},
+		{"short ExtNonce", valid["ed25519"], func(h *Handshake) { h.ExtNonce = h.ExtNonce[1:] }},
+		{"ExtNonce without extension", valid["original protocol"], func(h *Handshake) { h.ExtNonce = h.Nonce }},
+		{"short Nonce", valid["ed25519"], func(h *Handshake) { h.Nonce = h.Nonce[1:] }},
+		{"missing ExtSig", valid["ed25519"], func(h *Handshake) { h.ExtSig = nil }},
+		{"short ExtSig", valid["rsa with both"], func(h *Handshake) { h.ExtSig = h.ExtSig[1:] }},
+		{"short RSASig", valid["rsa with both"], func(h *Handshake) { h.RSASig = h.RSASig[1:] }},
+		{"missing RSASig", valid["original protocol"], func(h *Handshake) { h.RSASig = nil }},
+		{"server with two suites", valid["rsa with both"], func(h *Handshake) { h.IsClient = false }},
+		{"expired", valid["ed25519"], func(h *Handshake) { h.Time = h.Time.Add(-2 * HandshakeExpirationTime) }},
+	}
+	for _, test := range tests {
+		modified := *test.h
+		test.modify(&modified)
+		if modified.isValid() {
+			t.Errorf("isValid of a handshake with %s = true, want false", test.name)
+		}
+	}
+}
+
+// writeTestHandshake sends the client handshake made for the address of a
+// server of the config and returns the error of the handshake of the server.
+func writeTestHandshake(t *testing.T, serverConfig *Config, newHandshake func(addr net.Addr) *Handshake) error {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	clientConn, err := net.DialTCP("tcp", nil, ln.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := ln.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := SecureServer(serverConn, serverConfig)
+	defer server.Close()
+	server.SetDeadline(time.Now().Add(HandshakeExpirationTime))
+
+	h := newHandshake(ln.Addr())
+	if err := gob.NewEncoder(clientConn).Encode(&Message{Data: []byte{}, Handshake: *h}); err != nil {
+		t.Fatal(err)
+	}
+	return server.Handshake()
+}
+
+func TestHandshakeRejectsDowngradeAndTampering(t *testing.T) {
+	rsaKey := newTestRSAHostKey(t)
+	x25519, dhRSA := CipherSuiteX25519Ed25519, CipherSuiteDHRSA
+	serverConfig, cleanup := newTestConfig(t, newTestEd25519HostKey(t), []CipherSuite{x25519, dhRSA}, rsaKey)
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		suites []CipherSuite
+		modify func(h *Handshake)
+		ok     bool
+	}{
+		{"no change", []CipherSuite{x25519, dhRSA}, func(*Handshake) {}, true},
+		{"stripped X25519 suite", []CipherSuite{x25519, dhRSA}, func(h *Handshake) {
+			h.Suites, h.X25519Pub = []CipherSuite{dhRSA}, nil
+		}, false},
+		{"reordered suites", []CipherSuite{x25519, dhRSA}, func(h *Handshake) {
+			h.Suites = []CipherSuite{dhRSA, x25519}
+		}, false},
+		{"replaced X25519 key share", []CipherSuite{x25519, dhRSA}, func(h *Handshake) {
+			h.X25519Pub = bytes.Repeat([]byte{9}, len(h.X25519Pub))
+		}, false},
+		{"replaced ExtNonce", []CipherSuite{x25519, dhRSA}, func(h *Handshake) {
+			h.ExtNonce = bytes.Repeat([]byte{1}, ScryptNonceSize)
+		}, false},
+		// A client not offering dh-rsa makes no signature of the original
+		// protocol, so it cannot be made to look like a client of it.
+		{"stripped extension", []CipherSuite{x25519}, func(h *Handshake) {
+			h.Suites, h.X25519Pub, h.ExtNonce, h.ExtSig = nil, nil, nil, nil
+		}, false},
+	}
+	for _, test := range tests {
+		var err error
+		// The server does not find a proof of work with a small probability.
+		for i := 0; i < 3; i++ {
+			err = writeTestHandshake(t, serverConfig, func(addr net.Addr) *Handshake {
+				h := newTestHandshake(t, rsaKey, addr, test.suites...)
+				test.modify(h)
+				return h
+			})
+			if !isPoWFailure(err) {
+				break
+			}
+		}
+		if (err == nil) != test.ok {
+			t.Errorf("%s: handshake of the server = %v, want ok %t", test.name, err, test.ok)
+		}
+	}
+}
@@ -1,9 +1,7 @@
 package securecomm
 
 import (
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
+	"crypto"
 	"encoding/hex"
 	"fmt"
 	"gossip/src/parser/identity"
@@ -35,11 +33,24 @@ func emptyKM() *KeyManagement {
 	return &KeyManagement{}
 }
 
+// hashVal returns the scrypt hash of the identifiers of the handshake with
+// Nonce, which is the proof of work of the original protocol.
 func (h *Handshake) hashVal() (*big.Int, error) {
-	if h.Nonce == nil {
+	return scryptHashVal(h.concatIdentifiers(), h.Nonce)
+}
+
+// extHashVal returns the scrypt hash of the fields of the handshake unknown to
+// the original protocol, together with the ones known to it, with ExtNonce.
+// It is the proof of work covering the key share and the cipher suites.
+func (h *Handshake) extHashVal() (*big.Int, error) {
+	return scryptHashVal(h.concatExtension(), h.ExtNonce)
+}
+
+func scryptHashVal(data, nonce []byte) (*big.Int, error) {
+	if nonce == nil {
 		return nil, fmt.Errorf("securecomm: Nonce should not be nil")
 	}
-	hash, err := scrypt.Key(h.concatIdentifiers(), h.Nonce, ScryptN, ScryptR, ScryptP, ScryptHashlength)
+	hash, err := scrypt.Key(data, nonce, ScryptN, ScryptR, ScryptP, ScryptHashlength)
 	if err != nil {
 		return nil, err
 	}
@@ -68,8 +79,20 @@ func (km *KeyManagement) returnNonceSize() int {
 }
 
 // checkProofOfWorkValidity expects k, and the handshake, where the nonce is seperated and the signatur is not included and checks the handshake for validity of the proof of work.
+// A handshake not of the original protocol has the proof of work of its extension as well.
 func checkProofOfWorkValidity(k int, h *Handshake) error {
-	hashVal, err := h.hashVal()
+	if err := checkHashVal(h.hashVal); err != nil {
+		return err
+	}
+	if h.isExtended() {
+		return checkHashVal(h.extHashVal)
+	}
+	return nil
+}
+
+// checkHashVal checks that the hash of a proof of work is below the threshold.
+func checkHashVal(hashValFn func() (*big.Int, error)) error {
+	hashVal, err := hashValFn()
 	if err != nil {
 		return err
 	}
@@ -91,34 +114,54 @@ func PoWThreshold(repetition, bits uint64) *big.Int {
 	return k
 }
 
-// ProofOfWork tries to find right nonce to have k leading zeros.
+// ProofOfWork tries to find right nonce to have k leading zeros. A handshake
+// not of the original protocol gets the nonce of its extension as well, which
+// is found after Nonce, since its proof of work covers Nonce.
 func ProofOfWork(k int, h *Handshake) error {
+	if err := findNonce(&h.Nonce, h.hashVal); err != nil {
+		return err
+	}
+	if h.isExtended() {
+		return findNonce(&h.ExtNonce, h.extHashVal)
+	}
+	return nil
+}
+
+// findNonce tries random nonces until the hash of the proof of work is below
+// the threshold. The nonce is nil if none is found.
+func findNonce(nonce *[]byte, hashValFn func() (*big.Int, error)) error {
 	// Threshold that must not be crossed to have a valid nonce
 	threshold := PoWThreshold(ScryptRepetition, ScryptHashlength*8)
-	h.Nonce = make([]byte, ScryptNonceSize)
-	rand.Read(h.Nonce)
+	*nonce = make([]byte, ScryptNonceSize)
+	rand.Read(*nonce)
 	// https://wizardforcel.gitbooks.io/practical-cryptography-for-developers-book/content/mac-and-key-derivation/scrypt.html
 	// Memory required = 128 * N * r * p bytes
 	for i := 0; i < 4*ScryptRepetition; i++ {
-		hashVal, err := h.hashVal()
+		hashVal, err := hashValFn()
 		if err != nil {
 			return err
 		}
 		if hashVal.Cmp(threshold) <= 0 {
 			return nil
 		}
-		rand.Read(h.Nonce)
+		rand.Read(*nonce)
 	}
-	h.Nonce = nil
+	*nonce = nil
 	return fmt.Errorf("securecomm: No suitable nonces found for PoW")
 }
 
-// CheckIdentity ensures that the public key is trusted using the out-of-band shared identities.
-func CheckIdentity(pubKey *rsa.PublicKey, path string) error {
-	pubKeyBytes := x509.MarshalPKCS1PublicKey(pubKey)
-	shaKey := sha256.Sum256(pubKeyBytes)
+// CheckIdentity ensures that the public key is trusted using the out-of-band shared identities
+// and that it is not banned by the ban list of the config.
+func CheckIdentity(pubKey crypto.PublicKey, config *Config) error {
+	shaKey, err := IdentityOf(pubKey)
+	if err != nil {
+		return err
+	}
+	if config.BanList.IsIdentityBanned(shaKey) {
+		return fmt.Errorf("securecomm: Identity is banned")
+	}
 	hexStr := hex.EncodeToString(shaKey[:])
-	identities := identity.Parse(path)
+	identities := identity.Parse(config.TrustedIdentitiesPath)
 	for _, v := range identities {
 		if v == hexStr {
 			return nil
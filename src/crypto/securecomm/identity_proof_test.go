@@ -0,0 +1,56 @@
+package securecomm
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestProofOfWorkCoversExtension(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6001}
+	h := newTestHandshake(t, newTestEd25519HostKey(t), addr, CipherSuiteX25519Ed25519)
+	if len(h.ExtNonce) != ScryptNonceSize {
+		t.Fatalf("ExtNonce has %d bytes, want %d", len(h.ExtNonce), ScryptNonceSize)
+	}
+	if err := checkProofOfWorkValidity(0, h); err != nil {
+		t.Fatalf("checkProofOfWorkValidity of a valid handshake = %v", err)
+	}
+	original, err := h.extHashVal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(h *Handshake)
+	}{
+		{"altered key share", func(h *Handshake) { h.X25519Pub = bytes.Repeat([]byte{8}, 32) }},
+		{"altered cipher suites", func(h *Handshake) { h.Suites = []CipherSuite{CipherSuiteX25519Ed25519, CipherSuiteDHRSA} }},
+		{"altered nonce", func(h *Handshake) { h.Nonce = bytes.Repeat([]byte{1}, ScryptNonceSize) }},
+	}
+	for _, test := range tests {
+		modified := *h
+		test.modify(&modified)
+		hashVal, err := modified.extHashVal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hashVal.Cmp(original) == 0 {
+			t.Errorf("the proof of work of the extension does not cover the %s", test.name)
+		}
+	}
+
+	// The signature covers the nonce of the extension.
+	modified := *h
+	modified.ExtNonce = bytes.Repeat([]byte{1}, ScryptNonceSize)
+	if bytes.Equal(modified.concatAll(), h.concatAll()) {
+		t.Errorf("the signed bytes do not cover ExtNonce")
+	}
+
+	// The handshakes of the original protocol have no nonce of an extension.
+	legacy := *h
+	legacy.Suites, legacy.X25519Pub, legacy.ExtNonce = nil, nil, nil
+	if err := checkProofOfWorkValidity(0, &legacy); err != nil {
+		t.Errorf("checkProofOfWorkValidity of a handshake of the original protocol = %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package securecomm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// Labels of the HKDF expansions, which separate the keys of both directions
+// and the keys ratcheted forward from them.
+const (
+	clientToServerLabel = "securecomm client to server"
+	serverToClientLabel = "securecomm server to client"
+	rekeyLabel          = "securecomm rekey"
+)
+
+// sessionKeySize is the size of the AES-256 keys of both directions.
+const sessionKeySize = 32
+
+// transcriptHash returns the hash of both signed handshakes, so that the
+// session keys are bound to the identities and DH keys of both peers. The
+// server only answers in the original protocol to a client of it, so that
+// the handshakes are hashed the same way the peers of it do then.
+func transcriptHash(mClient, mServer *Handshake) []byte {
+	h := sha3.New256()
+	if mServer.isExtended() {
+		h.Write(mClient.concatAll())
+		h.Write(mClient.ExtSig)
+		h.Write(mServer.concatAll())
+		h.Write(mServer.ExtSig)
+		return h.Sum(nil)
+	}
+	h.Write(mClient.concatIdentifiersInclNonce())
+	h.Write(mClient.RSASig)
+	h.Write(mServer.concatIdentifiersInclNonce())
+	h.Write(mServer.RSASig)
+	return h.Sum(nil)
+}
+
+// deriveSessionKeys derives the keys of both directions from the DH shared
+// secret with HKDF, salted with the hash of the handshake transcript.
+func deriveSessionKeys(sharedSecret []byte, mClient, mServer *Handshake) (clientToServer, serverToClient []byte, err error) {
+	salt := transcriptHash(mClient, mServer)
+	clientToServer = make([]byte, sessionKeySize)
+	if _, err = io.ReadFull(hkdf.New(sha3.New256, sharedSecret, salt, []byte(clientToServerLabel)), clientToServer); err != nil {
+		return nil, nil, err
+	}
+	serverToClient = make([]byte, sessionKeySize)
+	if _, err = io.ReadFull(hkdf.New(sha3.New256, sharedSecret, salt, []byte(serverToClientLabel)), serverToClient); err != nil {
+		return nil, nil, err
+	}
+	return clientToServer, serverToClient, nil
+}
+
+// recordCipher encrypts or decrypts the records of one direction of a
+// SecureConn. The nonce of a record is its sequence number, which is not
+// sent but counted by both peers, so that any replayed, reordered or
+// dropped record fails to decrypt. The key can be ratcheted forward, after
+// which the sequence numbers start from 0 again.
+type recordCipher struct {
+	key  []byte
+	aead cipher.AEAD
+	// seq is the sequence number of the next record.
+	seq uint64
+	// bytes is the number of plaintext bytes sealed with the key.
+	bytes uint64
+	// keyedAt is the time the key was derived.
+	keyedAt time.Time
+}
+
+// newRecordCipher is the constructor function for recordCipher.
+func newRecordCipher(key []byte) (*recordCipher, error) {
+	rc := &recordCipher{}
+	if err := rc.setKey(key); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// setKey is the method for replacing the key and resetting the sequence numbers.
+func (rc *recordCipher) setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	rc.key, rc.aead, rc.seq, rc.bytes, rc.keyedAt = key, aead, 0, 0, time.Now()
+	return nil
+}
+
+// rekey is the method for ratcheting the key forward. The next key is derived
+// from the current one with HKDF, so that the records sealed with the previous
+// keys cannot be decrypted with the next ones.
+func (rc *recordCipher) rekey() error {
+	next := make([]byte, sessionKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha3.New256, rc.key, nil, []byte(rekeyLabel)), next); err != nil {
+		return err
+	}
+	return rc.setKey(next)
+}
+
+// needsRekey returns true iff the key has sealed at least maxBytes bytes or is
+// used for at least maxAge. A limit of 0 is never reached.
+func (rc *recordCipher) needsRekey(maxBytes uint64, maxAge time.Duration) bool {
+	return (maxBytes != 0 && rc.bytes >= maxBytes) || (maxAge != 0 && time.Since(rc.keyedAt) >= maxAge)
+}
+
+// nextNonce returns the nonce of the next record and advances the sequence number.
+func (rc *recordCipher) nextNonce() ([]byte, error) {
+	// A sequence number must never be reused with the same key.
+	if rc.seq == ^uint64(0) {
+		return nil, fmt.Errorf("securecomm: sequence numbers are exhausted")
+	}
+	nonce := make([]byte, rc.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], rc.seq)
+	rc.seq++
+	return nonce, nil
+}
+
+// seal encrypts the plaintext as the next record. The additional data is
+// authenticated, but not encrypted.
+func (rc *recordCipher) seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce, err := rc.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	rc.bytes += uint64(len(plaintext))
+	return rc.aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the ciphertext as the next record. The additional data
+// has to be the same as the one the record is sealed with.
+func (rc *recordCipher) open(ciphertext, additionalData []byte) ([]byte, error) {
+	nonce, err := rc.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	return rc.aead.Open(nil, nonce, ciphertext, additionalData)
+}
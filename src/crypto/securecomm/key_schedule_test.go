@@ -0,0 +1,118 @@
+package securecomm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// newTestRecordCiphers returns the sealing and the opening recordCipher of the same key.
+func newTestRecordCiphers(t *testing.T) (sealer, opener *recordCipher) {
+	key := bytes.Repeat([]byte{7}, sessionKeySize)
+	sealer, err := newRecordCipher(append([]byte{}, key...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opener, err = newRecordCipher(append([]byte{}, key...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealer, opener
+}
+
+func TestRecordCipherSealOpen(t *testing.T) {
+	sealer, opener := newTestRecordCiphers(t)
+	ad := []byte("ad")
+	for i, plaintext := range [][]byte{[]byte("first"), {}, []byte("third")} {
+		ciphertext, err := sealer.seal(plaintext, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := opener.open(ciphertext, ad)
+		if err != nil {
+			t.Fatalf("open of record %d = %v", i, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatalf("open of record %d = %q, want %q", i, opened, plaintext)
+		}
+	}
+	if sealer.bytes != uint64(len("first")+len("third")) {
+		t.Fatalf("bytes = %d, want %d", sealer.bytes, len("first")+len("third"))
+	}
+}
+
+func TestRecordCipherRejectsReplayAndReorder(t *testing.T) {
+	sealer, opener := newTestRecordCiphers(t)
+	first, _ := sealer.seal([]byte("first"), nil)
+	second, _ := sealer.seal([]byte("second"), nil)
+
+	if _, err := opener.open(second, nil); err == nil {
+		t.Fatalf("open of a reordered record = nil error, want an error")
+	}
+	_, opener = newTestRecordCiphers(t)
+	if _, err := opener.open(first, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opener.open(first, nil); err == nil {
+		t.Fatalf("open of a replayed record = nil error, want an error")
+	}
+
+	_, opener = newTestRecordCiphers(t)
+	if _, err := opener.open(first, []byte("other")); err == nil {
+		t.Fatalf("open with other additional data = nil error, want an error")
+	}
+}
+
+func TestRecordCipherRekey(t *testing.T) {
+	sealer, opener := newTestRecordCiphers(t)
+	before, _ := sealer.seal([]byte("before"), nil)
+	if _, err := opener.open(before, nil); err != nil {
+		t.Fatal(err)
+	}
+	oldKey := append([]byte{}, sealer.key...)
+	if err := sealer.rekey(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(oldKey, sealer.key) || sealer.seq != 0 || sealer.bytes != 0 {
+		t.Fatalf("rekey did not replace the key and reset the counters")
+	}
+
+	after, _ := sealer.seal([]byte("after"), nil)
+	if _, err := opener.open(after, nil); err == nil {
+		t.Fatalf("open of a record of the next key with the previous key = nil error, want an error")
+	}
+	_, opener = newTestRecordCiphers(t)
+	opener.open(before, nil)
+	if err := opener.rekey(); err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := opener.open(after, nil); err != nil || string(opened) != "after" {
+		t.Fatalf("open after rekeying both sides = %q, %v, want %q, nil", opened, err, "after")
+	}
+}
+
+func TestRecordCipherNeedsRekey(t *testing.T) {
+	sealer, _ := newTestRecordCiphers(t)
+	if sealer.needsRekey(0, 0) {
+		t.Fatalf("needsRekey without limits = true, want false")
+	}
+	sealer.seal(make([]byte, 100), nil)
+	if sealer.needsRekey(101, 0) {
+		t.Fatalf("needsRekey below the byte limit = true, want false")
+	}
+	if !sealer.needsRekey(100, 0) {
+		t.Fatalf("needsRekey at the byte limit = false, want true")
+	}
+	sealer.keyedAt = time.Now().Add(-time.Hour)
+	if !sealer.needsRekey(0, time.Hour) {
+		t.Fatalf("needsRekey at the age limit = false, want true")
+	}
+}
+
+func TestRecordCipherSequenceExhaustion(t *testing.T) {
+	sealer, _ := newTestRecordCiphers(t)
+	sealer.seq = ^uint64(0)
+	if _, err := sealer.seal([]byte("x"), nil); err == nil {
+		t.Fatalf("seal with exhausted sequence numbers = nil error, want an error")
+	}
+}
@@ -3,16 +3,16 @@
 package securecomm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/gob"
 	"encoding/pem"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net"
 	"strings"
@@ -23,6 +23,18 @@ import (
 const (
 	// Time after which a Handshake is not valid anymore
 	HandshakeExpirationTime = 10 * time.Second
+	// DefaultRekeyBytes is the number of bytes sent with a key before
+	// it is ratcheted forward, unless configured otherwise.
+	DefaultRekeyBytes = 1 << 30
+	// DefaultRekeyInterval is the time duration a key is used for sending
+	// before it is ratcheted forward, unless configured otherwise.
+	DefaultRekeyInterval = 1 * time.Hour
+	// maxItemRecordSize is the maximum size of a record per cached item,
+	// which is a gossip item of at most 65535 bytes with its overhead.
+	maxItemRecordSize = 65580
+	// maxRecordOverhead is the maximum size of a record without any item,
+	// e.g. a handshake, in addition to the items.
+	maxRecordOverhead = 16384
 )
 
 // Config is the struct for configuration parameters
@@ -32,14 +44,45 @@ type Config struct {
 	// empty files whose names are hex encoded 'identity' of the trusted peers.
 	// This folder HAS TO contain the identity of the 'bootstrapper' !!!
 	TrustedIdentitiesPath string
-	// HostKey is the variable containing 4096-bit RSA key.
-	HostKey *rsa.PrivateKey
+	// HostKey is the variable containing either a 4096-bit RSA key
+	// or an Ed25519 key, whose public key is the identity of the host.
+	HostKey crypto.Signer
+	// CipherSuites are the cipher suites offered and accepted in the
+	// handshakes in the order of preference. DefaultCipherSuites are used if empty.
+	CipherSuites []CipherSuite
+	// AdvertisedAddrs are the (ip, port) pairs the peers use for connecting to
+	// this host. They may differ from the listen addresses, e.g. behind a NAT.
+	// Handshakes addressed to them are accepted in addition to the listen addresses.
+	AdvertisedAddrs []string
+	// BanList is the list of banned hosts, whose connections are refused.
+	// It is not used if nil.
+	BanList *BanList
+	// RekeyBytes is the number of bytes sent with a key, after which the key
+	// of that direction is ratcheted forward. It is never reached if 0.
+	RekeyBytes uint64
+	// RekeyInterval is the time duration a key is used for sending, after which
+	// the key of that direction is ratcheted forward. It is never reached if 0.
+	RekeyInterval time.Duration
 	// Number of zeros necessary in Proof Of Work hash
 	k int
 	// CacheSize is needed to calculate maximum message size
 	cacheSize uint16
 }
 
+// maxRecordSize returns the maximum size of a record received, which is
+// the size of a message carrying every item of the cache.
+func (config *Config) maxRecordSize() int64 {
+	return maxItemRecordSize*int64(config.cacheSize) + maxRecordOverhead
+}
+
+// cipherSuites returns the cipher suites of the config in the order of preference.
+func (config *Config) cipherSuites() []CipherSuite {
+	if len(config.CipherSuites) == 0 {
+		return DefaultCipherSuites
+	}
+	return config.CipherSuites
+}
+
 // SecureListener is the secure communication listener.
 type SecureListener struct {
 	ln     net.TCPListener
@@ -52,78 +95,101 @@ type SecureListener struct {
 // using conn as the underlying transport.
 // The config cannot be nil: users must set either ServerName or
 // InsecureSkipVerify in the config.
-func Client(conn *net.TCPConn, config *Config) *SecureConn {
-	c := &SecureConn{
-		conn:     conn,
-		config:   config,
-		isClient: true,
-		input:    gob.NewDecoder(io.LimitReader(conn, 65580*int64(config.cacheSize))),
-		output:   gob.NewEncoder(io.Writer(conn)),
-	}
+func Client(conn net.Conn, config *Config) *SecureConn {
+	c := newSecureConn(conn, config)
+	c.isClient = true
 	c.handshakeFn = c.clientHandshake
 	return c
 }
 
 // SecureServer returns a new secure server side connection
-// using TCPConn as the underlying transport.
-func SecureServer(conn *net.TCPConn, config *Config) *SecureConn {
-	c := &SecureConn{
-		conn:     conn,
-		config:   config,
-		isClient: false,
-		input:    gob.NewDecoder(io.LimitReader(conn, 65580*int64(config.cacheSize))),
-		output:   gob.NewEncoder(io.Writer(conn)),
-	}
+// using conn as the underlying transport.
+func SecureServer(conn net.Conn, config *Config) *SecureConn {
+	c := newSecureConn(conn, config)
 	c.handshakeFn = c.serverHandshake
 	return c
 }
 
+// newSecureConn returns a new secure connection using conn as the underlying
+// transport, whose records are limited to the maximum size of the config.
+func newSecureConn(conn net.Conn, config *Config) *SecureConn {
+	inputLimit := &recordLimitReader{r: bufio.NewReader(conn)}
+	return &SecureConn{
+		conn:       conn,
+		config:     config,
+		inputLimit: inputLimit,
+		input:      gob.NewDecoder(inputLimit),
+		output:     gob.NewEncoder(conn),
+	}
+}
+
 // NewConfig is the constructor method for Config struct.
+// The host key is either an RSA or an Ed25519 key.
 func NewConfig(trustedIdentitiesPath, hostKeyPath, pubKeyPath string, cacheSize uint16) (*Config, error) {
-	// Read and load the RSA private key.
+	// Read and load the private key.
 	priv, err := ioutil.ReadFile(hostKeyPath)
 	if err != nil {
 		return nil, err
 	}
 	privPem, _ := pem.Decode(priv)
 	if privPem == nil || !strings.Contains(privPem.Type, "PRIVATE KEY") {
-		return nil, fmt.Errorf("RSA key is not a valid '.pem' type private key")
+		return nil, fmt.Errorf("Host key is not a valid '.pem' type private key")
 	}
 	privPemBytes := privPem.Bytes
 	var parsedKey interface{}
 	if parsedKey, err = x509.ParsePKCS1PrivateKey(privPemBytes); err != nil {
 		if parsedKey, err = x509.ParsePKCS8PrivateKey(privPemBytes); err != nil {
-			return nil, fmt.Errorf("Unable to parse RSA private key")
+			return nil, fmt.Errorf("Unable to parse host private key")
 		}
 	}
-	privateKey, ok := parsedKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("Unable to parse RSA private key")
-	}
 
-	// Read and load the RSA public key.
+	// Read and load the public key.
 	pub, err := ioutil.ReadFile(pubKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("No RSA public key found, generating temp one")
+		return nil, fmt.Errorf("No public key found, generating temp one")
 	}
 	pubPem, _ := pem.Decode(pub)
 	if pubPem == nil || !strings.Contains(pubPem.Type, "PUBLIC KEY") {
-		return nil, fmt.Errorf("RSA key is not a valid '.pem' type public key")
+		return nil, fmt.Errorf("Public key is not a valid '.pem' type public key")
 	}
-
-	if parsedKey, err = x509.ParsePKIXPublicKey(pubPem.Bytes); err != nil {
-		return nil, fmt.Errorf("Unable to parse RSA public key")
-	}
-	var pubKey *rsa.PublicKey
-	if pubKey, ok = parsedKey.(*rsa.PublicKey); !ok {
-		return nil, fmt.Errorf("Unable to parse RSA public key")
+	parsedPubKey, err := x509.ParsePKIXPublicKey(pubPem.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse public key")
 	}
 
-	privateKey.PublicKey = *pubKey
+	var hostKey crypto.Signer
+	switch privateKey := parsedKey.(type) {
+	case *rsa.PrivateKey:
+		pubKey, ok := parsedPubKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("Unable to parse RSA public key")
+		}
+		privateKey.PublicKey = *pubKey
+		hostKey = privateKey
+	case ed25519.PrivateKey:
+		pubKey, ok := parsedPubKey.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("Unable to parse Ed25519 public key")
+		}
+		if !bytes.Equal(privateKey.Public().(ed25519.PublicKey), pubKey) {
+			return nil, fmt.Errorf("Ed25519 public key does not match the private key")
+		}
+		hostKey = privateKey
+	default:
+		return nil, fmt.Errorf("Host key is neither an RSA nor an Ed25519 key")
+	}
 
 	// Hard code k for proof of work
 	k := 12
-	return &Config{TrustedIdentitiesPath: trustedIdentitiesPath, HostKey: privateKey, k: k, cacheSize: cacheSize}, nil
+	return &Config{
+		TrustedIdentitiesPath: trustedIdentitiesPath,
+		HostKey:               hostKey,
+		CipherSuites:          append([]CipherSuite{}, DefaultCipherSuites...),
+		RekeyBytes:            DefaultRekeyBytes,
+		RekeyInterval:         DefaultRekeyInterval,
+		k:                     k,
+		cacheSize:             cacheSize,
+	}, nil
 }
 
 // Listen is the function for creating a secure
@@ -237,17 +303,24 @@ func dial(ctx context.Context, netDialer *net.Dialer, network, addr string, conf
 
 // Accept waits for and returns the next incoming secure connection.
 // The returned connection is of type *SecureConn.
+// Connections from banned IP addresses are closed without a handshake.
 func (l *SecureListener) Accept() (net.Conn, error) {
-	c, err := l.ln.AcceptTCP()
-	if err != nil {
-		select {
-		case <-l.quit:
-			return nil, fmt.Errorf("secure listener is closed")
-		default:
-			return nil, err
+	for {
+		c, err := l.ln.AcceptTCP()
+		if err != nil {
+			select {
+			case <-l.quit:
+				return nil, fmt.Errorf("secure listener is closed")
+			default:
+				return nil, err
+			}
 		}
+		if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok && l.config.BanList.IsIPBanned(addr.IP) {
+			c.Close()
+			continue
+		}
+		return SecureServer(c, l.config), nil
 	}
-	return SecureServer(c, l.config), nil
 }
 
 // Close closes the listener.
@@ -277,30 +350,44 @@ func (sc *SecureConn) Read(b []byte) (int, error) {
 		// Read(nil) for the side effect of the Handshake.
 		return 0, nil
 	}
-	encM, err := sc.read()
-	if err != nil {
-		return 0, err
-	}
-	block, err := aes.NewCipher(sc.masterKey[:32])
-	if err != nil {
-		return 0, err
-	}
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return 0, err
+	// The rest of a record which did not fit into b is returned first.
+	if len(sc.unread) == 0 {
+		plaintext, err := sc.readRecord()
+		if err != nil {
+			return 0, err
+		}
+		sc.unread = plaintext
 	}
+	n := copy(b, sc.unread)
+	sc.unread = sc.unread[n:]
+	return n, nil
+}
 
-	// Extract nonce from the data
-	nonceSize := aesGCM.NonceSize()
-	nonce, ciphertext := encM.Data[:nonceSize], encM.Data[nonceSize:]
-
-	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return 0, err
+// readRecord reads the records until one with data arrives and returns its
+// plaintext. The rekey records in between ratchet the receive key forward.
+func (sc *SecureConn) readRecord() ([]byte, error) {
+	for {
+		encM, err := sc.read()
+		if err != nil {
+			return nil, err
+		}
+		// The nonce is the sequence number of the record, so a replayed or
+		// reordered record fails to decrypt.
+		if !encM.Rekey {
+			plaintext, err := sc.recvCipher.open(encM.Data, nil)
+			if err != nil || len(plaintext) != 0 {
+				return plaintext, err
+			}
+			continue
+		}
+		// The remote peer ratchets its key forward after this record.
+		if _, err = sc.recvCipher.open(encM.Data, []byte(rekeyLabel)); err != nil {
+			return nil, err
+		}
+		if err = sc.recvCipher.rekey(); err != nil {
+			return nil, err
+		}
 	}
-	copy(b, plaintext)
-
-	return len(plaintext), nil
 }
 
 // Write writes data to the connection.
@@ -308,28 +395,35 @@ func (sc *SecureConn) Write(b []byte) (int, error) {
 	if err := sc.Handshake(); err != nil {
 		return 0, err
 	}
-	block, err := aes.NewCipher(sc.masterKey[:32])
-	if err != nil {
-		return 0, err
+	sc.sendMutex.Lock()
+	defer sc.sendMutex.Unlock()
+	if sc.writeErr != nil {
+		return 0, sc.writeErr
 	}
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
+	if err := sc.writeRecord(b); err != nil {
+		sc.writeErr = err
 		return 0, err
 	}
-	//Create a nonce to be used with GCM
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		panic(err)
+	return len(b), nil
+}
+
+// writeRecord seals the data into the next record and sends it, after
+// ratcheting the key forward if needed. The caller has to hold sendMutex.
+func (sc *SecureConn) writeRecord(b []byte) error {
+	// Limit the lifetime of the key by ratcheting it forward in-band.
+	if sc.sendCipher.needsRekey(sc.config.RekeyBytes, sc.config.RekeyInterval) {
+		if err := sc.sendRekey(); err != nil {
+			return err
+		}
+	}
+	// The nonce is the sequence number of the record, which is not sent.
+	encB, err := sc.sendCipher.seal(b, nil)
+	if err != nil {
+		return err
 	}
-	// Add nonce as a prefix to the ciphertext
-	encB := aesGCM.Seal(nonce, nonce, b, nil)
 	m := &Message{
 		Data: encB}
-	err = sc.write(m)
-	if err != nil {
-		return 0, err
-	}
-	return len(b), nil
+	return sc.write(m)
 }
 
 // Close closes the secure connection properly.
@@ -0,0 +1,223 @@
+package securecomm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSecureConnPair returns the client and the server side of a secure
+// connection over net.Pipe, whose session keys are established without a
+// handshake. Both sides use the config.
+func newTestSecureConnPair(t *testing.T, config *Config) (client, server *SecureConn) {
+	clientConn, serverConn := net.Pipe()
+	client, server = Client(clientConn, config), SecureServer(serverConn, config)
+	clientToServer := bytes.Repeat([]byte{1}, sessionKeySize)
+	serverToClient := bytes.Repeat([]byte{2}, sessionKeySize)
+	for _, keys := range []struct {
+		c                *SecureConn
+		sendKey, recvKey []byte
+	}{{client, clientToServer, serverToClient}, {server, serverToClient, clientToServer}} {
+		var err error
+		if keys.c.sendCipher, err = newRecordCipher(append([]byte{}, keys.sendKey...)); err != nil {
+			t.Fatal(err)
+		}
+		if keys.c.recvCipher, err = newRecordCipher(append([]byte{}, keys.recvKey...)); err != nil {
+			t.Fatal(err)
+		}
+		keys.c.handShakeCompleted = 1
+	}
+	return client, server
+}
+
+// readAll decodes n messages from the connection with gob, which reads it
+// through a 4096-byte buffer like the P2P endpoints do, and sends them to the
+// returned channel, which is closed afterwards. The first error is sent to errCh.
+func readAll(c *SecureConn, n int, errCh chan<- error) <-chan string {
+	messages := make(chan string, n)
+	go func() {
+		defer close(messages)
+		decoder := gob.NewDecoder(c)
+		for i := 0; i < n; i++ {
+			var message string
+			if err := decoder.Decode(&message); err != nil {
+				errCh <- fmt.Errorf("decode of message %d = %v", i, err)
+				return
+			}
+			messages <- message
+		}
+	}()
+	return messages
+}
+
+func TestSecureConnRekeyWithParallelWriters(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{"rekey bytes", &Config{RekeyBytes: 16, cacheSize: 1}},
+		{"rekey interval", &Config{RekeyInterval: time.Nanosecond, cacheSize: 1}},
+	}
+	for _, test := range tests {
+		client, server := newTestSecureConnPair(t, test.config)
+		firstKey := append([]byte{}, client.sendCipher.key...)
+		const writers, writes = 8, 50
+		errCh := make(chan error, writers+1)
+		records := readAll(server, writers*writes, errCh)
+
+		// The encoder serializes the writers, like the writer goroutine of a P2P endpoint.
+		encoder := gob.NewEncoder(client)
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < writes; i++ {
+					if err := encoder.Encode(fmt.Sprintf("writer %d record %d", w, i)); err != nil {
+						errCh <- fmt.Errorf("write %d of writer %d = %v", i, w, err)
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		got := map[string]bool{}
+		for record := range records {
+			got[record] = true
+		}
+		select {
+		case err := <-errCh:
+			t.Fatalf("%s: %v", test.name, err)
+		default:
+		}
+		for w := 0; w < writers; w++ {
+			for i := 0; i < writes; i++ {
+				if record := fmt.Sprintf("writer %d record %d", w, i); !got[record] {
+					t.Fatalf("%s: %q was not received", test.name, record)
+				}
+			}
+		}
+		if bytes.Equal(firstKey, client.sendCipher.key) || !bytes.Equal(client.sendCipher.key, server.recvCipher.key) {
+			t.Fatalf("%s: the keys were not ratcheted forward in sync", test.name)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestSecureConnRecordSizeLimit(t *testing.T) {
+	config := &Config{cacheSize: 1}
+	client, server := newTestSecureConnPair(t, config)
+	defer client.Close()
+	defer server.Close()
+
+	// The records are limited one by one, not over the lifetime of the connection.
+	const n = 64
+	record := strings.Repeat("x", maxItemRecordSize)
+	errCh := make(chan error, 2)
+	records := readAll(server, n, errCh)
+	go func() {
+		encoder := gob.NewEncoder(client)
+		for i := 0; i < n; i++ {
+			if err := encoder.Encode(record); err != nil {
+				errCh <- fmt.Errorf("write of record %d = %v", i, err)
+				return
+			}
+		}
+	}()
+	received := 0
+	for got := range records {
+		if got != record {
+			t.Fatalf("record %d was altered", received)
+		}
+		received++
+	}
+	if received != n {
+		t.Fatalf("received %d records, want %d: %v", received, n, <-errCh)
+	}
+
+	// A record larger than the limit is refused.
+	go client.Write(make([]byte, config.maxRecordSize()))
+	if _, err := server.Read(make([]byte, 4096)); err == nil {
+		t.Fatalf("Read of a too large record = nil error, want an error")
+	}
+}
+
+func TestSecureConnShortReads(t *testing.T) {
+	client, server := newTestSecureConnPair(t, &Config{cacheSize: 1})
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		client.Write([]byte("first record"))
+		client.Write([]byte("second"))
+	}()
+	// A record which does not fit into the buffer is returned by the next Reads.
+	var got []string
+	b := make([]byte, 5)
+	for _, want := range []string{"first", " reco", "rd", "secon", "d"} {
+		n, err := server.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > len(b) {
+			t.Fatalf("Read = %d bytes into a buffer of %d bytes", n, len(b))
+		}
+		got = append(got, string(b[:n]))
+		if got[len(got)-1] != want {
+			t.Fatalf("Reads = %q, want the last one to be %q", got, want)
+		}
+	}
+}
+
+func TestSecureConnGobMessagesLargerThanBuffer(t *testing.T) {
+	client, server := newTestSecureConnPair(t, &Config{cacheSize: 1})
+	defer client.Close()
+	defer server.Close()
+
+	// gob reads the connection through a buffer of 4096 bytes, like the
+	// P2P endpoints do, so that the messages span several Reads.
+	type message struct {
+		Records [][]byte
+	}
+	sent := message{}
+	for i := 0; i < 10; i++ {
+		sent.Records = append(sent.Records, bytes.Repeat([]byte{byte(i)}, 1000))
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		encoder := gob.NewEncoder(client)
+		for i := 0; i < 3; i++ {
+			if err := encoder.Encode(&sent); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	decoder := gob.NewDecoder(server)
+	for i := 0; i < 3; i++ {
+		var received message
+		if err := decoder.Decode(&received); err != nil {
+			t.Fatalf("Decode of message %d = %v", i, err)
+		}
+		if len(received.Records) != len(sent.Records) {
+			t.Fatalf("message %d has %d records, want %d", i, len(received.Records), len(sent.Records))
+		}
+		for j := range sent.Records {
+			if !bytes.Equal(received.Records[j], sent.Records[j]) {
+				t.Fatalf("record %d of message %d was altered", j, i)
+			}
+		}
+	}
+	select {
+	case err := <-errCh:
+		t.Fatal(err)
+	default:
+	}
+}
@@ -1,11 +1,13 @@
 package securecomm
 
 import (
+	"bufio"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/x509"
 	"encoding/binary"
 	"encoding/gob"
-	"encoding/hex"
+	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -18,7 +20,7 @@ func init() {
 
 // SecureConn is the secure communication connection.
 type SecureConn struct {
-	conn           *net.TCPConn
+	conn           net.Conn
 	config         *Config
 	isClient       bool
 	handshakeFn    func() error // (*SecureConn).clientHandshake or serverHandshake
@@ -27,11 +29,27 @@ type SecureConn struct {
 	// handShakeCompleted is 1 if a handshake was established
 	// This field is only to be accessed with sync/atomic.
 	handShakeCompleted int32
-	input              *gob.Decoder
-	output             *gob.Encoder
+	// inputLimit limits the size of each record read by input.
+	inputLimit *recordLimitReader
+	input      *gob.Decoder
+	output     *gob.Encoder
 
-	// Master Key which encrypts and decrypts communication between two peers
-	masterKey []byte
+	// sendCipher and recvCipher encrypt and decrypt the records of each
+	// direction with the keys derived from the DH shared secret. The
+	// writers are serialized by sendMutex, so that the records are sent
+	// in the order of their sequence numbers.
+	sendCipher *recordCipher
+	recvCipher *recordCipher
+	sendMutex  sync.Mutex
+	// unread is the plaintext of the last record received which has not
+	// been returned by Read yet. Like recvCipher, only the reader touches it.
+	unread []byte
+	// writeErr is the first error of sending a record. The sequence number
+	// and the key advance before a record is sent, so the peers are out of
+	// sync after it and every later Write returns it. It is guarded by sendMutex.
+	writeErr error
+	// Public key of the remote peer, verified during the handshake
+	remotePubKey crypto.PublicKey
 }
 
 // Message that is serialized and should be send or received
@@ -39,46 +57,186 @@ type SecureConn struct {
 type Message struct {
 	Data      []byte
 	Handshake Handshake
+	// Rekey is true iff Data is the empty record announcing that the
+	// sender ratchets its key forward for the records after it.
+	Rekey bool
 }
 
+// handshakeExtensionLabel separates the fields of the handshake unknown to
+// the original protocol from the ones known to it in the signed bytes.
+const handshakeExtensionLabel = "securecomm handshake extension"
+
 // Handshake that can be included in a message
+// The fields after IsClient are zero in the handshakes of the peers of the
+// original protocol, which only know CipherSuiteDHRSA and RSA identities.
 type Handshake struct {
-	DHPub    []byte
-	RSAPub   rsa.PublicKey
-	Time     time.Time
-	Addr     net.Addr
-	Nonce    []byte
+	DHPub  []byte
+	RSAPub rsa.PublicKey
+	Time   time.Time
+	Addr   net.Addr
+	Nonce  []byte
+	// RSASig is the signature of the fields of the original protocol made
+	// with the host key. It is only made if the remote peer may be of the
+	// original protocol, which only verifies it.
 	RSASig   []byte
 	IsClient bool
+	// Ed25519Pub is the host key of a peer with an Ed25519 identity,
+	// in which case RSAPub is zero.
+	Ed25519Pub ed25519.PublicKey
+	// X25519Pub is the public key of the X25519 key exchange.
+	X25519Pub []byte
+	// Suites are the cipher suites offered by the client in the order
+	// of its preference, each with its public key in the handshake. In the
+	// handshake of the server, it is only the cipher suite chosen.
+	Suites []CipherSuite
+	// ExtNonce is the nonce of the proof of work of the fields above,
+	// which is set iff Suites is set. Nonce only covers the fields of
+	// the original protocol, whose peers verify it.
+	ExtNonce []byte
+	// ExtSig is the signature of every field made with the host key,
+	// which is either an RSA or an Ed25519 key. It is made iff Suites is set.
+	ExtSig []byte
+}
+
+// hostKey returns the public host key of the peer sending the handshake.
+func (h *Handshake) hostKey() crypto.PublicKey {
+	if len(h.Ed25519Pub) != 0 {
+		return h.Ed25519Pub
+	}
+	return &h.RSAPub
+}
+
+// setHostKey sets the public host key of the peer sending the handshake.
+func (h *Handshake) setHostKey(pubKey crypto.PublicKey) error {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		h.RSAPub = *pubKey
+	case ed25519.PublicKey:
+		h.Ed25519Pub = pubKey
+	default:
+		return fmt.Errorf("securecomm: unsupported host key type %T", pubKey)
+	}
+	return nil
+}
+
+// isExtended returns true iff the handshake is not of the original protocol.
+func (h *Handshake) isExtended() bool {
+	return len(h.Suites) != 0
+}
+
+// suites returns the cipher suites of the handshake. The peers of the
+// original protocol don't send any, but only know CipherSuiteDHRSA.
+func (h *Handshake) suites() []CipherSuite {
+	if len(h.Suites) == 0 {
+		return []CipherSuite{CipherSuiteDHRSA}
+	}
+	return h.Suites
+}
+
+// keyShare returns the public key of the key exchange of the cipher suite.
+func (h *Handshake) keyShare(suite CipherSuite) []byte {
+	if suite == CipherSuiteX25519Ed25519 {
+		return h.X25519Pub
+	}
+	return h.DHPub
+}
+
+// setKeyShare sets the public key of the key exchange of the cipher suite.
+func (h *Handshake) setKeyShare(suite CipherSuite, public []byte) {
+	if suite == CipherSuiteX25519Ed25519 {
+		h.X25519Pub = public
+	} else {
+		h.DHPub = public
+	}
 }
 
 func (h *Handshake) isValid() bool {
 	if h.Time.Add(HandshakeExpirationTime).Before(time.Now().UTC()) {
 		return false
 	}
+	// Exactly one host key, either a 4096-bit RSA key or an Ed25519 key
+	if len(h.Ed25519Pub) != 0 {
+		if len(h.Ed25519Pub) != ed25519.PublicKeySize || h.RSAPub.N != nil {
+			return false
+		}
+	} else if h.RSAPub.N == nil || h.RSAPub.Size() != 512 {
+		return false
+	}
+	// The server chooses one cipher suite. The client may offer unknown cipher
+	// suites, e.g. of newer versions, but every known one with its public key.
+	suites := h.suites()
+	if !h.IsClient && len(suites) != 1 {
+		return false
+	}
+	known := 0
+	for _, suite := range suites {
+		if size := suite.keyShareSize(); size != 0 {
+			if len(h.keyShare(suite)) != size {
+				return false
+			}
+			known++
+		}
+	}
 
-	return len(h.DHPub) == 256 &&
-		h.RSAPub.Size() == 512 &&
+	extNonceSize := 0
+	if h.isExtended() {
+		extNonceSize = ScryptNonceSize
+	}
+
+	return known != 0 &&
+		len(h.ExtNonce) == extNonceSize &&
 		!h.Time.IsZero() &&
 		h.Addr != nil &&
 		h.Addr.String() != "" &&
 		len(h.Nonce) == ScryptNonceSize &&
-		len(h.RSASig) == 512
+		h.hasValidSignatureSizes()
+}
+
+// hasValidSignatureSizes returns true iff the handshake has the signatures
+// of its protocol, whose sizes are those of the signatures of its host key.
+func (h *Handshake) hasValidSignatureSizes() bool {
+	size := signatureSize(h.hostKey())
+	if !h.isExtended() {
+		return len(h.RSASig) == size
+	}
+	return len(h.ExtSig) == size && (len(h.RSASig) == 0 || len(h.RSASig) == size)
+}
+
+// sign signs the handshake with the host key. If legacy is true, then the
+// fields of the original protocol are signed into RSASig as well.
+func (h *Handshake) sign(hostKey crypto.Signer, legacy bool) (err error) {
+	if legacy || !h.isExtended() {
+		if h.RSASig, err = Sign(hostKey, crypto.SHA3_256, h.concatIdentifiersInclNonce()); err != nil {
+			return err
+		}
+	}
+	if h.isExtended() {
+		h.ExtSig, err = Sign(hostKey, crypto.SHA3_256, h.concatAll())
+	}
+	return err
+}
+
+// verify checks the signature of the handshake with its host key. Every
+// field is covered by ExtSig, so that RSASig is ignored if it is set.
+func (h *Handshake) verify() error {
+	if h.isExtended() {
+		return Verify(h.hostKey(), crypto.SHA3_256, h.concatAll(), h.ExtSig)
+	}
+	return Verify(h.hostKey(), crypto.SHA3_256, h.concatIdentifiersInclNonce(), h.RSASig)
 }
 
-// concatIdentifiers returns a byte slice of every identity-realted field in the handshake (DHPub, RSAPub, Time, Addr)
+// concatIdentifiers returns a byte slice of every identity-realted field in the handshake (DHPub, host key, Time, Addr)
 func (h *Handshake) concatIdentifiers() (result []byte) {
 	// Serialize Public Key
-	rsaPub := x509.MarshalPKCS1PublicKey(&h.RSAPub)
-	result = append(h.DHPub, rsaPub...)
+	hostKey, _ := MarshalPublicKey(h.hostKey())
+	result = append(append([]byte{}, h.DHPub...), hostKey...)
 
 	// Serialize Time
 	timeBytes := toByteArray(h.Time.Unix())
 	result = append(result, timeBytes[:]...)
 
 	// Serialize IP adress
-	addrBytes, _ := hex.DecodeString(h.Addr.String())
-	result = append(result, addrBytes...)
+	result = append(result, []byte(h.Addr.String())...)
 	return result
 }
 
@@ -89,6 +247,28 @@ func (h *Handshake) concatIdentifiersInclNonce() (result []byte) {
 	return result
 }
 
+// concatExtension returns a byte slice of every field in the handshake except the signatures and
+// ExtNonce, which are the fields of the original protocol followed by the ones unknown to it
+func (h *Handshake) concatExtension() (result []byte) {
+	result = h.concatIdentifiersInclNonce()
+	result = append(result, handshakeExtensionLabel...)
+	result = append(result, byte(len(h.X25519Pub)))
+	result = append(result, h.X25519Pub...)
+	result = append(result, byte(len(h.Suites)))
+	for _, suite := range h.Suites {
+		result = append(result, byte(suite))
+	}
+	return result
+}
+
+// concatAll returns a byte slice of every field in the handshake except the signatures
+func (h *Handshake) concatAll() (result []byte) {
+	result = h.concatExtension()
+	result = append(result, byte(len(h.ExtNonce)))
+	result = append(result, h.ExtNonce...)
+	return result
+}
+
 type messageError struct{}
 
 func (messageError) Error() string { return "securecomm: Message format is incorrect" }
@@ -105,10 +285,46 @@ func (c *SecureConn) write(data *Message) error {
 // Read a Message directly, should be used only internally
 func (c *SecureConn) read() (*Message, error) {
 	var data Message
+	c.inputLimit.remaining = c.config.maxRecordSize()
 	err := c.input.Decode(&data)
 	return &data, err
 }
 
+type recordSizeError struct{}
+
+func (recordSizeError) Error() string { return "securecomm: record is too large" }
+
+// recordLimitReader reads from r until the remaining bytes of the record
+// being read are exhausted. It is an io.ByteReader, so that the gob.Decoder
+// reading from it does not buffer the bytes of the next record.
+type recordLimitReader struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+func (lr *recordLimitReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		return 0, recordSizeError{}
+	}
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	return n, err
+}
+
+func (lr *recordLimitReader) ReadByte() (byte, error) {
+	if lr.remaining <= 0 {
+		return 0, recordSizeError{}
+	}
+	b, err := lr.r.ReadByte()
+	if err == nil {
+		lr.remaining--
+	}
+	return b, err
+}
+
 // Handshake runs the client or server handshake
 // protocol if it has not yet been run.
 //
@@ -129,6 +345,49 @@ func (c *SecureConn) Handshake() error {
 	return handshakeErr
 }
 
+// establishSessionKeys derives the keys of both directions from the DH shared
+// secret and the handshake transcript, and creates the ciphers of the records.
+func (c *SecureConn) establishSessionKeys(sharedSecret []byte, mClient, mServer *Handshake) error {
+	clientToServer, serverToClient, err := deriveSessionKeys(sharedSecret, mClient, mServer)
+	if err != nil {
+		return err
+	}
+	sendKey, recvKey := clientToServer, serverToClient
+	if !c.isClient {
+		sendKey, recvKey = serverToClient, clientToServer
+	}
+	if c.sendCipher, err = newRecordCipher(sendKey); err != nil {
+		return err
+	}
+	c.recvCipher, err = newRecordCipher(recvKey)
+	return err
+}
+
+// sendRekey is the method for announcing to the remote peer that the key of
+// the records sent is ratcheted forward, and then ratcheting it. The announcement
+// is sealed with the current key and the rekey label as additional data, so
+// that no data record can be turned into it. The caller has to hold sendMutex.
+func (c *SecureConn) sendRekey() error {
+	encB, err := c.sendCipher.seal(nil, []byte(rekeyLabel))
+	if err != nil {
+		return err
+	}
+	if err = c.write(&Message{Data: encB, Rekey: true}); err != nil {
+		return err
+	}
+	return c.sendCipher.rekey()
+}
+
+// RemotePublicKey returns the public host key of the remote peer, either an
+// *rsa.PublicKey or an ed25519.PublicKey, which is nil if the handshake has
+// not been completed yet.
+func (c *SecureConn) RemotePublicKey() crypto.PublicKey {
+	c.handshakeMutex.Lock()
+	defer c.handshakeMutex.Unlock()
+
+	return c.remotePubKey
+}
+
 func toByteArray(i int64) (arr [8]byte) {
 	binary.BigEndian.PutUint64(arr[0:8], uint64(i))
 	return
@@ -3,32 +3,80 @@
 package utils
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 )
 
+// ParseIPPort parses an (ip, port) pair address such as "127.0.0.1:6001",
+// "[::1]:6001" or "[fe80::1%eth0]:6001". Host names are not resolved.
+func ParseIPPort(addr string) (*net.TCPAddr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	zone := ""
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || (zone != "" && ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid IP address in %q", addr)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %q", addr)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(portNum), Zone: zone}, nil
+}
+
 // TCPAddrCmp compares 2 (ip, port) pair addresses and returns
-// true if and only if they are equivalent.
+// true if and only if they are equivalent. The IP addresses are
+// compared by value, so e.g. an IPv4-mapped IPv6 address equals
+// the IPv4 address.
 func TCPAddrCmp(a, b string) bool {
-	if ahost, aport, err := net.SplitHostPort(a); err == nil {
-		if bhost, bport, err := net.SplitHostPort(b); err == nil {
-			if net.ParseIP(ahost).Equal(net.ParseIP(bhost)) {
-				return aport == bport
-			}
+	aAddr, err := ParseIPPort(a)
+	if err != nil {
+		return false
+	}
+	bAddr, err := ParseIPPort(b)
+	if err != nil {
+		return false
+	}
+	return aAddr.IP.Equal(bAddr.IP) && aAddr.Zone == bAddr.Zone && aAddr.Port == bAddr.Port
+}
+
+// SplitAddrList splits a comma separated list of addresses,
+// dropping the surrounding spaces and the empty entries.
+func SplitAddrList(list string) []string {
+	addrs := []string{}
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
 		}
 	}
-	return false
+	return addrs
 }
 
-// GetOutboundIP attempts to find the public IP of the
+// GetOutboundIP attempts to find the public IPv4 address of the
 // outgoing TCP or UDP connections.
 func GetOutboundIP() (string, error) {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+	return getOutboundIP("udp4", "8.8.8.8:80")
+}
+
+// GetOutboundIPv6 attempts to find the public IPv6 address of the
+// outgoing TCP or UDP connections.
+func GetOutboundIPv6() (string, error) {
+	return getOutboundIP("udp6", "[2001:4860:4860::8888]:80")
+}
+
+func getOutboundIP(network, remoteAddr string) (string, error) {
+	conn, err := net.Dial(network, remoteAddr)
 	if err != nil {
 		return "", err
 	}
 	defer conn.Close()
-	localAddr := conn.LocalAddr().String()
-	idx := strings.LastIndex(localAddr, ":")
-	return localAddr[0:idx], nil
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	return host, err
 }